@@ -0,0 +1,220 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+
+	"k8s.io/kubernetes/pkg/util/wait"
+
+	"github.com/aledbf/ingress-controller/pkg/ingress"
+)
+
+var (
+	// URL of the endpoint in NGINX that updates the certificates
+	// served using ssl_certificate_by_lua
+	certificatesURL     = "http://127.0.0.1:18080/configuration/certificates"
+	certificatesTimeout = 10 * time.Second
+	// interval and timeout used to send the certificates after NGINX starts
+	certificatesRetryInterval = time.Second
+	certificatesRetryTimeout  = time.Minute
+)
+
+// certificatesRequest is the body of the requests sent to NGINX
+type certificatesRequest struct {
+	// Certificates contains the certificate and key (PEM) by host
+	Certificates map[string]string `json:"certificates"`
+	// Removed contains the hosts without certificate
+	Removed []string `json:"removed"`
+}
+
+// certificateStore keeps the certificates sent to NGINX when the dynamic
+// certificates are enabled. A change in a certificate only requires
+// sending the new certificate instead of a reload
+type certificateStore struct {
+	mu      sync.Mutex
+	client  *http.Client
+	enabled bool
+	// certificate files of the last configuration by host
+	files map[string]string
+	// checksum of the certificates in the last configuration by host
+	checksums map[string]string
+	// checksum of the certificates sent to NGINX by host
+	sent map[string]string
+	// hosts of the last configuration served using the certificates
+	// in the configuration file
+	static map[string]bool
+}
+
+// servedDynamically returns true if the certificate of the server can be
+// served using ssl_certificate_by_lua. The Lua handler only sets one
+// certificate and does not staple the OCSP response, so the servers with
+// an ECDSA certificate or an OCSP response use the configuration file.
+// This must match the condition in the template
+func servedDynamically(server *ingress.Server) bool {
+	return server.SSLCertificateECDSA == "" && server.SSLStaplingFile == ""
+}
+
+func newCertificateStore() *certificateStore {
+	return &certificateStore{
+		client:    &http.Client{Timeout: certificatesTimeout},
+		files:     map[string]string{},
+		checksums: map[string]string{},
+		sent:      map[string]string{},
+		static:    map[string]bool{},
+	}
+}
+
+// setEnabled configures if the certificates are served dynamically
+func (s *certificateStore) setEnabled(enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.enabled != enabled {
+		s.sent = map[string]string{}
+	}
+	s.enabled = enabled
+}
+
+func (s *certificateStore) isEnabled() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enabled
+}
+
+// requiresReload returns true if the certificate of any of the hosts
+// is not served dynamically, so a change requires a reload
+func (s *certificateStore) requiresReload(hosts []string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, host := range hosts {
+		if s.static[host] {
+			return true
+		}
+	}
+	return false
+}
+
+// update sends to NGINX the certificates of the servers that changed
+func (s *certificateStore) update(servers []*ingress.Server) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.files = map[string]string{}
+	s.checksums = map[string]string{}
+	s.static = map[string]bool{}
+	for _, server := range servers {
+		if !server.SSL || server.SSLCertificate == "" {
+			continue
+		}
+		if !servedDynamically(server) {
+			s.static[server.Name] = true
+			continue
+		}
+		s.files[server.Name] = server.SSLCertificate
+		s.checksums[server.Name] = server.SSLPemChecksum
+	}
+
+	return s.send()
+}
+
+// reset sends all the certificates again once NGINX is running. This
+// is required after NGINX starts because the shared dict is empty
+func (s *certificateStore) reset() {
+	s.mu.Lock()
+	s.sent = map[string]string{}
+	enabled := s.enabled
+	s.mu.Unlock()
+
+	if !enabled {
+		return
+	}
+
+	err := wait.PollImmediate(certificatesRetryInterval, certificatesRetryTimeout, func() (bool, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.send() == nil, nil
+	})
+	if err != nil {
+		glog.Warningf("the certificates were not sent to NGINX after %v", certificatesRetryTimeout)
+	}
+}
+
+// send sends the certificates not yet in NGINX. It must be called
+// with the lock held
+func (s *certificateStore) send() error {
+	if !s.enabled {
+		return nil
+	}
+
+	req := certificatesRequest{
+		Certificates: map[string]string{},
+		Removed:      []string{},
+	}
+	for host, checksum := range s.checksums {
+		if sent, ok := s.sent[host]; ok && sent == checksum {
+			continue
+		}
+		pem, err := ioutil.ReadFile(s.files[host])
+		if err != nil {
+			return fmt.Errorf("error reading certificate of %v: %v", host, err)
+		}
+		req.Certificates[host] = string(pem)
+	}
+	for host := range s.sent {
+		if _, ok := s.checksums[host]; !ok {
+			req.Removed = append(req.Removed, host)
+		}
+	}
+
+	if len(req.Certificates) == 0 && len(req.Removed) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	res, err := s.client.Post(certificatesURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("unexpected status code %v updating certificates: %s", res.StatusCode, msg)
+	}
+
+	for host := range req.Certificates {
+		s.sent[host] = s.checksums[host]
+	}
+	for _, host := range req.Removed {
+		delete(s.sent, host)
+	}
+	glog.V(2).Infof("certificates updated in NGINX: %v updated, %v removed", len(req.Certificates), len(req.Removed))
+	return nil
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/aledbf/ingress-controller/pkg/ingress"
+)
+
+func TestCertificateStore(t *testing.T) {
+	var requests []certificatesRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req certificatesRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		requests = append(requests, req)
+	}))
+	defer server.Close()
+
+	defURL := certificatesURL
+	defer func() { certificatesURL = defURL }()
+	certificatesURL = server.URL
+
+	dir, err := ioutil.TempDir("", "certificates")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	fooPem := dir + "/default-foo.pem"
+	barPem := dir + "/default-bar.pem"
+	ioutil.WriteFile(fooPem, []byte("foo"), 0644)
+	ioutil.WriteFile(barPem, []byte("bar"), 0644)
+
+	servers := []*ingress.Server{
+		{Name: "foo.bar", SSL: true, SSLCertificate: fooPem, SSLPemChecksum: "1"},
+		{Name: "bar.baz", SSL: true, SSLCertificate: barPem, SSLPemChecksum: "1"},
+		{Name: "plain.bar"},
+		{Name: "ecdsa.bar", SSL: true, SSLCertificate: barPem, SSLCertificateECDSA: fooPem},
+		{Name: "stapled.bar", SSL: true, SSLCertificate: barPem, SSLStaplingFile: fooPem},
+	}
+
+	s := newCertificateStore()
+	if err := s.update(servers); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(requests) != 0 {
+		t.Errorf("expected no requests with the dynamic certificates disabled")
+	}
+
+	s.setEnabled(true)
+	if err := s.update(servers); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := certificatesRequest{
+		Certificates: map[string]string{"foo.bar": "foo", "bar.baz": "bar"},
+		Removed:      []string{},
+	}
+	if len(requests) != 1 || !reflect.DeepEqual(requests[0], expected) {
+		t.Fatalf("expected %v but returned %v", expected, requests)
+	}
+
+	// without changes the certificates are not sent again
+	s.update(servers)
+	if len(requests) != 1 {
+		t.Errorf("expected no requests without changes but returned %v", requests[1:])
+	}
+
+	ioutil.WriteFile(fooPem, []byte("new foo"), 0644)
+	s.update([]*ingress.Server{
+		{Name: "foo.bar", SSL: true, SSLCertificate: fooPem, SSLPemChecksum: "2"},
+	})
+	expected = certificatesRequest{
+		Certificates: map[string]string{"foo.bar": "new foo"},
+		Removed:      []string{"bar.baz"},
+	}
+	if len(requests) != 2 || !reflect.DeepEqual(requests[1], expected) {
+		t.Errorf("expected %v but returned %v", expected, requests[1:])
+	}
+
+	// after a restart of NGINX all the certificates are sent again
+	s.reset()
+	expected = certificatesRequest{
+		Certificates: map[string]string{"foo.bar": "new foo"},
+		Removed:      []string{},
+	}
+	if len(requests) != 3 || !reflect.DeepEqual(requests[2], expected) {
+		t.Errorf("expected %v but returned %v", expected, requests[2:])
+	}
+}
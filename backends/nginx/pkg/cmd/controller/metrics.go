@@ -0,0 +1,50 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	templateRenderOperation = "template_render"
+	configTestOperation     = "config_test"
+)
+
+func init() {
+	prometheus.MustRegister(nginxOperationDuration)
+}
+
+var (
+	nginxOperationDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "ingress_controller",
+			Subsystem: "nginx",
+			Name:      "operation_duration_seconds",
+			Help:      "Time spent rendering the NGINX template and testing the configuration",
+			Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+		},
+		[]string{"operation"},
+	)
+)
+
+// observeOperationDuration records the time elapsed since start
+func observeOperationDuration(operation string, start time.Time) {
+	nginxOperationDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
@@ -17,16 +17,20 @@ limitations under the License.
 package main
 
 import (
-	"bytes"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"os/exec"
+	"time"
 
 	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/aledbf/ingress-controller/pkg/ingress"
 	"github.com/aledbf/ingress-controller/pkg/ingress/defaults"
+	"github.com/aledbf/ingress-controller/pkg/throttle"
+	"github.com/aledbf/ingress-controller/pkg/traffic"
 
 	"github.com/aledbf/ingress-controller/backends/nginx/pkg/config"
 	ngx_template "github.com/aledbf/ingress-controller/backends/nginx/pkg/template"
@@ -39,6 +43,19 @@ var (
 	tmplPath = "/etc/nginx/template/nginx.tmpl"
 	cfgPath  = "/etc/nginx/nginx.conf"
 	binary   = "/usr/sbin/nginx"
+
+	// address of the endpoint used by NGINX to check global rate limits
+	throttleAddr = "127.0.0.1:10247"
+	// time added to the timeout of the store to obtain the maximum time
+	// NGINX waits for a response of the endpoint
+	throttleTimeoutMargin = 100
+
+	// UDP address where NGINX sends the information of the requests
+	trafficAddr = "127.0.0.1:10248"
+
+	// URL of the status page in the default server
+	statusURL          = "http://127.0.0.1:18080/nginx_status"
+	healthCheckTimeout = 5 * time.Second
 )
 
 // newNGINXController creates a new NGINX Ingress controller.
@@ -49,7 +66,13 @@ func newNGINXController() ingress.Controller {
 	if ngx == "" {
 		ngx = binary
 	}
-	n := NGINXController{binary: ngx}
+	n := NGINXController{
+		binary:       ngx,
+		throttle:     throttle.NewThrottle(),
+		traffic:      traffic.NewCollector(),
+		certificates: newCertificateStore(),
+		pending:      &pendingState{},
+	}
 
 	var onChange func()
 	onChange = func() {
@@ -75,6 +98,16 @@ Error loading new template : %v
 	}
 
 	n.t = ngxTpl
+
+	go func() {
+		glog.Errorf("global rate limit endpoint error: %v", http.ListenAndServe(throttleAddr, n.throttle))
+	}()
+
+	prometheus.MustRegister(n.traffic)
+	go func() {
+		glog.Errorf("request metrics endpoint error: %v", n.traffic.Listen(trafficAddr))
+	}()
+
 	return n
 }
 
@@ -83,26 +116,43 @@ type NGINXController struct {
 	t *ngx_template.Template
 
 	binary string
+
+	// throttle keeps the counters of the global rate limits
+	throttle *throttle.Throttle
+
+	// traffic exposes the metrics of the requests processed by NGINX
+	traffic *traffic.Collector
+
+	// certificates sent to NGINX when the dynamic certificates are enabled
+	certificates *certificateStore
+
+	// state of the last configuration generated, applied after a reload
+	pending *pendingState
 }
 
-// Start ...
-func (n NGINXController) Start() {
+// Start starts the NGINX master process and waits until it exits
+func (n NGINXController) Start() error {
 	glog.Info("starting NGINX process...")
 	cmd := exec.Command(n.binary, "-c", cfgPath)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	if err := cmd.Start(); err != nil {
-		glog.Fatalf("nginx error: %v", err)
+		return fmt.Errorf("nginx error: %v", err)
 	}
+	// the certificates are lost when the process exits
+	go n.certificates.reset()
 	if err := cmd.Wait(); err != nil {
-		glog.Errorf("nginx error: %v", err)
+		return fmt.Errorf("nginx error: %v", err)
 	}
+
+	return fmt.Errorf("nginx process exited")
 }
 
-// Stop ...
+// Stop starts a graceful shutdown of NGINX. The master process exits
+// after the workers finish the in-flight requests
 func (n NGINXController) Stop() error {
 	n.t.Close()
-	return exec.Command(n.binary, "-s", "stop").Run()
+	return exec.Command(n.binary, "-s", "quit").Run()
 }
 
 // Restart ...
@@ -112,7 +162,15 @@ func (n NGINXController) Restart(data []byte) ([]byte, error) {
 		return nil, err
 	}
 
-	return exec.Command(n.binary, "-s", "reload").CombinedOutput()
+	out, err := exec.Command(n.binary, "-s", "reload").CombinedOutput()
+	if err != nil {
+		return out, err
+	}
+
+	if state := n.pending.take(data); state != nil {
+		n.applyState(state)
+	}
+	return out, nil
 }
 
 // Test checks is a file contains a valid NGINX configuration
@@ -126,44 +184,36 @@ func (n NGINXController) UpstreamDefaults() defaults.Backend {
 	return d.Backend
 }
 
-// IsReloadRequired check if the new configuration file is different
-// from the current one.
-func (n NGINXController) IsReloadRequired(data []byte) bool {
-	in, err := os.Open(cfgPath)
-	if err != nil {
-		return false
+// IsReloadRequired checks if there are changes in the configuration.
+// All the changes require a reload of NGINX except the changes in the
+// certificates served dynamically
+func (n NGINXController) IsReloadRequired(changes *ingress.ConfigurationChanges) bool {
+	if n.certificates.isEnabled() && !n.certificates.requiresReload(changes.ChangedCertificates) {
+		c := *changes
+		c.ChangedCertificates = nil
+		return !c.IsEmpty()
 	}
-	src, err := ioutil.ReadAll(in)
-	in.Close()
-	if err != nil {
-		return false
-	}
-
-	if !bytes.Equal(src, data) {
-		tmpfile, err := ioutil.TempFile("", "nginx-cfg-diff")
-		if err != nil {
-			glog.Errorf("error creating temporal file: %s", err)
-			return false
-		}
-		defer tmpfile.Close()
-		err = ioutil.WriteFile(tmpfile.Name(), data, 0644)
-		if err != nil {
-			return false
-		}
+	return !changes.IsEmpty()
+}
 
-		diffOutput, err := diff(src, data)
-		if err != nil {
-			glog.Errorf("error computing diff: %s", err)
-			return true
-		}
+// UpdateCertificates sends the certificates of the servers to NGINX
+// when the dynamic certificates are enabled
+func (n NGINXController) UpdateCertificates(servers []*ingress.Server) error {
+	return n.certificates.update(servers)
+}
 
-		if glog.V(2) {
-			glog.Infof("NGINX configuration diff\n")
-			glog.Infof("%v", string(diffOutput))
-		}
-		return len(diffOutput) > 0
+// HealthCheck checks the NGINX status page is returning ok (status code 200)
+func (n NGINXController) HealthCheck() error {
+	client := &http.Client{Timeout: healthCheckTimeout}
+	res, err := client.Get(statusURL)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("NGINX is not healthy (status page returned %v)", res.StatusCode)
 	}
-	return false
+	return nil
 }
 
 // Info return build information
@@ -234,21 +284,90 @@ func (n NGINXController) OnUpdate(cmap *api.ConfigMap, ingressCfg ingress.Config
 		cfg.ServerNameHashMaxSize = serverNameHashMaxSize
 	}
 
+	serverTLS := configureTLS(&cfg, ingressCfg.Servers)
+
 	conf := make(map[string]interface{})
 	// adjust the size of the backlog
 	conf["backlogSize"] = sysctlSomaxconn()
 	conf["upstreams"] = ingressCfg.Upstreams
 	conf["passthroughUpstreams"] = ingressCfg.PassthroughUpstreams
 	conf["servers"] = ingressCfg.Servers
+	conf["serverTLS"] = serverTLS
 	conf["tcpUpstreams"] = ingressCfg.TCPUpstreams
 	conf["udpUpstreams"] = ingressCfg.UDPUpstreams
 	conf["healthzURL"] = ingressCfg.HealthzURL
 	conf["defResolver"] = cfg.Resolver
 	conf["sslDHParam"] = ""
+	if ingressCfg.DHParam != nil {
+		conf["sslDHParam"] = ingressCfg.DHParam.FileName
+	}
+	conf["sslSessionTicketKeys"] = ingressCfg.SessionTicketKeys
 	conf["customErrors"] = len(cfg.CustomHTTPErrors) > 0
+	conf["throttleAddr"] = throttleAddr
+	conf["throttleTimeout"] = cfg.GlobalRateLimitStoreTimeout + throttleTimeoutMargin
 	conf["cfg"] = ngx_template.StandarizeKeyNames(cfg)
 
-	return n.t.Write(conf, n.testTemplate)
+	start := time.Now()
+	data, err := n.t.Write(conf, func(cfg []byte) error {
+		// the template is rendered before testing the configuration
+		observeOperationDuration(templateRenderOperation, start)
+		defer observeOperationDuration(configTestOperation, time.Now())
+		return n.testTemplate(cfg)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	n.pending.set(data, &backendState{
+		labels:              trafficLabels(ingressCfg.Servers),
+		throttle:            throttleConfig(cfg),
+		dynamicCertificates: cfg.EnableDynamicCertificates,
+	})
+	return data, nil
+}
+
+// trafficLabels returns the labels of the request metrics of the
+// locations defined in the servers
+func trafficLabels(servers []*ingress.Server) []traffic.Labels {
+	var labels []traffic.Labels
+	for _, server := range servers {
+		for _, loc := range server.Locations {
+			labels = append(labels, traffic.Labels{
+				Host:      server.Name,
+				Path:      loc.Path,
+				Namespace: loc.Namespace,
+				Ingress:   loc.Ingress,
+				Service:   loc.Service,
+				Upstream:  loc.Upstream.Name,
+			})
+		}
+	}
+	return labels
+}
+
+// throttleConfig returns the configuration of the store
+// used to keep the counters of the global rate limits
+func throttleConfig(cfg config.Configuration) throttle.StoreConfig {
+	if cfg.GlobalRateLimitStore == "" {
+		return throttle.StoreConfig{}
+	}
+
+	port := cfg.GlobalRateLimitStorePort
+	if port == 0 {
+		switch cfg.GlobalRateLimitStore {
+		case throttle.Memcached:
+			port = 11211
+		case throttle.Redis:
+			port = 6379
+		}
+	}
+
+	return throttle.StoreConfig{
+		Type:    cfg.GlobalRateLimitStore,
+		Host:    cfg.GlobalRateLimitStoreHost,
+		Port:    port,
+		Timeout: time.Duration(cfg.GlobalRateLimitStoreTimeout) * time.Millisecond,
+	}
 }
 
 // http://graphics.stanford.edu/~seander/bithacks.html#RoundUpPowerOf2
@@ -0,0 +1,97 @@
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aledbf/ingress-controller/pkg/ingress"
+)
+
+func TestIsReloadRequired(t *testing.T) {
+	tests := []struct {
+		changes  *ingress.ConfigurationChanges
+		expected bool
+	}{
+		{&ingress.ConfigurationChanges{}, false},
+		{&ingress.ConfigurationChanges{ConfigMap: true}, true},
+		{&ingress.ConfigurationChanges{AddedServers: []string{"foo.bar"}}, true},
+		{&ingress.ConfigurationChanges{ChangedUpstreams: []ingress.UpstreamChanges{{Name: "default-foo-80"}}}, true},
+	}
+
+	n := NGINXController{}
+	for _, test := range tests {
+		if r := n.IsReloadRequired(test.changes); r != test.expected {
+			t.Errorf("expected %v but returned %v for changes %v", test.expected, r, test.changes)
+		}
+	}
+}
+
+func TestIsReloadRequiredDynamicCertificates(t *testing.T) {
+	changes := &ingress.ConfigurationChanges{ChangedCertificates: []string{"foo.bar"}}
+
+	n := NGINXController{certificates: newCertificateStore()}
+	if !n.IsReloadRequired(changes) {
+		t.Errorf("expected a reload with changes in the certificates")
+	}
+
+	n.certificates.setEnabled(true)
+	if n.IsReloadRequired(changes) {
+		t.Errorf("expected no reload with dynamic certificates")
+	}
+
+	changes.ChangedServers = []string{"bar.baz"}
+	if !n.IsReloadRequired(changes) {
+		t.Errorf("expected a reload with changes in the servers")
+	}
+
+	// the certificates of servers with an ECDSA certificate are not served dynamically
+	n.certificates.update([]*ingress.Server{
+		{Name: "foo.bar", SSL: true, SSLCertificate: "rsa.pem", SSLCertificateECDSA: "ecdsa.pem"},
+	})
+	changes = &ingress.ConfigurationChanges{ChangedCertificates: []string{"foo.bar"}}
+	if !n.IsReloadRequired(changes) {
+		t.Errorf("expected a reload with changes in a certificate not served dynamically")
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	code := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/nginx_status" {
+			t.Errorf("unexpected path %v", r.URL.Path)
+		}
+		w.WriteHeader(code)
+	}))
+	defer server.Close()
+
+	defStatusURL := statusURL
+	defer func() { statusURL = defStatusURL }()
+	statusURL = server.URL + "/nginx_status"
+
+	n := NGINXController{}
+	if err := n.HealthCheck(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	code = http.StatusInternalServerError
+	if err := n.HealthCheck(); err == nil {
+		t.Errorf("expected error with status code %v", code)
+	}
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/golang/glog"
+
+	"github.com/aledbf/ingress-controller/pkg/throttle"
+	"github.com/aledbf/ingress-controller/pkg/traffic"
+)
+
+// backendState contains the state of the controller that depends on
+// the configuration loaded in NGINX
+type backendState struct {
+	// labels of the request metrics of the locations
+	labels []traffic.Labels
+	// store of the counters of the global rate limits
+	throttle throttle.StoreConfig
+	// dynamicCertificates indicates if the certificates are served dynamically
+	dynamicCertificates bool
+}
+
+// pendingState keeps the state of the last configuration generated by
+// OnUpdate until NGINX loads it. The configurations that are never
+// loaded (ie the candidates of a bisection or a configuration that
+// cannot be reloaded) do not modify the state
+type pendingState struct {
+	mu    sync.Mutex
+	data  []byte
+	state *backendState
+}
+
+func (p *pendingState) set(data []byte, state *backendState) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.data = data
+	p.state = state
+}
+
+// take returns the state of the configuration if it is the
+// last configuration generated, or nil otherwise
+func (p *pendingState) take(data []byte) *backendState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.state == nil || !bytes.Equal(p.data, data) {
+		return nil
+	}
+	state := p.state
+	p.data = nil
+	p.state = nil
+	return state
+}
+
+// applyState updates the state of the controller after NGINX
+// loads the configuration
+func (n NGINXController) applyState(state *backendState) {
+	n.traffic.Prune(state.labels)
+	err := n.throttle.Configure(state.throttle)
+	if err != nil {
+		glog.Warningf("global rate limits are disabled: %v", err)
+	}
+	n.certificates.setEnabled(state.dynamicCertificates)
+}
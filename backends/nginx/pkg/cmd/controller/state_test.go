@@ -0,0 +1,55 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/aledbf/ingress-controller/pkg/throttle"
+	"github.com/aledbf/ingress-controller/pkg/traffic"
+)
+
+func TestPendingState(t *testing.T) {
+	n := NGINXController{
+		throttle:     throttle.NewThrottle(),
+		traffic:      traffic.NewCollector(),
+		certificates: newCertificateStore(),
+		pending:      &pendingState{},
+	}
+
+	n.pending.set([]byte("valid"), &backendState{dynamicCertificates: true})
+	// a bisection generates other configurations before the reload
+	n.pending.set([]byte("candidate"), &backendState{dynamicCertificates: true})
+	if s := n.pending.take([]byte("valid")); s != nil {
+		t.Errorf("expected no state for a configuration that is not the last generated")
+	}
+	if n.certificates.isEnabled() {
+		t.Errorf("expected no changes in the state before the reload")
+	}
+
+	s := n.pending.take([]byte("candidate"))
+	if s == nil {
+		t.Fatalf("expected the state of the last configuration generated")
+	}
+	n.applyState(s)
+	if !n.certificates.isEnabled() {
+		t.Errorf("expected the dynamic certificates enabled after the reload")
+	}
+	if s := n.pending.take([]byte("candidate")); s != nil {
+		t.Errorf("expected the state applied only once")
+	}
+}
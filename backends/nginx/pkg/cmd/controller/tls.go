@@ -0,0 +1,159 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/golang/glog"
+
+	"github.com/aledbf/ingress-controller/pkg/ingress"
+	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/tlssettings"
+
+	"github.com/aledbf/ingress-controller/backends/nginx/pkg/config"
+)
+
+// serverTLS contains the TLS settings of a server after applying
+// the annotations of the Ingress rules to the global configuration
+type serverTLS struct {
+	// ProtocolsRegex matches the protocols enabled in the server.
+	// Empty if the server accepts all the protocols enabled in the port
+	ProtocolsRegex string
+	// Ciphers is empty if the server uses the global ciphers
+	Ciphers               string
+	HSTS                  bool
+	HSTSMaxAge            string
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+	HTTP2                 bool
+}
+
+// configureTLS returns the TLS settings of the servers indexed by name.
+// NGINX negotiates the protocol before the server is selected using
+// the server name (SNI), so a server cannot enable a protocol not
+// enabled in the global configuration. The protocols of a server
+// that enables other protocols are ignored. Otherwise the server
+// rejects the requests that use a protocol not enabled in it.
+// HTTP/2 is enabled in the port if any server enables it
+func configureTLS(cfg *config.Configuration, servers []*ingress.Server) map[string]serverTLS {
+	enabled := map[string]bool{}
+	for _, p := range strings.Fields(cfg.SSLProtocols) {
+		enabled[p] = true
+	}
+	global := sortProtocols(enabled)
+
+	protocols := map[string]string{}
+	for _, server := range servers {
+		if !server.SSL {
+			continue
+		}
+		protocols[server.Name] = global
+		if server.TLSSettings.Protocols == "" {
+			continue
+		}
+		var disabled []string
+		for _, p := range strings.Fields(server.TLSSettings.Protocols) {
+			if !enabled[p] {
+				disabled = append(disabled, p)
+			}
+		}
+		if len(disabled) > 0 {
+			glog.Warningf("ignoring the SSL protocols of %v: %v not enabled in the global configuration (%v)",
+				server.Name, strings.Join(disabled, ", "), global)
+			continue
+		}
+		protocols[server.Name] = server.TLSSettings.Protocols
+	}
+
+	tls := map[string]serverTLS{}
+	var withHTTP2, withoutHTTP2 []string
+	for _, server := range servers {
+		if !server.SSL {
+			continue
+		}
+		s := server.TLSSettings
+		st := serverTLS{
+			Ciphers:               s.Ciphers,
+			HSTS:                  boolOr(s.HSTS, cfg.HSTS),
+			HSTSMaxAge:            cfg.HSTSMaxAge,
+			HSTSIncludeSubdomains: boolOr(s.HSTSIncludeSubdomains, cfg.HSTSIncludeSubdomains),
+			HSTSPreload:           boolOr(s.HSTSPreload, cfg.HSTSPreload),
+			HTTP2:                 boolOr(s.HTTP2, cfg.UseHTTP2),
+		}
+		if s.HSTSMaxAge != nil {
+			st.HSTSMaxAge = strconv.Itoa(*s.HSTSMaxAge)
+		}
+		if p := protocols[server.Name]; p != global {
+			st.ProtocolsRegex = protocolsRegex(p)
+		}
+		if st.HTTP2 {
+			withHTTP2 = append(withHTTP2, server.Name)
+		} else {
+			withoutHTTP2 = append(withoutHTTP2, server.Name)
+		}
+		tls[server.Name] = st
+	}
+
+	if len(withHTTP2) > 0 && len(withoutHTTP2) > 0 {
+		glog.Warningf("HTTP/2 cannot be disabled in %v: it is enabled in the port by %v",
+			strings.Join(withoutHTTP2, ", "), strings.Join(withHTTP2, ", "))
+	}
+
+	return tls
+}
+
+// sortProtocols returns the enabled protocols in the order of the
+// known protocols followed by the unknown protocols
+func sortProtocols(enabled map[string]bool) string {
+	var sorted []string
+	known := map[string]bool{}
+	for _, p := range tlssettings.Protocols {
+		known[p] = true
+		if enabled[p] {
+			sorted = append(sorted, p)
+		}
+	}
+	var unknown []string
+	for p := range enabled {
+		if !known[p] {
+			unknown = append(unknown, p)
+		}
+	}
+	sort.Strings(unknown)
+	return strings.Join(append(sorted, unknown...), " ")
+}
+
+// protocolsRegex returns a regular expression that matches the values
+// of the variable $ssl_protocol of the protocols. The empty value of
+// the requests without SSL also matches
+func protocolsRegex(protocols string) string {
+	quoted := []string{""}
+	for _, p := range strings.Fields(protocols) {
+		quoted = append(quoted, regexp.QuoteMeta(p))
+	}
+	return "^(" + strings.Join(quoted, "|") + ")$"
+}
+
+func boolOr(b *bool, def bool) bool {
+	if b == nil {
+		return def
+	}
+	return *b
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/aledbf/ingress-controller/pkg/ingress"
+	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/tlssettings"
+
+	"github.com/aledbf/ingress-controller/backends/nginx/pkg/config"
+)
+
+func TestConfigureTLS(t *testing.T) {
+	disabled := false
+	maxAge := 60
+
+	cfg := config.NewDefault()
+	cfg.SSLProtocols = "TLSv1 TLSv1.1 TLSv1.2"
+	servers := []*ingress.Server{
+		{Name: "_", SSL: true},
+		{Name: "partner.bar", SSL: true, TLSSettings: tlssettings.Config{
+			Protocols:   "SSLv3 TLSv1.2",
+			Ciphers:     "HIGH:!aNULL",
+			HSTSMaxAge:  &maxAge,
+			HSTSPreload: &disabled,
+		}},
+		{Name: "foo.bar", SSL: true, TLSSettings: tlssettings.Config{Protocols: "TLSv1.2", HTTP2: &disabled}},
+		{Name: "plain.bar"},
+	}
+
+	tls := configureTLS(&cfg, servers)
+
+	if cfg.SSLProtocols != "TLSv1 TLSv1.1 TLSv1.2" {
+		t.Errorf("expected the global protocols in the port but returned %v", cfg.SSLProtocols)
+	}
+	if len(tls) != 3 {
+		t.Fatalf("expected the settings of 3 servers but returned %v", len(tls))
+	}
+
+	def := tls["_"]
+	if def.ProtocolsRegex != "" {
+		t.Errorf("expected the global protocols in the default server but returned %v", def.ProtocolsRegex)
+	}
+	if def.Ciphers != "" || !def.HSTS || def.HSTSMaxAge != cfg.HSTSMaxAge || !def.HSTSPreload || !def.HTTP2 {
+		t.Errorf("expected the global settings in the default server but returned %+v", def)
+	}
+
+	partner := tls["partner.bar"]
+	if partner.ProtocolsRegex != "" {
+		t.Errorf("expected the protocols of partner.bar ignored (SSLv3 is not enabled) but returned %v", partner.ProtocolsRegex)
+	}
+	if partner.Ciphers != "HIGH:!aNULL" || partner.HSTSMaxAge != "60" || partner.HSTSPreload {
+		t.Errorf("expected the settings of the annotations in partner.bar but returned %+v", partner)
+	}
+
+	foo := tls["foo.bar"]
+	if foo.ProtocolsRegex != `^(|TLSv1\.2)$` {
+		t.Errorf("expected only TLSv1.2 in foo.bar but returned %v", foo.ProtocolsRegex)
+	}
+	if foo.HTTP2 {
+		t.Errorf("expected HTTP/2 disabled in foo.bar")
+	}
+}
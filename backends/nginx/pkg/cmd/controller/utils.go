@@ -17,10 +17,6 @@ limitations under the License.
 package main
 
 import (
-	"io/ioutil"
-	"os"
-	"os/exec"
-
 	"k8s.io/kubernetes/pkg/util/sysctl"
 
 	"github.com/golang/glog"
@@ -38,25 +34,3 @@ func sysctlSomaxconn() int {
 
 	return maxConns
 }
-
-func diff(b1, b2 []byte) ([]byte, error) {
-	f1, err := ioutil.TempFile("", "a")
-	if err != nil {
-		return nil, err
-	}
-	defer f1.Close()
-	defer os.Remove(f1.Name())
-
-	f2, err := ioutil.TempFile("", "b")
-	if err != nil {
-		return nil, err
-	}
-	defer f2.Close()
-	defer os.Remove(f2.Name())
-
-	f1.Write(b1)
-	f2.Write(b2)
-
-	out, _ := exec.Command("diff", "-u", f1.Name(), f2.Name()).CombinedOutput()
-	return out, nil
-}
@@ -103,6 +103,17 @@ type Configuration struct {
 
 	VtsStatusZoneSize string `structs:"vts-status-zone-size,omitempty"`
 
+	// EnableRequestMetrics sends information about each request to the Ingress
+	// controller to expose request metrics by host, location and upstream in /metrics
+	// By default this is disabled
+	EnableRequestMetrics bool `structs:"enable-request-metrics,omitempty"`
+
+	// EnableDynamicCertificates serves the SSL certificates using ssl_certificate_by_lua
+	// with the certificates sent by the Ingress controller to a shared dict. Changes in
+	// the certificates do not require a reload of NGINX
+	// By default this is disabled
+	EnableDynamicCertificates bool `structs:"enable-dynamic-certificates,omitempty"`
+
 	// RetryNonIdempotent since 1.9.13 NGINX will not retry non-idempotent requests (POST, LOCK, PATCH)
 	// in case of an error. The previous behavior can be restored using the value true
 	RetryNonIdempotent bool `structs:"retry-non-idempotent"`
@@ -112,6 +123,25 @@ type Configuration struct {
 	// Log levels above are listed in the order of increasing severity
 	ErrorLogLevel string `structs:"error-log-level,omitempty"`
 
+	// GlobalRateLimitStore defines the store used to keep the counters of the rate limits
+	// shared between all the replicas of the Ingress controller (memcached or redis).
+	// By default is empty (global rate limits are disabled)
+	GlobalRateLimitStore string `structs:"global-rate-limit-store,omitempty"`
+
+	// GlobalRateLimitStoreHost address of the store with the global rate limit counters
+	GlobalRateLimitStoreHost string `structs:"global-rate-limit-store-host,omitempty"`
+
+	// GlobalRateLimitStorePort port of the store with the global rate limit counters.
+	// By default 11211 for memcached and 6379 for redis
+	GlobalRateLimitStorePort int `structs:"global-rate-limit-store-port,omitempty"`
+
+	// GlobalRateLimitStoreTimeout maximum time in milliseconds to wait for the store.
+	// If the store is not available the requests are allowed
+	GlobalRateLimitStoreTimeout int `structs:"global-rate-limit-store-timeout,omitempty"`
+
+	// GlobalRateLimitStatusCode status code returned to the requests that exceed a global rate limit
+	GlobalRateLimitStatusCode int `structs:"global-rate-limit-status-code,omitempty"`
+
 	// Enables or disables the header HSTS in servers running SSL
 	HSTS bool `structs:"hsts,omitempty"`
 
@@ -126,6 +156,10 @@ type Configuration struct {
 	// accessed using HTTPS.
 	HSTSMaxAge string `structs:"hsts-max-age,omitempty"`
 
+	// Enables or disables the preload directive in the HSTS header
+	// Default: true
+	HSTSPreload bool `structs:"hsts-preload,omitempty"`
+
 	// Time during which a keep-alive client connection will stay open on the server side.
 	// The zero value disables keep-alive client connections
 	// http://nginx.org/en/docs/http/ngx_http_core_module.html#keepalive_timeout
@@ -160,7 +194,8 @@ type Configuration struct {
 	// http://nginx.org/en/docs/http/ngx_http_ssl_module.html#ssl_ciphers
 	SSLCiphers string `structs:"ssl-ciphers,omitempty"`
 
-	// Base64 string that contains Diffie-Hellman key to help with "Perfect Forward Secrecy"
+	// Name (<namespace>/<name>) of the Secret that contains the Diffie-Hellman
+	// parameters in the key dhparam.pem to help with "Perfect Forward Secrecy"
 	// https://www.openssl.org/docs/manmaster/apps/dhparam.html
 	// https://wiki.mozilla.org/Security/Server_Side_TLS#DHE_handshake_and_dhparam
 	// http://nginx.org/en/docs/http/ngx_http_ssl_module.html#ssl_dhparam
@@ -182,6 +217,12 @@ type Configuration struct {
 	// http://nginx.org/en/docs/http/ngx_http_ssl_module.html#ssl_session_tickets
 	SSLSessionTickets bool `structs:"ssl-session-tickets,omitempty"`
 
+	// Name (<namespace>/<name>) of the Secret that contains the keys used to
+	// encrypt and decrypt TLS session tickets. The keys are shared by all the
+	// replicas and the newest key (sorted by name) encrypts new tickets
+	// http://nginx.org/en/docs/http/ngx_http_ssl_module.html#ssl_session_ticket_key
+	SSLSessionTicketKey string `structs:"ssl-session-ticket-key,omitempty"`
+
 	// Time during which a client may reuse the session parameters stored in a cache.
 	// http://nginx.org/en/docs/http/ngx_http_ssl_module.html#ssl_session_timeout
 	SSLSessionTimeout string `structs:"ssl-session-timeout,omitempty"`
@@ -220,38 +261,42 @@ type Configuration struct {
 // in the file default-conf.json
 func NewDefault() Configuration {
 	cfg := Configuration{
-		BodySize:                bodySize,
-		EnableDynamicTLSRecords: true,
-		EnableSPDY:              false,
-		ErrorLogLevel:           errorLevel,
-		HSTS:                    true,
-		HSTSIncludeSubdomains:    true,
-		HSTSMaxAge:               hstsMaxAge,
-		GzipTypes:                gzipTypes,
-		KeepAlive:                75,
-		MaxWorkerConnections:     16384,
-		MapHashBucketSize:        64,
-		ProxyRealIPCIDR:          defIPCIDR,
-		ServerNameHashMaxSize:    512,
-		ServerNameHashBucketSize: 64,
-		SSLBufferSize:            sslBufferSize,
-		SSLCiphers:               sslCiphers,
-		SSLProtocols:             sslProtocols,
-		SSLSessionCache:          true,
-		SSLSessionCacheSize:      sslSessionCacheSize,
-		SSLSessionTickets:        true,
-		SSLSessionTimeout:        sslSessionTimeout,
-		UseProxyProtocol:         false,
-		UseGzip:                  true,
-		WorkerProcesses:          runtime.NumCPU(),
-		VtsStatusZoneSize:        "10m",
-		UseHTTP2:                 true,
+		BodySize:                    bodySize,
+		EnableDynamicTLSRecords:     true,
+		EnableSPDY:                  false,
+		ErrorLogLevel:               errorLevel,
+		GlobalRateLimitStoreTimeout: 50,
+		GlobalRateLimitStatusCode:   429,
+		HSTS:                        true,
+		HSTSIncludeSubdomains:       true,
+		HSTSMaxAge:                  hstsMaxAge,
+		HSTSPreload:                 true,
+		GzipTypes:                   gzipTypes,
+		KeepAlive:                   75,
+		MaxWorkerConnections:        16384,
+		MapHashBucketSize:           64,
+		ProxyRealIPCIDR:             defIPCIDR,
+		ServerNameHashMaxSize:       512,
+		ServerNameHashBucketSize:    64,
+		SSLBufferSize:               sslBufferSize,
+		SSLCiphers:                  sslCiphers,
+		SSLProtocols:                sslProtocols,
+		SSLSessionCache:             true,
+		SSLSessionCacheSize:         sslSessionCacheSize,
+		SSLSessionTickets:           true,
+		SSLSessionTimeout:           sslSessionTimeout,
+		UseProxyProtocol:            false,
+		UseGzip:                     true,
+		WorkerProcesses:             runtime.NumCPU(),
+		VtsStatusZoneSize:           "10m",
+		UseHTTP2:                    true,
 		Backend: defaults.Backend{
 			ProxyConnectTimeout:  5,
 			ProxyReadTimeout:     60,
 			ProxySendTimeout:     60,
 			ProxyBufferSize:      "4k",
 			SSLRedirect:          true,
+			ForceSSLRedirect:     false,
 			CustomHTTPErrors:     []int{},
 			WhitelistSourceRange: []string{},
 			SkipAccessLogURLs:    []string{},
@@ -21,13 +21,15 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"os/exec"
+	"regexp"
+	"sort"
 	"strings"
 	text_template "text/template"
 
 	"github.com/golang/glog"
 
 	"github.com/aledbf/ingress-controller/pkg/ingress"
+	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/ratelimit"
 	"github.com/aledbf/ingress-controller/pkg/watch"
 )
 
@@ -38,15 +40,14 @@ const (
 
 // Template ...
 type Template struct {
-	tmpl      *text_template.Template
-	fw        watch.FileWatcher
-	s         int
-	tmplBuf   *bytes.Buffer
-	outCmdBuf *bytes.Buffer
+	tmpl    *text_template.Template
+	fw      watch.FileWatcher
+	s       int
+	tmplBuf *bytes.Buffer
 }
 
-//NewTemplate returns a new Template instance or an
-//error if the specified template file contains errors
+// NewTemplate returns a new Template instance or an
+// error if the specified template file contains errors
 func NewTemplate(file string, onChange func()) (*Template, error) {
 	tmpl, err := text_template.New("nginx.tmpl").Funcs(funcMap).ParseFiles(file)
 	if err != nil {
@@ -58,11 +59,10 @@ func NewTemplate(file string, onChange func()) (*Template, error) {
 	}
 
 	return &Template{
-		tmpl:      tmpl,
-		fw:        fw,
-		s:         defBufferSize,
-		tmplBuf:   bytes.NewBuffer(make([]byte, 0, defBufferSize)),
-		outCmdBuf: bytes.NewBuffer(make([]byte, 0, defBufferSize)),
+		tmpl:    tmpl,
+		fw:      fw,
+		s:       defBufferSize,
+		tmplBuf: bytes.NewBuffer(make([]byte, 0, defBufferSize)),
 	}, nil
 }
 
@@ -77,7 +77,6 @@ func (t *Template) Write(conf map[string]interface{},
 	isValidTemplate func([]byte) error) ([]byte, error) {
 
 	defer t.tmplBuf.Reset()
-	defer t.outCmdBuf.Reset()
 
 	if glog.V(3) {
 		b, err := json.Marshal(conf)
@@ -88,25 +87,18 @@ func (t *Template) Write(conf map[string]interface{},
 	}
 
 	err := t.tmpl.Execute(t.tmplBuf, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	content := cleanConf(t.tmplBuf.Bytes())
 
 	if t.s < t.tmplBuf.Cap() {
 		glog.V(2).Infof("adjusting template buffer size from %v to %v", t.s, t.tmplBuf.Cap())
 		t.s = t.tmplBuf.Cap()
 		t.tmplBuf = bytes.NewBuffer(make([]byte, 0, t.tmplBuf.Cap()))
-		t.outCmdBuf = bytes.NewBuffer(make([]byte, 0, t.outCmdBuf.Cap()))
-	}
-
-	// squeezes multiple adjacent empty lines to be single
-	// spaced this is to avoid the use of regular expressions
-	cmd := exec.Command("/ingress-controller/clean-nginx-conf.sh")
-	cmd.Stdin = t.tmplBuf
-	cmd.Stdout = t.outCmdBuf
-	if err := cmd.Run(); err != nil {
-		glog.Warningf("unexpected error cleaning template: %v", err)
-		return t.tmplBuf.Bytes(), nil
 	}
 
-	content := t.outCmdBuf.Bytes()
 	err = isValidTemplate(content)
 	if err != nil {
 		return nil, err
@@ -115,7 +107,36 @@ func (t *Template) Write(conf map[string]interface{},
 	return content, nil
 }
 
+// cleanConf removes the spaces in empty lines and squeezes multiple
+// adjacent empty lines to be single spaced
+func cleanConf(in []byte) []byte {
+	out := bytes.NewBuffer(make([]byte, 0, len(in)))
+
+	lines := bytes.Split(in, []byte("\n"))
+	empty := false
+	for i, line := range lines {
+		if len(bytes.TrimSpace(line)) == 0 {
+			if empty {
+				continue
+			}
+			empty = true
+			line = nil
+		} else {
+			empty = false
+		}
+
+		out.Write(line)
+		if i < len(lines)-1 {
+			out.WriteByte('\n')
+		}
+	}
+
+	return out.Bytes()
+}
+
 var (
+	invalidVarChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
 	funcMap = text_template.FuncMap{
 		"empty": func(input interface{}) bool {
 			check, ok := input.(string)
@@ -124,12 +145,15 @@ var (
 			}
 			return true
 		},
-		"buildLocation":            buildLocation,
-		"buildAuthLocation":        buildAuthLocation,
-		"buildProxyPass":           buildProxyPass,
-		"buildRateLimitZones":      buildRateLimitZones,
-		"buildRateLimit":           buildRateLimit,
-		"getSSPassthroughUpstream": getSSPassthroughUpstream,
+		"buildLocation":             buildLocation,
+		"buildAuthLocation":         buildAuthLocation,
+		"buildProxyPass":            buildProxyPass,
+		"buildRateLimitZones":       buildRateLimitZones,
+		"buildRateLimit":            buildRateLimit,
+		"buildGlobalRateLimit":      buildGlobalRateLimit,
+		"buildCustomErrors":         buildCustomErrors,
+		"buildCustomErrorLocations": buildCustomErrorLocations,
+		"getSSPassthroughUpstream":  getSSPassthroughUpstream,
 
 		"contains":  strings.Contains,
 		"hasPrefix": strings.HasPrefix,
@@ -240,9 +264,12 @@ func buildProxyPass(input interface{}) string {
 	return defProxyPass
 }
 
-// buildRateLimitZones produces an array of limit_conn_zone in order to allow
-// rate limiting of request. Each Ingress rule could have up to two zones, one
-// for connection limit by IP address and other for limiting request per second
+// buildRateLimitZones produces an array of limit_conn_zone and limit_req_zone
+// in order to allow rate limiting of request. Each Ingress rule could have up
+// to three zones, one for connection limit by key (IP address by default),
+// one for limiting request per second and other for request per minute.
+// If the rule defines a whitelist a geo and map block are added to avoid
+// accounting requests from the whitelisted addresses.
 func buildRateLimitZones(input interface{}) []string {
 	zones := []string{}
 
@@ -251,21 +278,54 @@ func buildRateLimitZones(input interface{}) []string {
 		return zones
 	}
 
+	// the same Ingress rule could be used in multiple locations
+	// but the zones must be defined only once
+	added := map[string]bool{}
 	for _, server := range servers {
 		for _, loc := range server.Locations {
+			rl := loc.RateLimit
+			if rl.ID == "" || added[rl.ID] {
+				continue
+			}
+			added[rl.ID] = true
+
+			key := rateLimitKey(rl)
+			if len(rl.Whitelist) > 0 {
+				// requests from whitelisted addresses use an empty key
+				// and are not accounted in the zones
+				key = fmt.Sprintf("$limit_%v", rateLimitVar(rl))
+				geo := fmt.Sprintf("geo $limit_whitelist_%v {\n        default 0;\n", rateLimitVar(rl))
+				for _, cidr := range rl.Whitelist {
+					geo = fmt.Sprintf("%v        %v 1;\n", geo, cidr)
+				}
+				zones = append(zones, fmt.Sprintf("%v    }", geo))
+				zones = append(zones, fmt.Sprintf("map $limit_whitelist_%v %v {\n        0 %v;\n        1 \"\";\n    }",
+					rateLimitVar(rl), key, rateLimitKey(rl)))
+			}
 
-			if loc.RateLimit.Connections.Limit > 0 {
-				zone := fmt.Sprintf("limit_conn_zone $binary_remote_addr zone=%v:%vm;",
-					loc.RateLimit.Connections.Name,
-					loc.RateLimit.Connections.SharedSize)
+			if rl.Connections.Limit > 0 {
+				zone := fmt.Sprintf("limit_conn_zone %v zone=%v:%vm;",
+					key,
+					rl.Connections.Name,
+					rl.Connections.SharedSize)
 				zones = append(zones, zone)
 			}
 
-			if loc.RateLimit.RPS.Limit > 0 {
-				zone := fmt.Sprintf("limit_conn_zone $binary_remote_addr zone=%v:%vm rate=%vr/s;",
-					loc.RateLimit.Connections.Name,
-					loc.RateLimit.Connections.SharedSize,
-					loc.RateLimit.Connections.Limit)
+			if rl.RPS.Limit > 0 {
+				zone := fmt.Sprintf("limit_req_zone %v zone=%v:%vm rate=%vr/s;",
+					key,
+					rl.RPS.Name,
+					rl.RPS.SharedSize,
+					rl.RPS.Limit)
+				zones = append(zones, zone)
+			}
+
+			if rl.RPM.Limit > 0 {
+				zone := fmt.Sprintf("limit_req_zone %v zone=%v:%vm rate=%vr/m;",
+					key,
+					rl.RPM.Name,
+					rl.RPM.SharedSize,
+					rl.RPM.Limit)
 				zones = append(zones, zone)
 			}
 		}
@@ -275,7 +335,7 @@ func buildRateLimitZones(input interface{}) []string {
 }
 
 // buildRateLimit produces an array of limit_req to be used inside the Path of
-// Ingress rules. The order: connections by IP first and RPS next.
+// Ingress rules. The order: connections by key first, RPS and RPM next.
 func buildRateLimit(input interface{}) []string {
 	limits := []string{}
 
@@ -284,17 +344,166 @@ func buildRateLimit(input interface{}) []string {
 		return limits
 	}
 
-	if loc.RateLimit.Connections.Limit > 0 {
+	rl := loc.RateLimit
+	if rl.Connections.Limit > 0 {
 		limit := fmt.Sprintf("limit_conn %v %v;",
-			loc.RateLimit.Connections.Name, loc.RateLimit.Connections.Limit)
+			rl.Connections.Name, rl.Connections.Limit)
+		limits = append(limits, limit)
+		if rl.StatusCode > 0 {
+			limits = append(limits, fmt.Sprintf("limit_conn_status %v;", rl.StatusCode))
+		}
+	}
+
+	if rl.RPS.Limit > 0 {
+		limit := fmt.Sprintf("limit_req zone=%v burst=%v nodelay;",
+			rl.RPS.Name, rl.RPS.Burst)
 		limits = append(limits, limit)
 	}
 
-	if loc.RateLimit.RPS.Limit > 0 {
+	if rl.RPM.Limit > 0 {
 		limit := fmt.Sprintf("limit_req zone=%v burst=%v nodelay;",
-			loc.RateLimit.Connections.Name, loc.RateLimit.Connections.Burst)
+			rl.RPM.Name, rl.RPM.Burst)
 		limits = append(limits, limit)
 	}
 
+	if (rl.RPS.Limit > 0 || rl.RPM.Limit > 0) && rl.StatusCode > 0 {
+		limits = append(limits, fmt.Sprintf("limit_req_status %v;", rl.StatusCode))
+	}
+
 	return limits
 }
+
+// buildGlobalRateLimit produces the call to the lua function that checks
+// if a request is allowed by the global rate limit of the location
+func buildGlobalRateLimit(input interface{}, statusCode int) string {
+	loc, ok := input.(*ingress.Location)
+	if !ok {
+		return ""
+	}
+
+	grl := loc.GlobalRateLimit
+	if grl.Limit <= 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("global_rate_limit.check(\"%v\", ngx.var.%v, %v, %v, %v)",
+		grl.Name, strings.TrimPrefix(grl.Key, "$"), grl.Limit, grl.Window, statusCode)
+}
+
+// customErrorLocation describes a named location that returns the
+// custom error page for an HTTP code from a default backend
+type customErrorLocation struct {
+	Name     string
+	Code     int
+	Upstream string
+}
+
+// buildCustomErrors returns the error_page directives of a location
+// configured with a custom default backend or custom HTTP errors.
+// Locations without annotations use the directives of the http section
+func buildCustomErrors(input interface{}, globalCodes interface{}) []string {
+	errorPages := []string{}
+
+	loc, ok := input.(*ingress.Location)
+	if !ok {
+		return errorPages
+	}
+
+	if loc.DefaultBackend == "" && len(loc.CustomHTTPErrors) == 0 {
+		return errorPages
+	}
+
+	codes := locationErrorCodes(loc, globalCodes)
+	if len(codes) == 0 {
+		return errorPages
+	}
+
+	errorPages = append(errorPages, "proxy_intercept_errors on;")
+	for _, code := range codes {
+		errorPages = append(errorPages, fmt.Sprintf("error_page %v = @%v;",
+			code, customErrorLocationName(loc.DefaultBackend, code)))
+	}
+
+	return errorPages
+}
+
+// buildCustomErrorLocations returns the named locations required by
+// the error_page directives of the http section and the locations of
+// a server
+func buildCustomErrorLocations(input interface{}, globalCodes interface{}) []customErrorLocation {
+	found := map[string]customErrorLocation{}
+	add := func(upstream string, code int) {
+		name := customErrorLocationName(upstream, code)
+		found[name] = customErrorLocation{
+			Name:     name,
+			Code:     code,
+			Upstream: upstream,
+		}
+	}
+
+	codes, _ := globalCodes.([]int)
+	for _, code := range codes {
+		add("", code)
+	}
+
+	if server, ok := input.(*ingress.Server); ok {
+		for _, loc := range server.Locations {
+			if loc.DefaultBackend == "" && len(loc.CustomHTTPErrors) == 0 {
+				continue
+			}
+			for _, code := range locationErrorCodes(loc, globalCodes) {
+				add(loc.DefaultBackend, code)
+			}
+		}
+	}
+
+	names := make([]string, 0, len(found))
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	locations := make([]customErrorLocation, 0, len(names))
+	for _, name := range names {
+		locations = append(locations, found[name])
+	}
+
+	return locations
+}
+
+// locationErrorCodes returns the HTTP codes with custom error pages in a
+// location. The codes defined in the annotation replace the global codes
+func locationErrorCodes(loc *ingress.Location, globalCodes interface{}) []int {
+	if len(loc.CustomHTTPErrors) > 0 {
+		return loc.CustomHTTPErrors
+	}
+
+	codes, _ := globalCodes.([]int)
+	return codes
+}
+
+// customErrorLocationName returns the name of the location that returns
+// the custom error page of an HTTP code. An empty upstream means the
+// global default backend
+func customErrorLocationName(upstream string, code int) string {
+	if upstream == "" {
+		return fmt.Sprintf("custom_%v", code)
+	}
+
+	return fmt.Sprintf("custom_%v_%v", upstream, code)
+}
+
+// rateLimitKey returns the NGINX variable used to group the requests
+// in the zones of a rate limit
+func rateLimitKey(rl ratelimit.RateLimit) string {
+	if rl.Key == "" {
+		return ratelimit.DefKey
+	}
+
+	return rl.Key
+}
+
+// rateLimitVar returns the ID of the rate limit as a valid NGINX variable name
+func rateLimitVar(rl ratelimit.RateLimit) string {
+	return invalidVarChars.ReplaceAllString(rl.ID, "_")
+}
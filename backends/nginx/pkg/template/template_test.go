@@ -17,10 +17,13 @@ limitations under the License.
 package template
 
 import (
+	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/aledbf/ingress-controller/pkg/ingress"
+	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/globalratelimit"
+	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/ratelimit"
 	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/rewrite"
 )
 
@@ -97,3 +100,120 @@ func TestBuildProxyPass(t *testing.T) {
 		}
 	}
 }
+
+func TestBuildRateLimit(t *testing.T) {
+	rl := ratelimit.RateLimit{
+		Connections: ratelimit.Zone{Name: "default_foo_conn", Limit: 5, Burst: 25, SharedSize: 5},
+		RPM:         ratelimit.Zone{Name: "default_foo_rpm", Limit: 60, Burst: 10, SharedSize: 5},
+		ID:          "default_foo.bar",
+		Key:         "$http_x_api_key",
+		Whitelist:   []string{"10.0.0.0/8"},
+		StatusCode:  429,
+	}
+
+	servers := []*ingress.Server{
+		{
+			Name: "foo.bar.com",
+			Locations: []*ingress.Location{
+				{Path: "/", RateLimit: rl},
+				{Path: "/api", RateLimit: rl},
+			},
+		},
+	}
+
+	zones := buildRateLimitZones(servers)
+	if len(zones) != 4 {
+		t.Fatalf("expected 4 entries (geo, map and two zones) but %v returned: %v", len(zones), zones)
+	}
+	if !strings.Contains(zones[0], "10.0.0.0/8 1;") {
+		t.Errorf("expected whitelist in geo block but returned %v", zones[0])
+	}
+	if !strings.Contains(zones[1], "0 $http_x_api_key;") {
+		t.Errorf("expected key in map block but returned %v", zones[1])
+	}
+	if zones[2] != "limit_conn_zone $limit_default_foo_bar zone=default_foo_conn:5m;" {
+		t.Errorf("unexpected connection zone %v", zones[2])
+	}
+	if zones[3] != "limit_req_zone $limit_default_foo_bar zone=default_foo_rpm:5m rate=60r/m;" {
+		t.Errorf("unexpected request zone %v", zones[3])
+	}
+
+	limits := buildRateLimit(servers[0].Locations[0])
+	expected := []string{
+		"limit_conn default_foo_conn 5;",
+		"limit_conn_status 429;",
+		"limit_req zone=default_foo_rpm burst=10 nodelay;",
+		"limit_req_status 429;",
+	}
+	if strings.Join(limits, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected \n'%v'\nbut returned \n'%v'", expected, limits)
+	}
+}
+
+func TestBuildGlobalRateLimit(t *testing.T) {
+	loc := &ingress.Location{}
+	if grl := buildGlobalRateLimit(loc, 429); grl != "" {
+		t.Errorf("expected no global rate limit but returned %v", grl)
+	}
+
+	loc.GlobalRateLimit = globalratelimit.Config{
+		Name:   "default_foo",
+		Limit:  100,
+		Window: 60,
+		Key:    "$http_x_api_key",
+	}
+	expected := `global_rate_limit.check("default_foo", ngx.var.http_x_api_key, 100, 60, 429)`
+	if grl := buildGlobalRateLimit(loc, 429); grl != expected {
+		t.Errorf("expected '%v' but returned '%v'", expected, grl)
+	}
+}
+
+func TestBuildCustomErrors(t *testing.T) {
+	global := []int{404, 503}
+
+	loc := &ingress.Location{Path: "/"}
+	if errs := buildCustomErrors(loc, global); len(errs) != 0 {
+		t.Errorf("expected no error pages but returned %v", errs)
+	}
+
+	loc.DefaultBackend = "default-errors-80"
+	expected := []string{
+		"proxy_intercept_errors on;",
+		"error_page 404 = @custom_default-errors-80_404;",
+		"error_page 503 = @custom_default-errors-80_503;",
+	}
+	if errs := buildCustomErrors(loc, global); !reflect.DeepEqual(errs, expected) {
+		t.Errorf("expected %v but returned %v", expected, errs)
+	}
+
+	api := &ingress.Location{Path: "/api", CustomHTTPErrors: []int{502}}
+	server := &ingress.Server{
+		Name:      "foo.bar.com",
+		Locations: []*ingress.Location{loc, api, {Path: "/static"}},
+	}
+
+	locations := buildCustomErrorLocations(server, global)
+	expectedLocations := []customErrorLocation{
+		{Name: "custom_404", Code: 404},
+		{Name: "custom_502", Code: 502},
+		{Name: "custom_503", Code: 503},
+		{Name: "custom_default-errors-80_404", Code: 404, Upstream: "default-errors-80"},
+		{Name: "custom_default-errors-80_503", Code: 503, Upstream: "default-errors-80"},
+	}
+	if !reflect.DeepEqual(locations, expectedLocations) {
+		t.Errorf("expected %v but returned %v", expectedLocations, locations)
+	}
+
+	locations = buildCustomErrorLocations(nil, global)
+	if len(locations) != 2 {
+		t.Errorf("expected only the global error pages but returned %v", locations)
+	}
+}
+
+func TestCleanConf(t *testing.T) {
+	in := "http {\n    \n\n\n    server {\n\t\n    }\n}\n"
+	expected := "http {\n\n    server {\n\n    }\n}\n"
+	if out := string(cleanConf([]byte(in))); out != expected {
+		t.Errorf("expected %q but returned %q", expected, out)
+	}
+}
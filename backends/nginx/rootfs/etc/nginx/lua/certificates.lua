@@ -0,0 +1,143 @@
+-- serves the SSL certificates sent by the ingress controller. The
+-- certificates (certificate chain and key in PEM format) are stored by
+-- host in the shared dict "certificates" so the changes in the
+-- certificates do not require a reload of NGINX
+local ssl = require "ngx.ssl"
+local cjson = require "cjson.safe"
+
+local certificates = ngx.shared.certificates
+
+local _M = {}
+
+-- parsed certificates and keys of this worker by key of the shared dict:
+-- {pem = <pem>, cert = <cdata>, key = <cdata>}. The shared dict can be
+-- updated from any worker, so an entry is parsed again when the PEM in
+-- the shared dict is not the PEM of the entry
+local parsed = {}
+
+-- returns the key in the shared dict and the certificate of the host or
+-- of the wildcard of the host
+local function get_certificate(host)
+    local pem = certificates:get(host)
+    if pem then
+        return host, pem
+    end
+
+    local wildcard = string.gsub(host, "^[^.]+", "*", 1)
+    if wildcard ~= host then
+        return wildcard, certificates:get(wildcard)
+    end
+end
+
+-- returns the parsed certificate and key of the PEM stored in the key of
+-- the shared dict, parsing the PEM only if it changed
+local function parse_certificate(name, pem)
+    local entry = parsed[name]
+    if entry and entry.pem == pem then
+        return entry.cert, entry.key
+    end
+
+    local cert, err = ssl.parse_pem_cert(pem)
+    if not cert then
+        return nil, nil, "error parsing certificate of " .. name .. ": " .. err
+    end
+    local key, err = ssl.parse_pem_priv_key(pem)
+    if not key then
+        return nil, nil, "error parsing private key of " .. name .. ": " .. err
+    end
+
+    parsed[name] = { pem = pem, cert = cert, key = key }
+    return cert, key
+end
+
+-- replaces the certificate of the SSL handshake with the certificate of
+-- the server name (SNI). Without a certificate in the shared dict the
+-- certificate configured in the server is used
+function _M.call()
+    local host, err = ssl.server_name()
+    if not host then
+        return
+    end
+
+    local name, pem = get_certificate(host)
+    if not pem then
+        if name then
+            parsed[name] = nil
+        end
+        parsed[host] = nil
+        return
+    end
+
+    local cert, key, err = parse_certificate(name, pem)
+    if not cert then
+        ngx.log(ngx.ERR, err)
+        return
+    end
+
+    local ok, err = ssl.clear_certs()
+    if not ok then
+        ngx.log(ngx.ERR, "error clearing certificates: ", err)
+        return
+    end
+
+    ok, err = ssl.set_cert(cert)
+    if not ok then
+        ngx.log(ngx.ERR, "error setting certificate of ", host, ": ", err)
+        return ngx.exit(ngx.ERROR)
+    end
+    ok, err = ssl.set_priv_key(key)
+    if not ok then
+        ngx.log(ngx.ERR, "error setting private key of ", host, ": ", err)
+        return ngx.exit(ngx.ERROR)
+    end
+end
+
+-- updates the certificates with the content of the request:
+-- {"certificates": {"<host>": "<pem>"}, "removed": ["<host>"]}
+function _M.update()
+    if ngx.req.get_method() ~= "POST" then
+        ngx.status = ngx.HTTP_NOT_ALLOWED
+        return ngx.exit(ngx.status)
+    end
+
+    ngx.req.read_body()
+    local body = ngx.req.get_body_data()
+    if not body then
+        local file = ngx.req.get_body_file()
+        if file then
+            local f = io.open(file, "r")
+            if f then
+                body = f:read("*a")
+                f:close()
+            end
+        end
+    end
+
+    local data = body and cjson.decode(body)
+    if type(data) ~= "table" then
+        ngx.status = ngx.HTTP_BAD_REQUEST
+        ngx.say("invalid request")
+        return ngx.exit(ngx.status)
+    end
+
+    for host, pem in pairs(data.certificates or {}) do
+        local ok, err = certificates:safe_set(host, pem)
+        if not ok then
+            ngx.log(ngx.ERR, "error storing certificate of ", host, ": ", err)
+            ngx.status = ngx.HTTP_INTERNAL_SERVER_ERROR
+            ngx.say("error storing certificate of ", host, ": ", err)
+            return ngx.exit(ngx.status)
+        end
+        parsed[host] = nil
+    end
+
+    for _, host in ipairs(data.removed or {}) do
+        certificates:delete(host)
+        parsed[host] = nil
+    end
+
+    ngx.status = ngx.HTTP_OK
+    ngx.say("ok")
+end
+
+return _M
@@ -8,10 +8,10 @@ local get_upstreams = upstream.get_upstreams
 local random = math.random
 local us = get_upstreams()
 
-function openURL(status)
+function openURL(status, upstream)
     local httpc = http.new()
 
-    local random_backend = get_destination()
+    local random_backend = get_destination(upstream or def_backend)
     local res, err = httpc:request_uri(random_backend, {
         path = "/",
         method = "GET",
@@ -34,9 +34,9 @@ function openURL(status)
     ngx.say(res.body)
 end
 
-function get_destination()
+function get_destination(backend)
     for _, u in ipairs(us) do
-        if u == def_backend then
+        if u == backend then
             local srvs, err = get_servers(u)
             local us_table = {}
             if not srvs then
@@ -50,6 +50,13 @@ function get_destination()
             return "http://"..destination
         end
     end
+
+    -- the upstream does not exists, use the default backend
+    if backend ~= def_backend then
+        return get_destination(def_backend)
+    end
+
+    return "http://127.0.0.1:8181"
 end
 
 function random_weight(tbl)
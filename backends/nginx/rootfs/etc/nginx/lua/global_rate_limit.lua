@@ -0,0 +1,47 @@
+-- checks the global rate limits using the endpoint in the ingress
+-- controller that keeps the counters shared between the replicas
+local http = require "resty.http"
+
+local _M = {}
+
+-- URL of the endpoint and maximum time in milliseconds to wait for a
+-- response, set from the configuration with configure
+local throttle_url
+local timeout
+
+function _M.configure(url, ms)
+    throttle_url = url
+    timeout = ms
+end
+
+-- checks if the request is allowed by a global rate limit. In case
+-- of error the request is allowed (the store is not available)
+function _M.check(name, key, limit, window, status)
+    if not key or key == "" then
+        return
+    end
+
+    local httpc = http.new()
+    httpc:set_timeout(timeout)
+
+    local res, err = httpc:request_uri(throttle_url, {
+        method = "GET",
+        query = {
+            name = name,
+            key = key,
+            limit = limit,
+            window = window,
+        }
+    })
+
+    if not res then
+        ngx.log(ngx.WARN, "error checking global rate limit ", name, ": ", err)
+        return
+    end
+
+    if res.status == 429 then
+        ngx.exit(status)
+    end
+end
+
+return _M
@@ -0,0 +1,106 @@
+-- sends the information of the requests to the ingress controller that
+-- exposes the data as prometheus metrics. The requests are buffered in
+-- each worker and sent each second in UDP datagrams (one JSON per line)
+local metrics_host = "127.0.0.1"
+local metrics_port = 10248
+
+-- maximum size of a datagram
+local max_payload_size = 65000
+-- maximum number of requests in the buffer. New requests are discarded
+-- if the ingress controller is not able to receive the data
+local max_buffer_size = 10000
+local flush_interval = 1
+
+local buffer = {}
+local flush_scheduled = false
+
+local function escape(value)
+    return string.gsub(value, '[%c"\\]', function(c)
+        return string.format("\\u%04x", string.byte(c))
+    end)
+end
+
+local function encode(data)
+    local fields = {}
+    for k, v in pairs(data) do
+        table.insert(fields, '"' .. k .. '":"' .. escape(tostring(v or "")) .. '"')
+    end
+    return "{" .. table.concat(fields, ",") .. "}"
+end
+
+local function send(sock, lines)
+    local ok, err = sock:send(table.concat(lines, "\n"))
+    if not ok then
+        ngx.log(ngx.WARN, "error sending request metrics: ", err)
+    end
+end
+
+local function flush(premature)
+    flush_scheduled = false
+    if #buffer == 0 then
+        return
+    end
+
+    local requests = buffer
+    buffer = {}
+
+    local sock = ngx.socket.udp()
+    local ok, err = sock:setpeername(metrics_host, metrics_port)
+    if not ok then
+        ngx.log(ngx.WARN, "error sending request metrics: ", err)
+        return
+    end
+
+    local lines = {}
+    local size = 0
+    for _, line in ipairs(requests) do
+        if size > 0 and size + #line + 1 > max_payload_size then
+            send(sock, lines)
+            lines = {}
+            size = 0
+        end
+        table.insert(lines, line)
+        size = size + #line + 1
+    end
+
+    if size > 0 then
+        send(sock, lines)
+    end
+    sock:close()
+end
+
+-- adds the current request to the buffer. Only the requests processed
+-- in locations defined by Ingress rules are included
+function request_metrics()
+    local path = ngx.var.location_path
+    if not path or path == "" then
+        return
+    end
+
+    if #buffer >= max_buffer_size then
+        return
+    end
+
+    table.insert(buffer, encode({
+        host = ngx.var.server_name,
+        path = path,
+        namespace = ngx.var.namespace,
+        ingress = ngx.var.ingress_name,
+        service = ngx.var.service_name,
+        upstream = ngx.var.proxy_upstream_name,
+        status = ngx.var.status,
+        requestTime = ngx.var.request_time,
+        upstreamResponseTime = ngx.var.upstream_response_time,
+        requestLength = ngx.var.request_length,
+        bytesSent = ngx.var.bytes_sent,
+    }))
+
+    if not flush_scheduled then
+        local ok, err = ngx.timer.at(flush_interval, flush)
+        if not ok then
+            ngx.log(ngx.WARN, "error scheduling the request metrics: ", err)
+            return
+        end
+        flush_scheduled = true
+    end
+end
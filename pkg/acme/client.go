@@ -0,0 +1,441 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package acme
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+const (
+	// status of orders, authorizations and challenges
+	statusPending    = "pending"
+	statusProcessing = "processing"
+	statusValid      = "valid"
+	statusInvalid    = "invalid"
+
+	errBadNonce = "urn:ietf:params:acme:error:badNonce"
+
+	challengeHTTP01 = "http-01"
+)
+
+var (
+	// interval between requests checking the status of an order or authorization
+	pollInterval = 2 * time.Second
+	// maximum time to wait for the validation of an order or authorization
+	pollTimeout = 2 * time.Minute
+)
+
+// ChallengeSolver makes the response of a HTTP-01 challenge available in
+// the URL http://<domain>/.well-known/acme-challenge/<token>
+type ChallengeSolver interface {
+	// Present makes the key authorization available for the token
+	Present(token, keyAuth string) error
+	// CleanUp removes the token after the validation
+	CleanUp(token string) error
+}
+
+// Error is a problem document returned by the ACME server (RFC 7807)
+type Error struct {
+	Type       string `json:"type"`
+	Detail     string `json:"detail"`
+	StatusCode int    `json:"status"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("acme error %v (%v): %v", e.StatusCode, e.Type, e.Detail)
+}
+
+// directory contains the URLs of the resources of the ACME server
+type directory struct {
+	NewNonce   string `json:"newNonce"`
+	NewAccount string `json:"newAccount"`
+	NewOrder   string `json:"newOrder"`
+}
+
+type identifier struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type order struct {
+	Status         string       `json:"status"`
+	Identifiers    []identifier `json:"identifiers"`
+	Authorizations []string     `json:"authorizations"`
+	Finalize       string       `json:"finalize"`
+	Certificate    string       `json:"certificate"`
+	Error          *Error       `json:"error"`
+}
+
+type challenge struct {
+	Type   string `json:"type"`
+	URL    string `json:"url"`
+	Token  string `json:"token"`
+	Status string `json:"status"`
+	Error  *Error `json:"error"`
+}
+
+type authorization struct {
+	Status     string      `json:"status"`
+	Identifier identifier  `json:"identifier"`
+	Challenges []challenge `json:"challenges"`
+}
+
+// Client is a minimal ACME (RFC 8555) client that obtains certificates
+// using HTTP-01 challenges
+type Client struct {
+	// DirectoryURL is the URL of the directory of the ACME server
+	DirectoryURL string
+	// Key is the key of the account
+	Key *ecdsa.PrivateKey
+	// HTTPClient is the client used in the requests to the ACME server
+	HTTPClient *http.Client
+
+	mu     sync.Mutex
+	dir    *directory
+	kid    string
+	nonces []string
+}
+
+// NewClient returns a client for the ACME server with the account key
+func NewClient(directoryURL string, key *ecdsa.PrivateKey) *Client {
+	return &Client{
+		DirectoryURL: directoryURL,
+		Key:          key,
+		HTTPClient:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// KeyAuthorization returns the response of a challenge with the token
+func (c *Client) KeyAuthorization(token string) string {
+	return token + "." + newJSONWebKey(c.Key).thumbprint()
+}
+
+// Register creates the account in the ACME server (or obtains the
+// existing account of the key) accepting the terms of service
+func (c *Client) Register(email string) error {
+	dir, err := c.directory()
+	if err != nil {
+		return err
+	}
+
+	req := map[string]interface{}{
+		"termsOfServiceAgreed": true,
+	}
+	if email != "" {
+		req["contact"] = []string{"mailto:" + email}
+	}
+
+	res, err := c.post(dir.NewAccount, req, true)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	kid := res.Header.Get("Location")
+	if kid == "" {
+		return fmt.Errorf("the ACME server did not return the account URL")
+	}
+
+	c.mu.Lock()
+	c.kid = kid
+	c.mu.Unlock()
+	return nil
+}
+
+// ObtainCertificate requests a certificate for the domains. The key is
+// used to create the certificate signing request. It returns the
+// certificate chain in PEM format
+func (c *Client) ObtainCertificate(domains []string, key crypto.Signer, solver ChallengeSolver) ([]byte, error) {
+	if len(domains) == 0 {
+		return nil, fmt.Errorf("the certificate requires at least one domain")
+	}
+
+	dir, err := c.directory()
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []identifier
+	for _, d := range domains {
+		ids = append(ids, identifier{Type: "dns", Value: d})
+	}
+
+	var o order
+	res, err := c.postJSON(dir.NewOrder, map[string]interface{}{"identifiers": ids}, &o)
+	if err != nil {
+		return nil, fmt.Errorf("error creating order: %v", err)
+	}
+	orderURL := res.Header.Get("Location")
+
+	for _, authzURL := range o.Authorizations {
+		err := c.authorize(authzURL, solver)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: domains[0]},
+		DNSNames: domains,
+	}, key)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = c.postJSON(o.Finalize, map[string]string{"csr": encode(csr)}, &o)
+	if err != nil {
+		return nil, fmt.Errorf("error finalizing order: %v", err)
+	}
+
+	err = poll(func() (bool, error) {
+		switch o.Status {
+		case statusValid:
+			return true, nil
+		case statusInvalid:
+			return false, fmt.Errorf("the order is invalid: %v", o.Error)
+		}
+		_, err := c.postJSON(orderURL, nil, &o)
+		return false, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res, err = c.post(o.Certificate, nil, false)
+	if err != nil {
+		return nil, fmt.Errorf("error downloading certificate: %v", err)
+	}
+	defer res.Body.Close()
+
+	return ioutil.ReadAll(res.Body)
+}
+
+// authorize completes the HTTP-01 challenge of an authorization
+func (c *Client) authorize(authzURL string, solver ChallengeSolver) error {
+	var authz authorization
+	if _, err := c.postJSON(authzURL, nil, &authz); err != nil {
+		return fmt.Errorf("error obtaining authorization: %v", err)
+	}
+
+	if authz.Status == statusValid {
+		return nil
+	}
+
+	var chal *challenge
+	for i := range authz.Challenges {
+		if authz.Challenges[i].Type == challengeHTTP01 {
+			chal = &authz.Challenges[i]
+			break
+		}
+	}
+	if chal == nil {
+		return fmt.Errorf("the ACME server does not support %v challenges for %v", challengeHTTP01, authz.Identifier.Value)
+	}
+
+	if err := solver.Present(chal.Token, c.KeyAuthorization(chal.Token)); err != nil {
+		return err
+	}
+	defer func() {
+		if err := solver.CleanUp(chal.Token); err != nil {
+			glog.Warningf("error removing ACME challenge %v: %v", chal.Token, err)
+		}
+	}()
+
+	glog.V(2).Infof("accepting %v challenge for %v", challengeHTTP01, authz.Identifier.Value)
+	if _, err := c.postJSON(chal.URL, struct{}{}, chal); err != nil {
+		return fmt.Errorf("error accepting challenge: %v", err)
+	}
+
+	return poll(func() (bool, error) {
+		switch authz.Status {
+		case statusValid:
+			return true, nil
+		case statusInvalid:
+			for _, ch := range authz.Challenges {
+				if ch.Error != nil {
+					return false, fmt.Errorf("the authorization of %v is invalid: %v", authz.Identifier.Value, ch.Error)
+				}
+			}
+			return false, fmt.Errorf("the authorization of %v is invalid", authz.Identifier.Value)
+		}
+		_, err := c.postJSON(authzURL, nil, &authz)
+		return false, err
+	})
+}
+
+// poll executes the condition until it returns true or an error
+func poll(condition func() (bool, error)) error {
+	deadline := time.Now().Add(pollTimeout)
+	for {
+		done, err := condition()
+		if err != nil || done {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timeout waiting for the ACME server")
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+func (c *Client) directory() (*directory, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.dir != nil {
+		return c.dir, nil
+	}
+
+	res, err := c.HTTPClient.Get(c.DirectoryURL)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %v obtaining the ACME directory", res.StatusCode)
+	}
+
+	var dir directory
+	if err := json.NewDecoder(res.Body).Decode(&dir); err != nil {
+		return nil, fmt.Errorf("invalid ACME directory: %v", err)
+	}
+	c.dir = &dir
+	return c.dir, nil
+}
+
+// nonce returns a nonce received in a previous response or a new one
+func (c *Client) nonce() (string, error) {
+	c.mu.Lock()
+	if n := len(c.nonces); n > 0 {
+		nonce := c.nonces[n-1]
+		c.nonces = c.nonces[:n-1]
+		c.mu.Unlock()
+		return nonce, nil
+	}
+	c.mu.Unlock()
+
+	dir, err := c.directory()
+	if err != nil {
+		return "", err
+	}
+
+	res, err := c.HTTPClient.Head(dir.NewNonce)
+	if err != nil {
+		return "", err
+	}
+	res.Body.Close()
+
+	nonce := res.Header.Get("Replay-Nonce")
+	if nonce == "" {
+		return "", fmt.Errorf("the ACME server did not return a nonce")
+	}
+	return nonce, nil
+}
+
+func (c *Client) addNonce(res *http.Response) {
+	nonce := res.Header.Get("Replay-Nonce")
+	if nonce == "" {
+		return
+	}
+	c.mu.Lock()
+	c.nonces = append(c.nonces, nonce)
+	c.mu.Unlock()
+}
+
+// postJSON sends a signed request decoding the response in v
+func (c *Client) postJSON(url string, payload, v interface{}) (*http.Response, error) {
+	res, err := c.post(url, payload, false)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return nil, fmt.Errorf("invalid response from %v: %v", url, err)
+	}
+	return res, nil
+}
+
+// post sends a request signed with the account key. A nil payload is
+// a POST-as-GET request. The request is sent again if the nonce is
+// rejected. The body of the response must be closed
+func (c *Client) post(url string, payload interface{}, useJWK bool) (*http.Response, error) {
+	for retry := 0; ; retry++ {
+		nonce, err := c.nonce()
+		if err != nil {
+			return nil, err
+		}
+
+		header := protectedHeader{
+			Nonce: nonce,
+			URL:   url,
+		}
+		if useJWK {
+			jwk := newJSONWebKey(c.Key)
+			header.JWK = &jwk
+		} else {
+			c.mu.Lock()
+			header.KID = c.kid
+			c.mu.Unlock()
+			if header.KID == "" {
+				return nil, fmt.Errorf("the ACME account is not registered")
+			}
+		}
+
+		body, err := signJWS(c.Key, header, payload)
+		if err != nil {
+			return nil, err
+		}
+
+		req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/jose+json")
+
+		res, err := c.HTTPClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		c.addNonce(res)
+
+		if res.StatusCode < 400 {
+			return res, nil
+		}
+
+		acmeErr := &Error{StatusCode: res.StatusCode}
+		json.NewDecoder(res.Body).Decode(acmeErr)
+		res.Body.Close()
+
+		if acmeErr.Type == errBadNonce && retry < 3 {
+			continue
+		}
+		return nil, acmeErr
+	}
+}
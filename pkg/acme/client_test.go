@@ -0,0 +1,326 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package acme
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer is an ACME server that validates the HTTP-01 challenges
+// using the solver of the client and issues self-signed certificates
+type fakeServer struct {
+	t      *testing.T
+	srv    *httptest.Server
+	solver *HTTP01Solver
+
+	mu      sync.Mutex
+	key     *ecdsa.PublicKey
+	nonce   int
+	nonces  map[string]bool
+	badOnce bool
+	domains []string
+	authz   map[string]string
+	cert    []byte
+}
+
+func newFakeServer(t *testing.T, solver *HTTP01Solver) *fakeServer {
+	s := &fakeServer{
+		t:      t,
+		solver: solver,
+		nonces: map[string]bool{},
+		authz:  map[string]string{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/directory", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(directory{
+			NewNonce:   s.srv.URL + "/new-nonce",
+			NewAccount: s.srv.URL + "/new-account",
+			NewOrder:   s.srv.URL + "/new-order",
+		})
+	})
+	mux.HandleFunc("/new-nonce", func(w http.ResponseWriter, r *http.Request) {
+		s.addNonce(w)
+	})
+	mux.HandleFunc("/new-account", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := s.verify(w, r); !ok {
+			return
+		}
+		w.Header().Set("Location", s.srv.URL+"/account/1")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("{}"))
+	})
+	mux.HandleFunc("/new-order", func(w http.ResponseWriter, r *http.Request) {
+		payload, ok := s.verify(w, r)
+		if !ok {
+			return
+		}
+		var req struct {
+			Identifiers []identifier `json:"identifiers"`
+		}
+		json.Unmarshal(payload, &req)
+
+		o := order{Status: statusPending, Finalize: s.srv.URL + "/finalize"}
+		s.mu.Lock()
+		s.domains = nil
+		for _, id := range req.Identifiers {
+			s.domains = append(s.domains, id.Value)
+			s.authz[id.Value] = statusPending
+			o.Authorizations = append(o.Authorizations, s.srv.URL+"/authz/"+id.Value)
+		}
+		s.mu.Unlock()
+
+		w.Header().Set("Location", s.srv.URL+"/order")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(o)
+	})
+	mux.HandleFunc("/authz/", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := s.verify(w, r); !ok {
+			return
+		}
+		domain := r.URL.Path[len("/authz/"):]
+		s.mu.Lock()
+		status := s.authz[domain]
+		s.mu.Unlock()
+		json.NewEncoder(w).Encode(authorization{
+			Status:     status,
+			Identifier: identifier{Type: "dns", Value: domain},
+			Challenges: []challenge{
+				{Type: "dns-01", URL: s.srv.URL + "/dns/" + domain, Token: "dns-" + domain, Status: statusPending},
+				{Type: challengeHTTP01, URL: s.srv.URL + "/challenge/" + domain, Token: "token-" + domain, Status: statusPending},
+			},
+		})
+	})
+	mux.HandleFunc("/challenge/", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := s.verify(w, r); !ok {
+			return
+		}
+		domain := r.URL.Path[len("/challenge/"):]
+		token := "token-" + domain
+
+		status := statusInvalid
+		keyAuth, _ := s.solver.KeyAuthorization(token)
+		if keyAuth == token+"."+newJSONWebKey(&ecdsa.PrivateKey{PublicKey: *s.key}).thumbprint() {
+			status = statusValid
+		}
+		s.mu.Lock()
+		s.authz[domain] = status
+		s.mu.Unlock()
+		json.NewEncoder(w).Encode(challenge{Type: challengeHTTP01, Token: token, Status: status})
+	})
+	mux.HandleFunc("/finalize", func(w http.ResponseWriter, r *http.Request) {
+		payload, ok := s.verify(w, r)
+		if !ok {
+			return
+		}
+		var req struct {
+			CSR string `json:"csr"`
+		}
+		json.Unmarshal(payload, &req)
+		der, _ := decode(req.CSR)
+		csr, err := x509.ParseCertificateRequest(der)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.issue(csr)
+		json.NewEncoder(w).Encode(order{Status: statusProcessing})
+	})
+	mux.HandleFunc("/order", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := s.verify(w, r); !ok {
+			return
+		}
+		json.NewEncoder(w).Encode(order{Status: statusValid, Certificate: s.srv.URL + "/cert"})
+	})
+	mux.HandleFunc("/cert", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := s.verify(w, r); !ok {
+			return
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		w.Write(s.cert)
+	})
+
+	s.srv = httptest.NewServer(mux)
+	return s
+}
+
+func (s *fakeServer) addNonce(w http.ResponseWriter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nonce++
+	nonce := fmt.Sprintf("nonce-%v", s.nonce)
+	s.nonces[nonce] = true
+	w.Header().Set("Replay-Nonce", nonce)
+}
+
+// verify checks the signature and the nonce of a request and returns the payload
+func (s *fakeServer) verify(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	defer s.addNonce(w)
+
+	var msg jws
+	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	h, _ := decode(msg.Protected)
+	var header protectedHeader
+	json.Unmarshal(h, &header)
+
+	s.mu.Lock()
+	validNonce := s.nonces[header.Nonce]
+	delete(s.nonces, header.Nonce)
+	rejectNonce := s.badOnce
+	s.badOnce = false
+	if header.JWK != nil {
+		x, _ := decode(header.JWK.X)
+		y, _ := decode(header.JWK.Y)
+		s.key = &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	} else if header.KID != s.srv.URL+"/account/1" {
+		s.t.Errorf("unexpected kid %v", header.KID)
+	}
+	key := s.key
+	s.mu.Unlock()
+
+	if !validNonce || rejectNonce {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(Error{Type: errBadNonce, Detail: "invalid nonce"})
+		return nil, false
+	}
+
+	if header.Alg != "ES256" || header.URL != s.srv.URL+r.URL.Path {
+		s.t.Errorf("unexpected header %+v for %v", header, r.URL.Path)
+	}
+
+	sig, _ := decode(msg.Signature)
+	digest := sha256.Sum256([]byte(msg.Protected + "." + msg.Payload))
+	if len(sig) != 64 || !ecdsa.Verify(key, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	payload, _ := decode(msg.Payload)
+	return payload, true
+}
+
+func (s *fakeServer) issue(csr *x509.CertificateRequest) {
+	key, _ := rsa.GenerateKey(rand.Reader, 1024)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "fake ACME CA"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+		DNSNames:     csr.DNSNames,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, csr.PublicKey, key)
+	if err != nil {
+		s.t.Fatalf("unexpected error issuing certificate: %v", err)
+	}
+	s.mu.Lock()
+	s.cert = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	s.mu.Unlock()
+}
+
+func TestObtainCertificate(t *testing.T) {
+	defer func(i time.Duration) { pollInterval = i }(pollInterval)
+	pollInterval = 10 * time.Millisecond
+
+	solver := NewHTTP01Solver()
+	fs := newFakeServer(t, solver)
+	defer fs.srv.Close()
+
+	key, err := NewAccountKey()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c := NewClient(fs.srv.URL+"/directory", key)
+
+	_, err = c.ObtainCertificate([]string{"foo.bar"}, key, solver)
+	if err == nil {
+		t.Errorf("expected an error using an account not registered")
+	}
+
+	if err := c.Register("admin@foo.bar"); err != nil {
+		t.Fatalf("unexpected error registering the account: %v", err)
+	}
+
+	// the first signed request is rejected and must be sent again
+	fs.badOnce = true
+
+	certKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+	domains := []string{"foo.bar", "www.foo.bar"}
+	data, err := c.ObtainCertificate(domains, certKey, solver)
+	if err != nil {
+		t.Fatalf("unexpected error obtaining certificate: %v", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		t.Fatalf("expected a certificate in PEM format but returned %s", data)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("unexpected error parsing certificate: %v", err)
+	}
+	if !reflect.DeepEqual(cert.DNSNames, domains) {
+		t.Errorf("expected %v as names of the certificate but returned %v", domains, cert.DNSNames)
+	}
+
+	for _, d := range domains {
+		if _, ok := solver.KeyAuthorization("token-" + d); ok {
+			t.Errorf("expected the challenge of %v to be removed after the validation", d)
+		}
+	}
+}
+
+func TestObtainCertificateInvalidChallenge(t *testing.T) {
+	defer func(i time.Duration) { pollInterval = i }(pollInterval)
+	pollInterval = 10 * time.Millisecond
+
+	fs := newFakeServer(t, NewHTTP01Solver())
+	defer fs.srv.Close()
+
+	key, _ := NewAccountKey()
+	c := NewClient(fs.srv.URL+"/directory", key)
+	if err := c.Register(""); err != nil {
+		t.Fatalf("unexpected error registering the account: %v", err)
+	}
+
+	// the challenge is presented in a solver not used by the server
+	_, err := c.ObtainCertificate([]string{"foo.bar"}, key, NewHTTP01Solver())
+	if err == nil {
+		t.Errorf("expected an error with an invalid challenge")
+	}
+}
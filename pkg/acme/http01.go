@@ -0,0 +1,81 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package acme
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// ChallengePath is the path of the URL used to validate HTTP-01 challenges
+const ChallengePath = "/.well-known/acme-challenge/"
+
+// HTTP01Solver keeps the responses of the HTTP-01 challenges in memory
+// and serves them in the path ChallengePath
+type HTTP01Solver struct {
+	mu     sync.RWMutex
+	tokens map[string]string
+}
+
+// NewHTTP01Solver returns an empty HTTP01Solver
+func NewHTTP01Solver() *HTTP01Solver {
+	return &HTTP01Solver{
+		tokens: map[string]string{},
+	}
+}
+
+// Present implements ChallengeSolver
+func (s *HTTP01Solver) Present(token, keyAuth string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[token] = keyAuth
+	return nil
+}
+
+// CleanUp implements ChallengeSolver
+func (s *HTTP01Solver) CleanUp(token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tokens, token)
+	return nil
+}
+
+// KeyAuthorization returns the response of the challenge with the token
+func (s *HTTP01Solver) KeyAuthorization(token string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keyAuth, ok := s.tokens[token]
+	return keyAuth, ok
+}
+
+// ServeHTTP returns the key authorization of the token in the URL
+func (s *HTTP01Solver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, ChallengePath) {
+		http.NotFound(w, r)
+		return
+	}
+
+	keyAuth, ok := s.KeyAuthorization(strings.TrimPrefix(r.URL.Path, ChallengePath))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte(keyAuth))
+}
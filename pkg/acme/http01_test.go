@@ -0,0 +1,54 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package acme
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTP01Solver(t *testing.T) {
+	s := NewHTTP01Solver()
+	s.Present("abc", "abc.thumbprint")
+
+	tests := map[string]struct {
+		path string
+		code int
+		body string
+	}{
+		"valid token":   {ChallengePath + "abc", http.StatusOK, "abc.thumbprint"},
+		"unknown token": {ChallengePath + "xyz", http.StatusNotFound, ""},
+		"invalid path":  {"/abc", http.StatusNotFound, ""},
+	}
+
+	for title, tc := range tests {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", tc.path, nil))
+		if w.Code != tc.code {
+			t.Errorf("%v: expected status code %v but returned %v", title, tc.code, w.Code)
+		}
+		if tc.body != "" && w.Body.String() != tc.body {
+			t.Errorf("%v: expected %v but returned %v", title, tc.body, w.Body.String())
+		}
+	}
+
+	s.CleanUp("abc")
+	if _, ok := s.KeyAuthorization("abc"); ok {
+		t.Errorf("expected the token to be removed")
+	}
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package acme
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+// jsonWebKey is the public key of an account in JWK format (RFC 7517).
+// Only ECDSA P-256 keys are supported
+type jsonWebKey struct {
+	Crv string `json:"crv"`
+	Kty string `json:"kty"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func newJSONWebKey(key *ecdsa.PrivateKey) jsonWebKey {
+	return jsonWebKey{
+		Crv: "P-256",
+		Kty: "EC",
+		X:   encode(padBytes(key.X.Bytes(), 32)),
+		Y:   encode(padBytes(key.Y.Bytes(), 32)),
+	}
+}
+
+// thumbprint returns the JWK thumbprint (RFC 7638) of the key. The
+// members are sorted in lexicographic order and without spaces
+func (k jsonWebKey) thumbprint() string {
+	jwk := fmt.Sprintf(`{"crv":"%v","kty":"%v","x":"%v","y":"%v"}`, k.Crv, k.Kty, k.X, k.Y)
+	sum := sha256.Sum256([]byte(jwk))
+	return encode(sum[:])
+}
+
+// protectedHeader is the header of the JWS messages sent to the ACME server.
+// Only one of jwk (new accounts) or kid (existing accounts) is used
+type protectedHeader struct {
+	Alg   string      `json:"alg"`
+	Nonce string      `json:"nonce"`
+	URL   string      `json:"url"`
+	JWK   *jsonWebKey `json:"jwk,omitempty"`
+	KID   string      `json:"kid,omitempty"`
+}
+
+// jws is a JSON Web Signature with the flattened JSON serialization
+type jws struct {
+	Protected string `json:"protected"`
+	Payload   string `json:"payload"`
+	Signature string `json:"signature"`
+}
+
+// signJWS returns the payload signed using ES256. A nil payload
+// returns an empty payload (POST-as-GET requests)
+func signJWS(key *ecdsa.PrivateKey, header protectedHeader, payload interface{}) ([]byte, error) {
+	header.Alg = "ES256"
+	h, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+
+	var p []byte
+	if payload != nil {
+		p, err = json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	msg := jws{
+		Protected: encode(h),
+		Payload:   encode(p),
+	}
+
+	digest := sha256.Sum256([]byte(msg.Protected + "." + msg.Payload))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	msg.Signature = encode(append(padBytes(r.Bytes(), 32), padBytes(s.Bytes(), 32)...))
+
+	return json.Marshal(msg)
+}
+
+// NewAccountKey returns a new key for an ACME account
+func NewAccountKey() (*ecdsa.PrivateKey, error) {
+	return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+}
+
+// padBytes adds leading zeros until the slice has the specified size
+func padBytes(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+	return append(make([]byte, size-len(b)), b...)
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
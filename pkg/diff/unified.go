@@ -0,0 +1,301 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+const (
+	// contextLines number of unchanged lines around a change in a hunk
+	contextLines = 3
+
+	// maxEditSteps limits the work to split a changed range. Ranges that
+	// need more steps are reported as removed and inserted entirely
+	maxEditSteps = 1000
+)
+
+type operation int
+
+const (
+	equal operation = iota
+	insert
+	remove
+)
+
+// edit describes an operation in a line. aPos and bPos are the position
+// of the line in the original and the new content
+type edit struct {
+	op   operation
+	aPos int
+	bPos int
+	line string
+}
+
+// Unified returns the differences between two texts in unified format
+// (like diff -u). An empty string means the texts are equal
+func Unified(a, b []byte, aName, bName string) string {
+	if bytes.Equal(a, b) {
+		return ""
+	}
+
+	edits := lineEdits(splitLines(a), splitLines(b))
+
+	out := &bytes.Buffer{}
+	fmt.Fprintf(out, "--- %v\n+++ %v\n", aName, bName)
+	for _, h := range hunks(edits) {
+		writeHunk(out, edits[h[0]:h[1]])
+	}
+
+	return out.String()
+}
+
+func splitLines(b []byte) []string {
+	if len(b) == 0 {
+		return []string{}
+	}
+
+	lines := strings.Split(string(b), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// lineEdits returns the operations required to transform a in b
+func lineEdits(a, b []string) []edit {
+	size := 2*maxEditSteps + 2
+	if n := len(a) + len(b) + 3; n < size {
+		size = n
+	}
+
+	s := &splitter{
+		forward:  make([]int, size),
+		backward: make([]int, size),
+	}
+	return s.edits(make([]edit, 0, len(a)+len(b)), a, b, 0, 0)
+}
+
+// splitter computes the edits using the linear space refinement of the
+// algorithm described in "An O(ND) Difference Algorithm and Its Variations".
+// The content is split in the middle of the shortest edit script and each
+// half is solved recursively, reusing the same buffers
+type splitter struct {
+	forward  []int
+	backward []int
+}
+
+// edits appends to e the operations required to transform a in b.
+// aOff and bOff are the positions of a and b in the original content
+func (s *splitter) edits(e []edit, a, b []string, aOff, bOff int) []edit {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		e = append(e, edit{equal, aOff + prefix, bOff + prefix, a[prefix]})
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix &&
+		a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ca, cb := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	aOff, bOff = aOff+prefix, bOff+prefix
+
+	switch {
+	case len(ca) == 0:
+		for i, line := range cb {
+			e = append(e, edit{insert, aOff, bOff + i, line})
+		}
+	case len(cb) == 0:
+		for i, line := range ca {
+			e = append(e, edit{remove, aOff + i, bOff, line})
+		}
+	default:
+		x, y := s.middle(ca, cb)
+		e = s.edits(e, ca[:x], cb[:y], aOff, bOff)
+		e = s.edits(e, ca[x:], cb[y:], aOff+x, bOff+y)
+	}
+
+	for i := 0; i < suffix; i++ {
+		e = append(e, edit{equal, aOff + len(ca) + i, bOff + len(cb) + i, a[len(a)-suffix+i]})
+	}
+
+	return e
+}
+
+// middle returns a point of the shortest edit script between a and b
+// where the content can be split. a and b must not be empty and must
+// differ in the first and last line. If the point cannot be found in
+// maxEditSteps the whole range is reported as replaced
+func (s *splitter) middle(a, b []string) (int, int) {
+	n, m := len(a), len(b)
+	maxD := (n + m + 1) / 2
+	if maxD > maxEditSteps {
+		maxD = maxEditSteps
+	}
+
+	offset := maxD
+	forward := s.forward[:2*maxD+2]
+	backward := s.backward[:2*maxD+2]
+	for i := range forward {
+		forward[i] = -1
+		backward[i] = -1
+	}
+	forward[offset+1] = 0
+	backward[offset+1] = 0
+
+	delta := n - m
+	odd := delta%2 != 0
+
+	// k ranges that went outside the edit graph
+	var fStart, fEnd, bStart, bEnd int
+
+	for d := 0; d < maxD; d++ {
+		for k := -d + fStart; k <= d-fEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			forward[offset+k] = x
+
+			switch {
+			case x > n:
+				fEnd += 2
+			case y > m:
+				fStart += 2
+			case odd:
+				i := offset + delta - k
+				if i >= 0 && i < len(backward) && backward[i] != -1 && x >= n-backward[i] {
+					return split(x, y, n, m)
+				}
+			}
+		}
+
+		for k := -d + bStart; k <= d-bEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && backward[offset+k-1] < backward[offset+k+1]) {
+				x = backward[offset+k+1]
+			} else {
+				x = backward[offset+k-1] + 1
+			}
+
+			y := x - k
+			for x < n && y < m && a[n-x-1] == b[m-y-1] {
+				x++
+				y++
+			}
+			backward[offset+k] = x
+
+			switch {
+			case x > n:
+				bEnd += 2
+			case y > m:
+				bStart += 2
+			case !odd:
+				i := offset + delta - k
+				if i >= 0 && i < len(forward) && forward[i] != -1 && forward[i] >= n-x {
+					fx := forward[i]
+					return split(fx, fx-(i-offset), n, m)
+				}
+			}
+		}
+	}
+
+	return n, 0
+}
+
+// split returns the point x, y unless it does not reduce the content to
+// split, in which case the whole range is reported as replaced
+func split(x, y, n, m int) (int, int) {
+	if (x == 0 && y == 0) || (x == n && y == m) {
+		return n, 0
+	}
+	return x, y
+}
+
+// hunks returns the ranges of the edits that contains changes
+// including the context lines
+func hunks(edits []edit) [][2]int {
+	ranges := [][2]int{}
+	for i, e := range edits {
+		if e.op == equal {
+			continue
+		}
+
+		start := i - contextLines
+		if start < 0 {
+			start = 0
+		}
+		end := i + contextLines + 1
+		if end > len(edits) {
+			end = len(edits)
+		}
+
+		if len(ranges) > 0 && start <= ranges[len(ranges)-1][1] {
+			ranges[len(ranges)-1][1] = end
+			continue
+		}
+		ranges = append(ranges, [2]int{start, end})
+	}
+
+	return ranges
+}
+
+func writeHunk(out *bytes.Buffer, edits []edit) {
+	var aLen, bLen int
+	for _, e := range edits {
+		if e.op != insert {
+			aLen++
+		}
+		if e.op != remove {
+			bLen++
+		}
+	}
+
+	aStart := edits[0].aPos + 1
+	if aLen == 0 {
+		aStart--
+	}
+	bStart := edits[0].bPos + 1
+	if bLen == 0 {
+		bStart--
+	}
+
+	fmt.Fprintf(out, "@@ -%v,%v +%v,%v @@\n", aStart, aLen, bStart, bLen)
+	for _, e := range edits {
+		switch e.op {
+		case equal:
+			fmt.Fprintf(out, " %v\n", e.line)
+		case insert:
+			fmt.Fprintf(out, "+%v\n", e.line)
+		case remove:
+			fmt.Fprintf(out, "-%v\n", e.line)
+		}
+	}
+}
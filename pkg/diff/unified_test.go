@@ -0,0 +1,99 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	if d := Unified([]byte("a\nb\n"), []byte("a\nb\n"), "a", "b"); d != "" {
+		t.Errorf("expected no differences but returned %v", d)
+	}
+
+	tests := []struct {
+		a        string
+		b        string
+		expected string
+	}{
+		{
+			"a\nb\nc\n",
+			"a\nc\nd\n",
+			"--- a\n+++ b\n@@ -1,3 +1,3 @@\n a\n-b\n c\n+d\n",
+		},
+		{
+			"",
+			"a\n",
+			"--- a\n+++ b\n@@ -0,0 +1,1 @@\n+a\n",
+		},
+		{
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			"0\n1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n12\n",
+			"--- a\n+++ b\n@@ -1,3 +1,4 @@\n+0\n 1\n 2\n 3\n@@ -8,5 +9,4 @@\n 8\n 9\n 10\n-11\n 12\n",
+		},
+	}
+
+	for _, test := range tests {
+		d := Unified([]byte(test.a), []byte(test.b), "a", "b")
+		if d != test.expected {
+			t.Errorf("expected \n%v\nbut returned \n%v", test.expected, d)
+		}
+	}
+}
+
+func TestUnifiedLargeInput(t *testing.T) {
+	lines := func(format string, n int) []byte {
+		b := &bytes.Buffer{}
+		for i := 0; i < n; i++ {
+			fmt.Fprintf(b, format, i)
+		}
+		return b.Bytes()
+	}
+
+	current := lines("server_name host-%v.example.com;\n", 10000)
+	changed := lines("server_name host-%v.example.org;\n", 10000)
+	edited := []byte(strings.Replace(string(current), "host-5000.", "host-x.", 1))
+
+	tests := []struct {
+		name string
+		a    []byte
+		b    []byte
+	}{
+		{"empty previous", []byte{}, current},
+		{"empty current", current, []byte{}},
+		{"every line changed", current, changed},
+		{"one line changed", current, edited},
+	}
+
+	for _, test := range tests {
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		d := Unified(test.a, test.b, "a", "b")
+		runtime.ReadMemStats(&after)
+
+		if d == "" {
+			t.Errorf("%v: expected differences but none returned", test.name)
+		}
+		if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 16<<20 {
+			t.Errorf("%v: expected less than 16MB allocated but %v bytes were used", test.name, allocated)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package errorpage
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/golang/glog"
+)
+
+const (
+	// CodeHeader name of the header that contains the HTTP code of the error page
+	CodeHeader = "X-Code"
+	// FormatHeader name of the header that contains the format of the error page
+	FormatHeader = "X-Format"
+
+	defFormat = "html"
+	defCode   = http.StatusNotFound
+)
+
+var (
+	// contentTypes maps the supported formats to the content type of the response
+	contentTypes = map[string]string{
+		"html": "text/html",
+		"json": "application/json",
+		"xml":  "application/xml",
+		"text": "text/plain",
+	}
+
+	defTemplates = map[string]string{
+		"html": `<html>
+<head><title>{{ .Code }} {{ .Message }}</title></head>
+<body>
+<center><h1>{{ .Code }} {{ .Message }}</h1></center>
+</body>
+</html>
+`,
+		"json": `{"code": {{ .Code }}, "message": "{{ .Message }}"}
+`,
+		"xml": `<?xml version="1.0" encoding="UTF-8"?>
+<error><code>{{ .Code }}</code><message>{{ .Message }}</message></error>
+`,
+		"text": `{{ .Code }} {{ .Message }}
+`,
+	}
+)
+
+// page contains the information available in the templates of the error pages
+type page struct {
+	Code    int
+	Message string
+}
+
+// Server is a default backend that returns error pages in the format
+// requested by the client. The HTTP code of the page is obtained from
+// the header X-Code (404 if the header is not present) and the format from
+// the header X-Format or the Accept header (html if not present).
+// The templates can be customized using the keys <format> or
+// <code>.<format> (ie. 404.html) with the format of text/template.
+type Server struct {
+	mu        sync.RWMutex
+	templates map[string]*template.Template
+}
+
+// NewServer returns a default backend that uses the default templates
+func NewServer() *Server {
+	s := &Server{}
+	s.Update(nil)
+	return s
+}
+
+// Update replaces the custom templates of the error pages. Templates that
+// cannot be parsed are ignored
+func (s *Server) Update(data map[string]string) {
+	templates := map[string]*template.Template{}
+	for format, content := range defTemplates {
+		templates[format] = template.Must(template.New(format).Parse(content))
+	}
+
+	for key, content := range data {
+		if !isValidKey(key) {
+			glog.Warningf("ignoring error page template %v: invalid name. Must be <format> or <code>.<format>", key)
+			continue
+		}
+
+		tmpl, err := template.New(key).Parse(content)
+		if err != nil {
+			glog.Warningf("ignoring error page template %v: %v", key, err)
+			continue
+		}
+		templates[key] = tmpl
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.templates = templates
+}
+
+// ServeHTTP returns the error page for the code and format of the request
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	code := defCode
+	if c, err := strconv.Atoi(r.Header.Get(CodeHeader)); err == nil && c >= 300 && c < 600 {
+		code = c
+	}
+
+	format := requestFormat(r)
+
+	s.mu.RLock()
+	tmpl, ok := s.templates[fmt.Sprintf("%v.%v", code, format)]
+	if !ok {
+		tmpl = s.templates[format]
+	}
+	s.mu.RUnlock()
+
+	buf := &bytes.Buffer{}
+	err := tmpl.Execute(buf, page{Code: code, Message: http.StatusText(code)})
+	if err != nil {
+		glog.Warningf("unexpected error rendering error page %v: %v", tmpl.Name(), err)
+		http.Error(w, http.StatusText(code), code)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentTypes[format])
+	w.WriteHeader(code)
+	w.Write(buf.Bytes())
+}
+
+// requestFormat returns the format of the error page using the header X-Format
+// (a format or content type) or the Accept header of the request
+func requestFormat(r *http.Request) string {
+	if f := r.Header.Get(FormatHeader); f != "" {
+		if _, ok := contentTypes[f]; ok {
+			return f
+		}
+		if f := formatFromContentType(f); f != "" {
+			return f
+		}
+	}
+
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		if f := formatFromContentType(accept); f != "" {
+			return f
+		}
+	}
+
+	return defFormat
+}
+
+func formatFromContentType(ct string) string {
+	mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(ct))
+	if err != nil {
+		return ""
+	}
+
+	for format, t := range contentTypes {
+		if t == mediaType {
+			return format
+		}
+	}
+
+	return ""
+}
+
+// isValidKey checks the name of a template is <format> or <code>.<format>
+func isValidKey(key string) bool {
+	parts := strings.Split(key, ".")
+	if _, ok := contentTypes[parts[len(parts)-1]]; !ok {
+		return false
+	}
+
+	switch len(parts) {
+	case 1:
+		return true
+	case 2:
+		code, err := strconv.Atoi(parts[0])
+		return err == nil && code >= 300 && code < 600
+	default:
+		return false
+	}
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package errorpage
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServeHTTP(t *testing.T) {
+	s := NewServer()
+
+	tests := []struct {
+		headers     map[string]string
+		code        int
+		contentType string
+		body        string
+	}{
+		{map[string]string{}, 404, "text/html", "<h1>404 Not Found</h1>"},
+		{map[string]string{CodeHeader: "503", FormatHeader: "json"}, 503, "application/json", `"code": 503`},
+		{map[string]string{CodeHeader: "502", FormatHeader: "text/plain"}, 502, "text/plain", "502 Bad Gateway"},
+		{map[string]string{CodeHeader: "abc", "Accept": "text/foo, application/xml;q=0.9"}, 404, "application/xml", "<code>404</code>"},
+		{map[string]string{CodeHeader: "200"}, 404, "text/html", "404 Not Found"},
+	}
+
+	for _, test := range tests {
+		req, _ := http.NewRequest("GET", "/", nil)
+		for k, v := range test.headers {
+			req.Header.Set(k, v)
+		}
+
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+
+		if w.Code != test.code {
+			t.Errorf("expected code %v but returned %v (%v)", test.code, w.Code, test.headers)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != test.contentType {
+			t.Errorf("expected content type %v but returned %v (%v)", test.contentType, ct, test.headers)
+		}
+		body, _ := ioutil.ReadAll(w.Body)
+		if !strings.Contains(string(body), test.body) {
+			t.Errorf("expected %v in the body but returned %v (%v)", test.body, string(body), test.headers)
+		}
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	s := NewServer()
+	s.Update(map[string]string{
+		"html":      "custom {{ .Code }}",
+		"503.html":  "maintenance",
+		"invalid":   "invalid",
+		"404.png":   "invalid",
+		"json":      "{{ .Code ",
+		"1000.html": "invalid",
+	})
+
+	check := func(code, expected string) {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set(CodeHeader, code)
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		body, _ := ioutil.ReadAll(w.Body)
+		if !strings.HasPrefix(string(body), expected) {
+			t.Errorf("expected %v but returned %v", expected, string(body))
+		}
+	}
+
+	check("404", "custom 404")
+	check("503", "maintenance")
+
+	if len(s.templates) != len(defTemplates)+1 {
+		t.Errorf("expected only valid templates but returned %v", s.templates)
+	}
+
+	s.Update(nil)
+	check("503", "<html>\n<head><title>503 Service Unavailable</title></head>")
+}
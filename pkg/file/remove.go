@@ -0,0 +1,65 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// RemoveUnused removes the files matching the pattern that are not in use.
+// Files modified in the last minAge are kept to avoid removing files
+// created but still not referenced in the configuration.
+// It returns the list of removed files
+func RemoveUnused(pattern string, inUse []string, minAge time.Duration) ([]string, error) {
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	used := map[string]bool{}
+	for _, f := range inUse {
+		used[f] = true
+	}
+
+	removed := []string{}
+	for _, f := range files {
+		if used[f] {
+			continue
+		}
+
+		info, err := os.Stat(f)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return removed, err
+		}
+		if info.IsDir() || time.Since(info.ModTime()) < minAge {
+			continue
+		}
+
+		err = os.Remove(f)
+		if err != nil {
+			return removed, err
+		}
+		removed = append(removed, f)
+	}
+
+	return removed, nil
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package file
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRemoveUnused(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remove")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	used := filepath.Join(dir, "used.pem")
+	unused := filepath.Join(dir, "unused.pem")
+	recent := filepath.Join(dir, "recent.pem")
+	other := filepath.Join(dir, "other.txt")
+	for _, f := range []string{used, unused, recent, other} {
+		ioutil.WriteFile(f, []byte("data"), 0644)
+	}
+	old := time.Now().Add(-time.Hour)
+	for _, f := range []string{used, unused, other} {
+		os.Chtimes(f, old, old)
+	}
+	os.Mkdir(filepath.Join(dir, "dir.pem"), 0755)
+
+	removed, err := RemoveUnused(filepath.Join(dir, "*.pem"), []string{used}, time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(removed, []string{unused}) {
+		t.Errorf("expected %v to be removed but returned %v", unused, removed)
+	}
+
+	for _, f := range []string{used, recent, other} {
+		if _, err := os.Stat(f); err != nil {
+			t.Errorf("expected %v to exist: %v", f, err)
+		}
+	}
+}
@@ -17,12 +17,20 @@ limitations under the License.
 package auth
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"os/user"
+	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/aledbf/ingress-controller/pkg/file"
 	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/parser"
 
 	"k8s.io/kubernetes/pkg/api"
@@ -30,13 +38,27 @@ import (
 )
 
 const (
-	authType   = "ingress.kubernetes.io/auth-type"
-	authSecret = "ingress.kubernetes.io/auth-secret"
-	authRealm  = "ingress.kubernetes.io/auth-realm"
+	// user of the NGINX worker processes when the master process runs
+	// as root (the template does not define the directive user)
+	workerUser = "nobody"
+
+	authType       = "ingress.kubernetes.io/auth-type"
+	authSecret     = "ingress.kubernetes.io/auth-secret"
+	authSecretType = "ingress.kubernetes.io/auth-secret-type"
+	authRealm      = "ingress.kubernetes.io/auth-realm"
+
+	// AuthFile indicates the secret contains an htpasswd (basic) or
+	// htdigest (digest) file in the key auth
+	AuthFile = "auth-file"
+	// AuthMap indicates each key of the secret is a username and
+	// the value the password hash
+	AuthMap = "auth-map"
 
 	// DefAuthDirectory default directory used to store files
 	// to authenticate request
 	DefAuthDirectory = "/etc/ingress-controller/auth"
+
+	passwdExt = ".passwd"
 )
 
 func init() {
@@ -45,16 +67,22 @@ func init() {
 }
 
 var (
-	authTypeRegex = regexp.MustCompile(`basic|digest`)
+	authTypeRegex = regexp.MustCompile(`^(basic|digest)$`)
 
 	// ErrInvalidAuthType is return in case of unsupported authentication type
 	ErrInvalidAuthType = errors.New("invalid authentication type")
 
+	// ErrInvalidAuthSecretType is return in case of unsupported secret type
+	ErrInvalidAuthSecretType = errors.New("invalid authentication secret type")
+
 	// ErrMissingSecretName is returned when the name of the secret is missing
 	ErrMissingSecretName = errors.New("secret name is missing")
 
 	// ErrMissingAuthInSecret is returned when there is no auth key in secret data
 	ErrMissingAuthInSecret = errors.New("the secret does not contains the auth key")
+
+	// ErrMissingRealm is returned when digest authentication is used without realm
+	ErrMissingRealm = errors.New("digest authentication requires a realm")
 )
 
 // BasicDigest returns authentication configuration for an Ingress rule
@@ -67,8 +95,8 @@ type BasicDigest struct {
 
 // ParseAnnotations parses the annotations contained in the ingress
 // rule used to add authentication in the paths defined in the rule
-// and generated an htpasswd compatible file to be used as source
-// during the authentication process
+// and generated an htpasswd (or htdigest) compatible file to be used
+// as source during the authentication process
 func ParseAnnotations(ing *extensions.Ingress, authDir string, fn func(string) (*api.Secret, error)) (*BasicDigest, error) {
 	if ing.GetAnnotations() == nil {
 		return &BasicDigest{}, parser.ErrMissingAnnotations
@@ -88,15 +116,34 @@ func ParseAnnotations(ing *extensions.Ingress, authDir string, fn func(string) (
 		return &BasicDigest{}, err
 	}
 
+	st, err := parser.GetStringAnnotation(authSecretType, ing)
+	if err != nil || st == "" {
+		st = AuthFile
+	}
+	if st != AuthFile && st != AuthMap {
+		return &BasicDigest{}, ErrInvalidAuthSecretType
+	}
+
 	secret, err := fn(fmt.Sprintf("%v/%v", ing.Namespace, s))
 	if err != nil {
 		return &BasicDigest{}, err
 	}
 
 	realm, _ := parser.GetStringAnnotation(authRealm, ing)
+	if at == "digest" && realm == "" {
+		return &BasicDigest{}, ErrMissingRealm
+	}
 
-	passFile := fmt.Sprintf("%v/%v-%v.passwd", authDir, ing.GetNamespace(), ing.GetName())
-	err = dumpSecret(passFile, secret)
+	passFile := PasswdFileName(authDir, ing.GetNamespace(), ing.GetName())
+	switch st {
+	case AuthMap:
+		err = dumpSecretAuthMap(passFile, secret, at, realm)
+	default:
+		err = dumpSecret(passFile, secret)
+		if err == nil && at == "digest" {
+			err = checkDigestFile(passFile, realm)
+		}
+	}
 	if err != nil {
 		return &BasicDigest{}, err
 	}
@@ -109,6 +156,12 @@ func ParseAnnotations(ing *extensions.Ingress, authDir string, fn func(string) (
 	}, nil
 }
 
+// PasswdFileName returns the name of the file used to authenticate
+// requests in the Ingress rule with the specified namespace and name
+func PasswdFileName(authDir, namespace, name string) string {
+	return fmt.Sprintf("%v/%v-%v%v", authDir, namespace, name, passwdExt)
+}
+
 // dumpSecret dumps the content of a secret into a file
 // in the expected format for the specified authorization
 func dumpSecret(filename string, secret *api.Secret) error {
@@ -117,6 +170,104 @@ func dumpSecret(filename string, secret *api.Secret) error {
 		return ErrMissingAuthInSecret
 	}
 
-	// TODO: check permissions required
-	return ioutil.WriteFile(filename, val, 0777)
+	return writeAuthFile(filename, val)
+}
+
+// dumpSecretAuthMap dumps the content of a secret where each key is a
+// username and the value the password hash into a file in the expected
+// format for the specified authorization (htpasswd or htdigest)
+func dumpSecretAuthMap(filename string, secret *api.Secret, at, realm string) error {
+	if len(secret.Data) == 0 {
+		return fmt.Errorf("the secret %v/%v does not contains users", secret.Namespace, secret.Name)
+	}
+
+	users := []string{}
+	for user := range secret.Data {
+		users = append(users, user)
+	}
+	sort.Strings(users)
+
+	buf := bytes.NewBuffer([]byte{})
+	for _, user := range users {
+		hash := strings.TrimSpace(string(secret.Data[user]))
+		if strings.ContainsAny(user, ":\n") || strings.ContainsAny(hash, "\n") {
+			return fmt.Errorf("invalid user %v in secret %v/%v", user, secret.Namespace, secret.Name)
+		}
+
+		if at == "digest" {
+			fmt.Fprintf(buf, "%v:%v:%v\n", user, realm, hash)
+			continue
+		}
+		fmt.Fprintf(buf, "%v:%v\n", user, hash)
+	}
+
+	return writeAuthFile(filename, buf.Bytes())
+}
+
+// writeAuthFile replaces the file with a new file readable only by its
+// owner. When the controller runs as root the owner is the user of the
+// NGINX workers, which read the file in each request
+func writeAuthFile(filename string, content []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(content)
+	tmp.Close()
+	if err != nil {
+		return err
+	}
+	err = os.Chmod(tmp.Name(), 0600)
+	if err != nil {
+		return err
+	}
+
+	if os.Geteuid() == 0 {
+		u, err := user.Lookup(workerUser)
+		if err != nil {
+			return fmt.Errorf("error looking up user %v: %v", workerUser, err)
+		}
+		uid, _ := strconv.Atoi(u.Uid)
+		gid, _ := strconv.Atoi(u.Gid)
+		err = os.Chown(tmp.Name(), uid, gid)
+		if err != nil {
+			return err
+		}
+	}
+
+	return os.Rename(tmp.Name(), filename)
+}
+
+// checkDigestFile checks the file uses the htdigest format
+// (user:realm:hash) and the realm is the one used in the Ingress rule.
+// If the realm is different NGINX rejects all the requests
+func checkDigestFile(filename, realm string) error {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		parts := strings.Split(line, ":")
+		if len(parts) != 3 {
+			return fmt.Errorf("invalid htdigest file: lines must have the format user:realm:hash")
+		}
+		if parts[1] != realm {
+			return fmt.Errorf("invalid htdigest file: realm %v is different from %v", parts[1], realm)
+		}
+	}
+
+	return nil
+}
+
+// RemoveUnusedFiles removes the files inside the directory used to
+// store files to authenticate requests that are not in use and were
+// not modified in the last minAge
+func RemoveUnusedFiles(authDir string, inUse []string, minAge time.Duration) ([]string, error) {
+	return file.RemoveUnused(fmt.Sprintf("%v/*%v", authDir, passwdExt), inUse, minAge)
 }
@@ -140,4 +140,118 @@ func TestDumpSecret(t *testing.T) {
 	if err != nil {
 		t.Errorf("Unexpected error creating htpasswd file %v: %v", tmpfile, err)
 	}
+
+	fi, err := os.Stat(tmpfile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600 in the htpasswd file but returned %v", fi.Mode().Perm())
+	}
+}
+
+func mockAuthMapSecret(name string) (*api.Secret, error) {
+	return &api.Secret{
+		ObjectMeta: api.ObjectMeta{
+			Namespace: api.NamespaceDefault,
+			Name:      "demo-secret",
+		},
+		Data: map[string][]byte{
+			"foo": []byte("$apr1$OFG3Xybp$ckL0FHDAkoXYIlH9.cysT0"),
+			"bar": []byte("939e7578ed9e3c518a452acee763bce9\n"),
+		},
+	}, nil
+}
+
+func TestIngressAuthMap(t *testing.T) {
+	ing := buildIngress()
+
+	data := map[string]string{}
+	data[authType] = "basic"
+	data[authSecret] = "demo-secret"
+	data[authSecretType] = AuthMap
+	ing.SetAnnotations(data)
+
+	_, dir, _ := dummySecretContent(t)
+	defer os.RemoveAll(dir)
+
+	auth, err := ParseAnnotations(ing, dir, mockAuthMapSecret)
+	if err != nil {
+		t.Fatalf("Uxpected error with ingress: %v", err)
+	}
+
+	content, _ := ioutil.ReadFile(auth.File)
+	expected := "bar:939e7578ed9e3c518a452acee763bce9\nfoo:$apr1$OFG3Xybp$ckL0FHDAkoXYIlH9.cysT0\n"
+	if string(content) != expected {
+		t.Errorf("Expected \n%v\nbut returned \n%v", expected, string(content))
+	}
+
+	data[authType] = "digest"
+	data[authRealm] = "-realm-"
+	ing.SetAnnotations(data)
+
+	auth, err = ParseAnnotations(ing, dir, mockAuthMapSecret)
+	if err != nil {
+		t.Fatalf("Uxpected error with ingress: %v", err)
+	}
+
+	content, _ = ioutil.ReadFile(auth.File)
+	expected = "bar:-realm-:939e7578ed9e3c518a452acee763bce9\nfoo:-realm-:$apr1$OFG3Xybp$ckL0FHDAkoXYIlH9.cysT0\n"
+	if string(content) != expected {
+		t.Errorf("Expected \n%v\nbut returned \n%v", expected, string(content))
+	}
+
+	data[authSecretType] = "invalid"
+	ing.SetAnnotations(data)
+	_, err = ParseAnnotations(ing, dir, mockAuthMapSecret)
+	if err == nil {
+		t.Errorf("Expected error with invalid secret type")
+	}
+}
+
+func TestIngressDigestAuthFile(t *testing.T) {
+	ing := buildIngress()
+
+	data := map[string]string{}
+	data[authType] = "digest"
+	data[authSecret] = "demo-secret"
+	ing.SetAnnotations(data)
+
+	_, dir, _ := dummySecretContent(t)
+	defer os.RemoveAll(dir)
+
+	_, err := ParseAnnotations(ing, dir, mockSecret)
+	if err != ErrMissingRealm {
+		t.Errorf("Expected error %v but returned %v", ErrMissingRealm, err)
+	}
+
+	// the content of the secret is an htpasswd file
+	data[authRealm] = "-realm-"
+	ing.SetAnnotations(data)
+	_, err = ParseAnnotations(ing, dir, mockSecret)
+	if err == nil {
+		t.Errorf("Expected error with an htpasswd file and digest authentication")
+	}
+}
+
+func TestRemoveUnusedFiles(t *testing.T) {
+	_, dir, _ := dummySecretContent(t)
+	defer os.RemoveAll(dir)
+
+	used := PasswdFileName(dir, "default", "foo")
+	unused := PasswdFileName(dir, "default", "bar")
+	for _, f := range []string{used, unused} {
+		ioutil.WriteFile(f, []byte("foo:bar"), 0644)
+	}
+
+	removed, err := RemoveUnusedFiles(dir, []string{used}, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(removed) != 1 || removed[0] != unused {
+		t.Errorf("Expected %v to be removed but returned %v", unused, removed)
+	}
+	if _, err := os.Stat(used); err != nil {
+		t.Errorf("Expected %v to exist: %v", used, err)
+	}
 }
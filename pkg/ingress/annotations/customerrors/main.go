@@ -0,0 +1,66 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package customerrors
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/kubernetes/pkg/apis/extensions"
+
+	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/parser"
+)
+
+const (
+	customHTTPErrors = "ingress.kubernetes.io/custom-http-errors"
+)
+
+var (
+	// ErrInvalidCode is returned when the annotation contains a value
+	// that is not a valid HTTP error code
+	ErrInvalidCode = errors.New("invalid HTTP error code. The valid range is 300-599")
+)
+
+// ParseAnnotations parses the annotations contained in the ingress
+// rule used to configure the HTTP codes that should be passed for
+// processing with the error_page directive, as a comma separated list
+// (ie. 404,503)
+func ParseAnnotations(ing *extensions.Ingress) ([]int, error) {
+	val, err := parser.GetStringAnnotation(customHTTPErrors, ing)
+	if err != nil {
+		return []int{}, err
+	}
+
+	codes := []int{}
+	found := map[int]bool{}
+	for _, c := range strings.Split(val, ",") {
+		code, err := strconv.Atoi(strings.TrimSpace(c))
+		if err != nil || code < 300 || code > 599 {
+			return []int{}, ErrInvalidCode
+		}
+		if found[code] {
+			continue
+		}
+		found[code] = true
+		codes = append(codes, code)
+	}
+
+	sort.Ints(codes)
+	return codes, nil
+}
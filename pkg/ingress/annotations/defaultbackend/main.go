@@ -0,0 +1,55 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package defaultbackend
+
+import (
+	"errors"
+	"regexp"
+
+	"k8s.io/kubernetes/pkg/apis/extensions"
+
+	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/parser"
+)
+
+const (
+	defaultBackend = "ingress.kubernetes.io/default-backend"
+)
+
+var (
+	// ErrInvalidServiceName is returned when the annotation does not
+	// contain a valid service name
+	ErrInvalidServiceName = errors.New("invalid service name")
+
+	serviceRegex = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+)
+
+// ParseAnnotations parses the annotations contained in the ingress
+// rule used to indicate the name of the service (in the same namespace
+// than the Ingress rule) used as default backend. This service handles
+// the requests that do not match any path and the custom error pages.
+func ParseAnnotations(ing *extensions.Ingress) (string, error) {
+	svc, err := parser.GetStringAnnotation(defaultBackend, ing)
+	if err != nil {
+		return "", err
+	}
+
+	if !serviceRegex.MatchString(svc) {
+		return "", ErrInvalidServiceName
+	}
+
+	return svc, nil
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package globalratelimit
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/parser"
+
+	"k8s.io/kubernetes/pkg/apis/extensions"
+)
+
+const (
+	limit  = "ingress.kubernetes.io/global-rate-limit"
+	window = "ingress.kubernetes.io/global-rate-limit-window"
+	key    = "ingress.kubernetes.io/global-rate-limit-key"
+
+	// defWindow is the default size of the window in seconds
+	defWindow = 60
+
+	// defKey is the variable used to group requests when no key is specified
+	defKey = "$remote_addr"
+)
+
+var (
+	// ErrInvalidLimit is returned when the annotation contains invalid values
+	ErrInvalidLimit = errors.New("invalid global rate limit value. Must be > 0")
+
+	// ErrInvalidWindow is returned when the window is not a positive number of seconds
+	ErrInvalidWindow = errors.New("invalid global rate limit window. Must be > 0")
+
+	// ErrInvalidKey is returned when the key is not a valid NGINX variable
+	ErrInvalidKey = errors.New("invalid global rate limit key. Must be a variable like $http_x_api_key")
+
+	keyRegex = regexp.MustCompile(`^\$[a-zA-Z0-9_]+$`)
+)
+
+// Config describes a rate limit shared between all the replicas of the
+// Ingress controller. The counters are kept in an external store.
+type Config struct {
+	// Name identifies the counters of the rate limit in the store
+	Name string
+	// Limit is the number of requests allowed in the window
+	Limit int
+	// Window is the size of the window in seconds
+	Window int
+	// Key is the NGINX variable used to group the requests
+	Key string
+}
+
+// ParseAnnotations parses the annotations contained in the ingress
+// rule used to configure a global rate limit
+func ParseAnnotations(ing *extensions.Ingress) (*Config, error) {
+	if ing.GetAnnotations() == nil {
+		return &Config{}, parser.ErrMissingAnnotations
+	}
+
+	l, err := parser.GetIntAnnotation(limit, ing)
+	if err != nil {
+		return &Config{}, err
+	}
+	if l <= 0 {
+		return &Config{}, ErrInvalidLimit
+	}
+
+	w, err := parser.GetIntAnnotation(window, ing)
+	if err == parser.ErrMissingAnnotations {
+		w = defWindow
+	} else if err != nil || w <= 0 {
+		return &Config{}, ErrInvalidWindow
+	}
+
+	k, err := parser.GetStringAnnotation(key, ing)
+	if err != nil || k == "" {
+		k = defKey
+	}
+	if !keyRegex.MatchString(k) {
+		return &Config{}, ErrInvalidKey
+	}
+
+	return &Config{
+		Name:   fmt.Sprintf("%v_%v", ing.GetNamespace(), ing.GetName()),
+		Limit:  l,
+		Window: w,
+		Key:    k,
+	}, nil
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package globalratelimit
+
+import (
+	"testing"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/apis/extensions"
+	"k8s.io/kubernetes/pkg/util/intstr"
+)
+
+func buildIngress() *extensions.Ingress {
+	defaultBackend := extensions.IngressBackend{
+		ServiceName: "default-backend",
+		ServicePort: intstr.FromInt(80),
+	}
+
+	return &extensions.Ingress{
+		ObjectMeta: api.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: extensions.IngressSpec{
+			Backend: &extensions.IngressBackend{
+				ServiceName: "default-backend",
+				ServicePort: intstr.FromInt(80),
+			},
+			Rules: []extensions.IngressRule{
+				{
+					Host: "foo.bar.com",
+					IngressRuleValue: extensions.IngressRuleValue{
+						HTTP: &extensions.HTTPIngressRuleValue{
+							Paths: []extensions.HTTPIngressPath{
+								{
+									Path:    "/foo",
+									Backend: defaultBackend,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestWithoutAnnotations(t *testing.T) {
+	ing := buildIngress()
+	_, err := ParseAnnotations(ing)
+	if err == nil {
+		t.Error("Expected error with ingress without annotations")
+	}
+}
+
+func TestGlobalRateLimit(t *testing.T) {
+	ing := buildIngress()
+
+	data := map[string]string{}
+	data[limit] = "100"
+	ing.SetAnnotations(data)
+
+	grl, err := ParseAnnotations(ing)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if grl.Name != "default_foo" {
+		t.Errorf("Expected default_foo as name but %v was returned", grl.Name)
+	}
+	if grl.Limit != 100 {
+		t.Errorf("Expected 100 as limit but %v was returned", grl.Limit)
+	}
+	if grl.Window != defWindow {
+		t.Errorf("Expected %v as window but %v was returned", defWindow, grl.Window)
+	}
+	if grl.Key != defKey {
+		t.Errorf("Expected %v as key but %v was returned", defKey, grl.Key)
+	}
+
+	data[window] = "1"
+	data[key] = "$http_x_api_key"
+	ing.SetAnnotations(data)
+
+	grl, err = ParseAnnotations(ing)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if grl.Window != 1 {
+		t.Errorf("Expected 1 as window but %v was returned", grl.Window)
+	}
+	if grl.Key != "$http_x_api_key" {
+		t.Errorf("Expected $http_x_api_key as key but %v was returned", grl.Key)
+	}
+}
+
+func TestInvalidGlobalRateLimit(t *testing.T) {
+	ing := buildIngress()
+
+	invalid := []map[string]string{
+		{limit: "0"},
+		{limit: "10", window: "0"},
+		{limit: "10", window: "a"},
+		{limit: "10", key: "remote_addr"},
+	}
+
+	for _, data := range invalid {
+		ing.SetAnnotations(data)
+		_, err := ParseAnnotations(ing)
+		if err == nil {
+			t.Errorf("Expected error with invalid annotations %v", data)
+		}
+	}
+}
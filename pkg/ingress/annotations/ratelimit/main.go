@@ -19,15 +19,25 @@ package ratelimit
 import (
 	"errors"
 	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
 
 	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/parser"
 
 	"k8s.io/kubernetes/pkg/apis/extensions"
+	"k8s.io/kubernetes/pkg/util/net/sets"
 )
 
 const (
-	limitIP  = "ingress.kubernetes.io/limit-connections"
-	limitRPS = "ingress.kubernetes.io/limit-rps"
+	limitIP         = "ingress.kubernetes.io/limit-connections"
+	limitRPS        = "ingress.kubernetes.io/limit-rps"
+	limitRPM        = "ingress.kubernetes.io/limit-rpm"
+	limitBurst      = "ingress.kubernetes.io/limit-burst"
+	limitKey        = "ingress.kubernetes.io/limit-key"
+	limitWhitelist  = "ingress.kubernetes.io/limit-whitelist"
+	limitStatusCode = "ingress.kubernetes.io/limit-status-code"
 
 	// allow 5 times the specified limit as burst
 	defBurst = 5
@@ -35,22 +45,52 @@ const (
 	// 1MB -> 16 thousand 64-byte states or about 8 thousand 128-byte states
 	// default is 5MB
 	defSharedSize = 5
+
+	// DefKey is the variable used to group requests when no key is specified
+	DefKey = "$binary_remote_addr"
+
+	// DefStatusCode is the status code returned to rejected requests
+	// http://nginx.org/en/docs/http/ngx_http_limit_req_module.html#limit_req_status
+	DefStatusCode = 503
 )
 
 var (
 	// ErrInvalidRateLimit is returned when the annotation caontains invalid values
 	ErrInvalidRateLimit = errors.New("invalid rate limit value. Must be > 0")
+
+	// ErrInvalidKey is returned when the key is not a valid NGINX variable
+	ErrInvalidKey = errors.New("invalid rate limit key. Must be a variable like $http_x_api_key")
+
+	// ErrInvalidStatusCode is returned when the status code is not between 400 and 599
+	ErrInvalidStatusCode = errors.New("invalid rate limit status code. Must be between 400 and 599")
+
+	// ErrInvalidCIDR is returned when the whitelist does not contains
+	// a valid IP or network address
+	ErrInvalidCIDR = errors.New("the rate limit whitelist does not contains a valid IP address or network")
+
+	keyRegex = regexp.MustCompile(`^\$[a-zA-Z0-9_]+$`)
 )
 
 // RateLimit returns rate limit configuration for an Ingress rule
-// Is possible to limit the number of connections per IP address or
-// connections per second.
-// Note: Is possible to specify both limits
+// Is possible to limit the number of connections per key (IP address
+// by default), requests per second or requests per minute.
+// Note: Is possible to specify all the limits
 type RateLimit struct {
-	// Connections indicates a limit with the number of connections per IP address
+	// Connections indicates a limit with the number of connections per key
 	Connections Zone
-	// RPS indicates a limit with the number of connections per second
+	// RPS indicates a limit with the number of requests per second
 	RPS Zone
+	// RPM indicates a limit with the number of requests per minute
+	RPM Zone
+	// ID identifies the Ingress rule that defines the limits
+	ID string
+	// Key is the NGINX variable used to group the requests
+	// http://nginx.org/en/docs/http/ngx_http_limit_req_module.html#limit_req_zone
+	Key string
+	// Whitelist contains the IP addresses or networks that are not limited
+	Whitelist []string
+	// StatusCode returned to requests that exceed the limit
+	StatusCode int
 }
 
 // Zone returns information about the NGINX rate limit (limit_req_zone)
@@ -71,29 +111,85 @@ func ParseAnnotations(ing *extensions.Ingress) (*RateLimit, error) {
 	}
 
 	rps, _ := parser.GetIntAnnotation(limitRPS, ing)
+	rpm, _ := parser.GetIntAnnotation(limitRPM, ing)
 	conn, _ := parser.GetIntAnnotation(limitIP, ing)
 
-	if rps == 0 && conn == 0 {
-		return &RateLimit{
-			Connections: Zone{},
-			RPS:         Zone{},
-		}, ErrInvalidRateLimit
+	if rps <= 0 && rpm <= 0 && conn <= 0 {
+		return &RateLimit{}, ErrInvalidRateLimit
+	}
+
+	key, err := parser.GetStringAnnotation(limitKey, ing)
+	if err != nil || key == "" {
+		key = DefKey
+	}
+	if !keyRegex.MatchString(key) {
+		return &RateLimit{}, ErrInvalidKey
+	}
+
+	code, err := parser.GetIntAnnotation(limitStatusCode, ing)
+	if err != nil {
+		code = DefStatusCode
+	}
+	if code < 400 || code > 599 {
+		return &RateLimit{}, ErrInvalidStatusCode
+	}
+
+	wl := []string{}
+	val, err := parser.GetStringAnnotation(limitWhitelist, ing)
+	if err == nil && val != "" {
+		values := []string{}
+		for _, v := range strings.Split(val, ",") {
+			v = strings.TrimSpace(v)
+			// a single IP address is a network with only one host
+			if ip := net.ParseIP(v); ip != nil {
+				if ip.To4() != nil {
+					v = fmt.Sprintf("%v/32", v)
+				} else {
+					v = fmt.Sprintf("%v/128", v)
+				}
+			}
+			values = append(values, v)
+		}
+		ipnets, err := sets.ParseIPNets(values...)
+		if err != nil {
+			return &RateLimit{}, ErrInvalidCIDR
+		}
+		for k := range ipnets {
+			wl = append(wl, k)
+		}
+		sort.Strings(wl)
 	}
 
+	burst, _ := parser.GetIntAnnotation(limitBurst, ing)
+
 	zoneName := fmt.Sprintf("%v_%v", ing.GetNamespace(), ing.GetName())
 
 	return &RateLimit{
-		Connections: Zone{
-			Name:       fmt.Sprintf("%v_conn", zoneName),
-			Limit:      conn,
-			Burst:      conn * defBurst,
-			SharedSize: defSharedSize,
-		},
-		RPS: Zone{
-			Name:       fmt.Sprintf("%v_rps", zoneName),
-			Limit:      rps,
-			Burst:      conn * defBurst,
-			SharedSize: defSharedSize,
-		},
+		Connections: newZone(fmt.Sprintf("%v_conn", zoneName), conn, 0),
+		RPS:         newZone(fmt.Sprintf("%v_rps", zoneName), rps, burst),
+		RPM:         newZone(fmt.Sprintf("%v_rpm", zoneName), rpm, burst),
+		ID:          zoneName,
+		Key:         key,
+		Whitelist:   wl,
+		StatusCode:  code,
 	}, nil
 }
+
+// newZone returns a Zone with the specified limit. If the burst is not
+// specified defBurst times the limit is used instead.
+func newZone(name string, limit, burst int) Zone {
+	if limit <= 0 {
+		return Zone{}
+	}
+
+	if burst <= 0 {
+		burst = limit * defBurst
+	}
+
+	return Zone{
+		Name:       name,
+		Limit:      limit,
+		Burst:      burst,
+		SharedSize: defSharedSize,
+	}
+}
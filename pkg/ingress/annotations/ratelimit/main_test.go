@@ -98,3 +98,81 @@ func TestBadRateLimiting(t *testing.T) {
 		t.Errorf("Expected 100 in limit by rps but %v was returend", rateLimit.RPS)
 	}
 }
+
+func TestRateLimitPerMinute(t *testing.T) {
+	ing := buildIngress()
+
+	data := map[string]string{}
+	data[limitRPM] = "60"
+	data[limitBurst] = "10"
+	ing.SetAnnotations(data)
+
+	rateLimit, err := ParseAnnotations(ing)
+	if err != nil {
+		t.Errorf("Uxpected error: %v", err)
+	}
+
+	if rateLimit.RPM.Limit != 60 {
+		t.Errorf("Expected 60 in limit by rpm but %v was returend", rateLimit.RPM)
+	}
+	if rateLimit.RPM.Burst != 10 {
+		t.Errorf("Expected 10 as burst but %v was returend", rateLimit.RPM.Burst)
+	}
+	if rateLimit.RPS.Limit != 0 {
+		t.Errorf("Expected no limit by rps but %v was returend", rateLimit.RPS)
+	}
+	if rateLimit.Key != DefKey {
+		t.Errorf("Expected %v as key but %v was returend", DefKey, rateLimit.Key)
+	}
+	if rateLimit.StatusCode != DefStatusCode {
+		t.Errorf("Expected %v as status code but %v was returend", DefStatusCode, rateLimit.StatusCode)
+	}
+}
+
+func TestRateLimitKeyAndWhitelist(t *testing.T) {
+	ing := buildIngress()
+
+	data := map[string]string{}
+	data[limitRPS] = "10"
+	data[limitKey] = "$http_x_api_key"
+	data[limitWhitelist] = "10.0.0.0/8,192.168.0.1"
+	data[limitStatusCode] = "429"
+	ing.SetAnnotations(data)
+
+	rateLimit, err := ParseAnnotations(ing)
+	if err != nil {
+		t.Errorf("Uxpected error: %v", err)
+	}
+
+	if rateLimit.Key != "$http_x_api_key" {
+		t.Errorf("Expected $http_x_api_key as key but %v was returend", rateLimit.Key)
+	}
+	if rateLimit.RPS.Burst != 10*defBurst {
+		t.Errorf("Expected %v as burst but %v was returend", 10*defBurst, rateLimit.RPS.Burst)
+	}
+	if len(rateLimit.Whitelist) != 2 {
+		t.Errorf("Expected 2 networks in the whitelist but %v was returend", rateLimit.Whitelist)
+	}
+	if rateLimit.StatusCode != 429 {
+		t.Errorf("Expected 429 as status code but %v was returend", rateLimit.StatusCode)
+	}
+}
+
+func TestInvalidRateLimitOptions(t *testing.T) {
+	ing := buildIngress()
+
+	invalid := []map[string]string{
+		{limitRPS: "10", limitKey: "http_x_api_key"},
+		{limitRPS: "10", limitKey: "$http_x_api_key; deny all"},
+		{limitRPS: "10", limitWhitelist: "10.0.0.0/40"},
+		{limitRPS: "10", limitStatusCode: "200"},
+	}
+
+	for _, data := range invalid {
+		ing.SetAnnotations(data)
+		_, err := ParseAnnotations(ing)
+		if err == nil {
+			t.Errorf("Expected error with invalid annotations %v", data)
+		}
+	}
+}
@@ -17,8 +17,6 @@ limitations under the License.
 package rewrite
 
 import (
-	"errors"
-
 	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/parser"
 	"github.com/aledbf/ingress-controller/pkg/ingress/defaults"
 
@@ -26,9 +24,10 @@ import (
 )
 
 const (
-	rewriteTo   = "ingress.kubernetes.io/rewrite-target"
-	addBaseURL  = "ingress.kubernetes.io/add-base-url"
-	sslRedirect = "ingress.kubernetes.io/ssl-redirect"
+	rewriteTo        = "ingress.kubernetes.io/rewrite-target"
+	addBaseURL       = "ingress.kubernetes.io/add-base-url"
+	sslRedirect      = "ingress.kubernetes.io/ssl-redirect"
+	forceSSLRedirect = "ingress.kubernetes.io/force-ssl-redirect"
 )
 
 // Redirect describes the per location redirect config
@@ -40,13 +39,20 @@ type Redirect struct {
 	AddBaseURL bool
 	// SSLRedirect indicates if the location section is accessible SSL only
 	SSLRedirect bool
+	// ForceSSLRedirect indicates if the location section is accessible SSL only
+	// even if the server does not use SSL (SSL terminated in a load balancer)
+	ForceSSLRedirect bool
 }
 
 // ParseAnnotations parses the annotations contained in the ingress
 // rule used to rewrite the defined paths
 func ParseAnnotations(cfg defaults.Backend, ing *extensions.Ingress) (*Redirect, error) {
 	if ing.GetAnnotations() == nil {
-		return &Redirect{}, errors.New("no annotations present")
+		// the global ssl-redirect is not applied to the rules
+		// without annotations
+		return &Redirect{
+			ForceSSLRedirect: cfg.ForceSSLRedirect,
+		}, parser.ErrMissingAnnotations
 	}
 
 	sslRe, err := parser.GetBoolAnnotation(sslRedirect, ing)
@@ -54,11 +60,17 @@ func ParseAnnotations(cfg defaults.Backend, ing *extensions.Ingress) (*Redirect,
 		sslRe = cfg.SSLRedirect
 	}
 
+	fSslRe, err := parser.GetBoolAnnotation(forceSSLRedirect, ing)
+	if err != nil {
+		fSslRe = cfg.ForceSSLRedirect
+	}
+
 	rt, _ := parser.GetStringAnnotation(rewriteTo, ing)
 	abu, _ := parser.GetBoolAnnotation(addBaseURL, ing)
 	return &Redirect{
-		Target:      rt,
-		AddBaseURL:  abu,
-		SSLRedirect: sslRe,
+		Target:           rt,
+		AddBaseURL:       abu,
+		SSLRedirect:      sslRe,
+		ForceSSLRedirect: fSslRe,
 	}, nil
 }
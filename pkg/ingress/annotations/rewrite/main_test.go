@@ -114,3 +114,36 @@ func TestSSLRedirect(t *testing.T) {
 		t.Errorf("Expected false but returned true")
 	}
 }
+
+func TestForceSSLRedirect(t *testing.T) {
+	ing := buildIngress()
+
+	cfg := defaults.Backend{ForceSSLRedirect: true}
+
+	redirect, _ := ParseAnnotations(cfg, ing)
+	if !redirect.ForceSSLRedirect {
+		t.Errorf("Expected true in Ingress without annotations but returned false")
+	}
+
+	redirect, _ = ParseAnnotations(defaults.Backend{SSLRedirect: true}, ing)
+	if redirect.SSLRedirect {
+		t.Errorf("Expected false in Ingress without annotations but returned true")
+	}
+
+	data := map[string]string{}
+	data[forceSSLRedirect] = "false"
+	ing.SetAnnotations(data)
+
+	redirect, _ = ParseAnnotations(cfg, ing)
+	if redirect.ForceSSLRedirect {
+		t.Errorf("Expected false but returned true")
+	}
+
+	data[forceSSLRedirect] = "true"
+	ing.SetAnnotations(data)
+
+	redirect, _ = ParseAnnotations(defaults.Backend{}, ing)
+	if !redirect.ForceSSLRedirect {
+		t.Errorf("Expected true but returned false")
+	}
+}
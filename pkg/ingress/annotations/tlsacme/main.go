@@ -1,5 +1,5 @@
 /*
-Copyright 2015 The Kubernetes Authors.
+Copyright 2016 The Kubernetes Authors.
 
 Licensed under the Apache License, Version 2.0 (the "License");
 you may not use this file except in compliance with the License.
@@ -14,28 +14,21 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
-package main
-
-import "testing"
-
-func TestDiff(t *testing.T) {
-	tests := []struct {
-		a     []byte
-		b     []byte
-		empty bool
-	}{
-		{[]byte(""), []byte(""), true},
-		{[]byte("a"), []byte("a"), true},
-		{[]byte("a"), []byte("b"), false},
-	}
-
-	for _, test := range tests {
-		b, err := diff(test.a, test.b)
-		if err != nil {
-			t.Fatalf("unexpected error returned: %v", err)
-		}
-		if len(b) == 0 && !test.empty {
-			t.Fatalf("expected empty but returned %s", b)
-		}
-	}
+package tlsacme
+
+import (
+	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/parser"
+
+	"k8s.io/kubernetes/pkg/apis/extensions"
+)
+
+const (
+	tlsACME = "ingress.kubernetes.io/tls-acme"
+)
+
+// ParseAnnotations parses the annotations contained in the ingress
+// rule used to indicate if the certificates of the TLS section should
+// be obtained using ACME
+func ParseAnnotations(ing *extensions.Ingress) (bool, error) {
+	return parser.GetBoolAnnotation(tlsACME, ing)
 }
@@ -0,0 +1,202 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tlssettings
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/parser"
+
+	"k8s.io/kubernetes/pkg/apis/extensions"
+)
+
+const (
+	sslProtocols          = "ingress.kubernetes.io/ssl-protocols"
+	sslCiphers            = "ingress.kubernetes.io/ssl-ciphers"
+	hsts                  = "ingress.kubernetes.io/hsts"
+	hstsMaxAge            = "ingress.kubernetes.io/hsts-max-age"
+	hstsIncludeSubdomains = "ingress.kubernetes.io/hsts-include-subdomains"
+	hstsPreload           = "ingress.kubernetes.io/hsts-preload"
+	useHTTP2              = "ingress.kubernetes.io/use-http2"
+)
+
+var (
+	// Protocols contains the valid SSL protocols in ascending order
+	Protocols = []string{"SSLv3", "TLSv1", "TLSv1.1", "TLSv1.2"}
+
+	// ErrInvalidProtocols is returned when the protocols are not a list of valid protocols
+	ErrInvalidProtocols = fmt.Errorf("invalid SSL protocols. Must be a list of %v", strings.Join(Protocols, ", "))
+
+	// ErrInvalidCiphers is returned when the ciphers contain characters not
+	// valid in the cipher list format of OpenSSL
+	ErrInvalidCiphers = errors.New("invalid SSL ciphers")
+
+	// ErrInvalidHSTSMaxAge is returned when the HSTS max-age is negative
+	ErrInvalidHSTSMaxAge = errors.New("invalid HSTS max-age. Must be >= 0")
+
+	ciphersRegex = regexp.MustCompile(`^[A-Za-z0-9!:+@=._-]+$`)
+)
+
+// Config contains the TLS settings of a server. Empty or nil
+// values use the global configuration of the backend
+type Config struct {
+	// Protocols contains the enabled protocols separated by spaces
+	// in the order of the variable Protocols
+	Protocols string
+	// Ciphers contains the enabled ciphers in the format of OpenSSL
+	Ciphers               string
+	HSTS                  *bool
+	HSTSMaxAge            *int
+	HSTSIncludeSubdomains *bool
+	HSTSPreload           *bool
+	HTTP2                 *bool
+}
+
+// IsEmpty returns true if the configuration does not override
+// any global setting
+func (c Config) IsEmpty() bool {
+	return c == Config{}
+}
+
+// Merge sets the settings not defined in the configuration with the
+// values of other configuration and returns the names of the settings
+// defined in both configurations with different values
+func (c *Config) Merge(o *Config) []string {
+	var conflicts []string
+	mergeString := func(name string, dst *string, src string) {
+		if src == "" {
+			return
+		}
+		if *dst == "" {
+			*dst = src
+		} else if *dst != src {
+			conflicts = append(conflicts, name)
+		}
+	}
+	mergeBool := func(name string, dst **bool, src *bool) {
+		if src == nil {
+			return
+		}
+		if *dst == nil {
+			*dst = src
+		} else if **dst != *src {
+			conflicts = append(conflicts, name)
+		}
+	}
+
+	mergeString(sslProtocols, &c.Protocols, o.Protocols)
+	mergeString(sslCiphers, &c.Ciphers, o.Ciphers)
+	mergeBool(hsts, &c.HSTS, o.HSTS)
+	if o.HSTSMaxAge != nil {
+		if c.HSTSMaxAge == nil {
+			c.HSTSMaxAge = o.HSTSMaxAge
+		} else if *c.HSTSMaxAge != *o.HSTSMaxAge {
+			conflicts = append(conflicts, hstsMaxAge)
+		}
+	}
+	mergeBool(hstsIncludeSubdomains, &c.HSTSIncludeSubdomains, o.HSTSIncludeSubdomains)
+	mergeBool(hstsPreload, &c.HSTSPreload, o.HSTSPreload)
+	mergeBool(useHTTP2, &c.HTTP2, o.HTTP2)
+
+	return conflicts
+}
+
+// ParseAnnotations parses the annotations contained in the ingress
+// rule used to override the global TLS settings in the servers
+func ParseAnnotations(ing *extensions.Ingress) (*Config, error) {
+	if ing.GetAnnotations() == nil {
+		return &Config{}, parser.ErrMissingAnnotations
+	}
+
+	cfg := &Config{}
+
+	protocols, err := parser.GetStringAnnotation(sslProtocols, ing)
+	if err == nil {
+		cfg.Protocols, err = normalizeProtocols(protocols)
+		if err != nil {
+			return &Config{}, err
+		}
+	}
+
+	ciphers, err := parser.GetStringAnnotation(sslCiphers, ing)
+	if err == nil {
+		if !ciphersRegex.MatchString(ciphers) {
+			return &Config{}, ErrInvalidCiphers
+		}
+		cfg.Ciphers = ciphers
+	}
+
+	maxAge, err := parser.GetIntAnnotation(hstsMaxAge, ing)
+	if err != nil && err != parser.ErrMissingAnnotations {
+		return &Config{}, err
+	}
+	if err == nil {
+		if maxAge < 0 {
+			return &Config{}, ErrInvalidHSTSMaxAge
+		}
+		cfg.HSTSMaxAge = &maxAge
+	}
+
+	cfg.HSTS = getBool(hsts, ing)
+	cfg.HSTSIncludeSubdomains = getBool(hstsIncludeSubdomains, ing)
+	cfg.HSTSPreload = getBool(hstsPreload, ing)
+	cfg.HTTP2 = getBool(useHTTP2, ing)
+
+	return cfg, nil
+}
+
+// getBool returns nil if the annotation is not defined
+func getBool(name string, ing *extensions.Ingress) *bool {
+	b, err := parser.GetBoolAnnotation(name, ing)
+	if err != nil {
+		return nil
+	}
+	return &b
+}
+
+// normalizeProtocols returns a list of protocols without duplicates
+// in the order of the variable Protocols
+func normalizeProtocols(protocols string) (string, error) {
+	enabled := map[string]bool{}
+	for _, p := range strings.Fields(protocols) {
+		valid := false
+		for _, v := range Protocols {
+			if p == v {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return "", ErrInvalidProtocols
+		}
+		enabled[p] = true
+	}
+	if len(enabled) == 0 {
+		return "", ErrInvalidProtocols
+	}
+
+	var sorted []string
+	for _, p := range Protocols {
+		if enabled[p] {
+			sorted = append(sorted, p)
+		}
+	}
+	return strings.Join(sorted, " "), nil
+}
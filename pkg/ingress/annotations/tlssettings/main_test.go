@@ -0,0 +1,146 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tlssettings
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/apis/extensions"
+)
+
+func buildIngress() *extensions.Ingress {
+	return &extensions.Ingress{
+		ObjectMeta: api.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: extensions.IngressSpec{
+			TLS: []extensions.IngressTLS{
+				{
+					Hosts:      []string{"foo.bar.com"},
+					SecretName: "foo-tls",
+				},
+			},
+		},
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	ing := buildIngress()
+	data := map[string]string{}
+	data[sslProtocols] = "TLSv1.2 TLSv1  TLSv1"
+	data[sslCiphers] = "ECDHE-RSA-AES128-GCM-SHA256:!aNULL"
+	data[hsts] = "true"
+	data[hstsMaxAge] = "3600"
+	data[hstsIncludeSubdomains] = "false"
+	data[useHTTP2] = "false"
+	ing.SetAnnotations(data)
+
+	cfg, err := ParseAnnotations(ing)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Protocols != "TLSv1 TLSv1.2" {
+		t.Errorf("expected protocols TLSv1 TLSv1.2 but returned %v", cfg.Protocols)
+	}
+	if cfg.Ciphers != data[sslCiphers] {
+		t.Errorf("expected ciphers %v but returned %v", data[sslCiphers], cfg.Ciphers)
+	}
+	if cfg.HSTS == nil || !*cfg.HSTS {
+		t.Errorf("expected HSTS enabled")
+	}
+	if cfg.HSTSMaxAge == nil || *cfg.HSTSMaxAge != 3600 {
+		t.Errorf("expected HSTS max-age 3600 but returned %v", cfg.HSTSMaxAge)
+	}
+	if cfg.HSTSIncludeSubdomains == nil || *cfg.HSTSIncludeSubdomains {
+		t.Errorf("expected HSTS includeSubdomains disabled")
+	}
+	if cfg.HSTSPreload != nil {
+		t.Errorf("expected HSTS preload not defined")
+	}
+	if cfg.HTTP2 == nil || *cfg.HTTP2 {
+		t.Errorf("expected HTTP/2 disabled")
+	}
+}
+
+func TestInvalidAnnotations(t *testing.T) {
+	tests := map[string]error{
+		sslProtocols: ErrInvalidProtocols,
+		sslCiphers:   ErrInvalidCiphers,
+		hstsMaxAge:   ErrInvalidHSTSMaxAge,
+	}
+	values := map[string][]string{
+		sslProtocols: {"TLSv1.3", "", "tlsv1"},
+		sslCiphers:   {"HIGH'; return 200;", ""},
+		hstsMaxAge:   {"-1"},
+	}
+
+	for name, expected := range tests {
+		for _, value := range values[name] {
+			ing := buildIngress()
+			ing.SetAnnotations(map[string]string{name: value})
+			_, err := ParseAnnotations(ing)
+			if err != expected {
+				t.Errorf("%v=%q: expected error %v but returned %v", name, value, expected, err)
+			}
+		}
+	}
+}
+
+func TestWithoutAnnotations(t *testing.T) {
+	ing := buildIngress()
+	_, err := ParseAnnotations(ing)
+	if err == nil {
+		t.Error("Expected error with ingress without annotations")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	enabled := true
+	disabled := false
+	maxAge := 3600
+	otherMaxAge := 60
+
+	cfg := &Config{Protocols: "TLSv1.2", HSTS: &enabled, HSTSMaxAge: &maxAge}
+	conflicts := cfg.Merge(&Config{
+		Protocols:  "TLSv1.2",
+		Ciphers:    "HIGH",
+		HSTS:       &disabled,
+		HSTSMaxAge: &otherMaxAge,
+		HTTP2:      &disabled,
+	})
+
+	expected := &Config{
+		Protocols:  "TLSv1.2",
+		Ciphers:    "HIGH",
+		HSTS:       &enabled,
+		HSTSMaxAge: &maxAge,
+		HTTP2:      &disabled,
+	}
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Errorf("expected %+v but returned %+v", expected, cfg)
+	}
+	if !reflect.DeepEqual(conflicts, []string{hsts, hstsMaxAge}) {
+		t.Errorf("expected conflicts in %v and %v but returned %v", hsts, hstsMaxAge, conflicts)
+	}
+
+	if !(Config{}).IsEmpty() || cfg.IsEmpty() {
+		t.Errorf("unexpected result of IsEmpty")
+	}
+}
@@ -0,0 +1,249 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ConfigurationChanges describes the differences between two configurations
+type ConfigurationChanges struct {
+	// ConfigMap indicates the custom configuration of the backend changed
+	ConfigMap bool
+	// HealthzURL indicates the URL used as health check changed
+	HealthzURL bool
+	// Streams indicates the TCP, UDP or SSL passthrough services changed
+	Streams bool
+	// SSLFiles indicates the DH parameters or the session ticket keys changed
+	SSLFiles bool
+
+	AddedServers   []string
+	RemovedServers []string
+	// ChangedServers contains the name of the servers with changes in
+	// the SSL configuration or in the locations
+	ChangedServers []string
+	// ChangedCertificates contains the name of the servers where only
+	// the content of the SSL certificates changed
+	ChangedCertificates []string
+
+	AddedUpstreams   []string
+	RemovedUpstreams []string
+	// ChangedUpstreams contains the upstreams with changes in the servers (endpoints)
+	ChangedUpstreams []UpstreamChanges
+}
+
+// UpstreamChanges describes the differences in the servers of an upstream
+type UpstreamChanges struct {
+	Name string
+	// AddedServers and RemovedServers contains <address>:<port>
+	AddedServers   []string
+	RemovedServers []string
+}
+
+// IsEmpty returns true if there are no differences
+func (c *ConfigurationChanges) IsEmpty() bool {
+	return !c.ConfigMap && !c.HealthzURL && !c.Streams && !c.SSLFiles &&
+		len(c.AddedServers) == 0 && len(c.RemovedServers) == 0 && len(c.ChangedServers) == 0 &&
+		len(c.ChangedCertificates) == 0 &&
+		len(c.AddedUpstreams) == 0 && len(c.RemovedUpstreams) == 0 && len(c.ChangedUpstreams) == 0
+}
+
+// String returns a summary of the differences
+func (c *ConfigurationChanges) String() string {
+	var out []string
+	if c.ConfigMap {
+		out = append(out, "configmap changed")
+	}
+	if c.HealthzURL {
+		out = append(out, "health check URL changed")
+	}
+	if c.Streams {
+		out = append(out, "TCP, UDP or SSL passthrough services changed")
+	}
+	if c.SSLFiles {
+		out = append(out, "DH parameters or session ticket keys changed")
+	}
+
+	list := func(title string, items []string) {
+		if len(items) > 0 {
+			out = append(out, fmt.Sprintf("%v: %v", title, strings.Join(items, ", ")))
+		}
+	}
+	list("added servers", c.AddedServers)
+	list("removed servers", c.RemovedServers)
+	list("changed servers", c.ChangedServers)
+	list("changed certificates", c.ChangedCertificates)
+	list("added upstreams", c.AddedUpstreams)
+	list("removed upstreams", c.RemovedUpstreams)
+	for _, u := range c.ChangedUpstreams {
+		out = append(out, fmt.Sprintf("upstream %v: added servers [%v] removed servers [%v]",
+			u.Name, strings.Join(u.AddedServers, ", "), strings.Join(u.RemovedServers, ", ")))
+	}
+
+	if len(out) == 0 {
+		return "no changes"
+	}
+	return strings.Join(out, "\n")
+}
+
+// CompareConfigurations returns the differences between two configurations.
+// A nil previous configuration means everything in the current
+// configuration is new
+func CompareConfigurations(prev, cur *Configuration) *ConfigurationChanges {
+	if prev == nil {
+		prev = &Configuration{}
+	}
+
+	changes := &ConfigurationChanges{
+		HealthzURL: prev.HealthzURL != cur.HealthzURL,
+		Streams: !reflect.DeepEqual(prev.TCPUpstreams, cur.TCPUpstreams) ||
+			!reflect.DeepEqual(prev.UDPUpstreams, cur.UDPUpstreams) ||
+			!reflect.DeepEqual(prev.PassthroughUpstreams, cur.PassthroughUpstreams),
+		SSLFiles: !reflect.DeepEqual(prev.DHParam, cur.DHParam) ||
+			!reflect.DeepEqual(prev.SessionTicketKeys, cur.SessionTicketKeys),
+	}
+
+	prevServers := map[string]*Server{}
+	for _, s := range prev.Servers {
+		prevServers[s.Name] = s
+	}
+	curServers := map[string]*Server{}
+	for _, s := range cur.Servers {
+		curServers[s.Name] = s
+		old, ok := prevServers[s.Name]
+		if !ok {
+			changes.AddedServers = append(changes.AddedServers, s.Name)
+			continue
+		}
+		if !equalServers(old, s) {
+			changes.ChangedServers = append(changes.ChangedServers, s.Name)
+		} else if !equalCertificates(old, s) {
+			changes.ChangedCertificates = append(changes.ChangedCertificates, s.Name)
+		}
+	}
+	for name := range prevServers {
+		if _, ok := curServers[name]; !ok {
+			changes.RemovedServers = append(changes.RemovedServers, name)
+		}
+	}
+
+	prevUpstreams := map[string]*Upstream{}
+	for _, u := range prev.Upstreams {
+		prevUpstreams[u.Name] = u
+	}
+	curUpstreams := map[string]*Upstream{}
+	for _, u := range cur.Upstreams {
+		curUpstreams[u.Name] = u
+		old, ok := prevUpstreams[u.Name]
+		if !ok {
+			changes.AddedUpstreams = append(changes.AddedUpstreams, u.Name)
+			continue
+		}
+		if uc := compareUpstreams(old, u); uc != nil {
+			changes.ChangedUpstreams = append(changes.ChangedUpstreams, *uc)
+		}
+	}
+	for name := range prevUpstreams {
+		if _, ok := curUpstreams[name]; !ok {
+			changes.RemovedUpstreams = append(changes.RemovedUpstreams, name)
+		}
+	}
+
+	sort.Strings(changes.AddedServers)
+	sort.Strings(changes.RemovedServers)
+	sort.Strings(changes.ChangedServers)
+	sort.Strings(changes.ChangedCertificates)
+	sort.Strings(changes.AddedUpstreams)
+	sort.Strings(changes.RemovedUpstreams)
+	sort.Sort(upstreamChangesByName(changes.ChangedUpstreams))
+
+	return changes
+}
+
+// equalServers compares two servers ignoring the servers (endpoints)
+// of the upstreams in the locations (compared in the upstreams) and
+// the content of the certificates (compared in equalCertificates)
+func equalServers(a, b *Server) bool {
+	if a.SSL != b.SSL ||
+		a.SSLPassthrough != b.SSLPassthrough ||
+		a.SSLCertificate != b.SSLCertificate ||
+		a.SSLCertificateECDSA != b.SSLCertificateECDSA ||
+		a.SSLStaplingFile != b.SSLStaplingFile ||
+		a.SSLStaplingChecksum != b.SSLStaplingChecksum ||
+		!reflect.DeepEqual(a.TLSSettings, b.TLSSettings) ||
+		len(a.Locations) != len(b.Locations) {
+		return false
+	}
+
+	for i := range a.Locations {
+		la := *a.Locations[i]
+		lb := *b.Locations[i]
+		la.Upstream.Backends = nil
+		lb.Upstream.Backends = nil
+		if !reflect.DeepEqual(la, lb) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// equalCertificates compares the checksum of the certificates of two servers
+func equalCertificates(a, b *Server) bool {
+	return a.SSLPemChecksum == b.SSLPemChecksum &&
+		a.SSLPemChecksumECDSA == b.SSLPemChecksumECDSA
+}
+
+// compareUpstreams returns the changes in the servers of an upstream
+// or nil if the upstreams are equal
+func compareUpstreams(prev, cur *Upstream) *UpstreamChanges {
+	if reflect.DeepEqual(prev, cur) {
+		return nil
+	}
+
+	uc := &UpstreamChanges{Name: cur.Name}
+
+	prevServers := map[UpstreamServer]bool{}
+	for _, s := range prev.Backends {
+		prevServers[s] = true
+	}
+	curServers := map[UpstreamServer]bool{}
+	for _, s := range cur.Backends {
+		curServers[s] = true
+		if !prevServers[s] {
+			uc.AddedServers = append(uc.AddedServers, fmt.Sprintf("%v:%v", s.Address, s.Port))
+		}
+	}
+	for _, s := range prev.Backends {
+		if !curServers[s] {
+			uc.RemovedServers = append(uc.RemovedServers, fmt.Sprintf("%v:%v", s.Address, s.Port))
+		}
+	}
+
+	return uc
+}
+
+type upstreamChangesByName []UpstreamChanges
+
+func (c upstreamChangesByName) Len() int      { return len(c) }
+func (c upstreamChangesByName) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
+func (c upstreamChangesByName) Less(i, j int) bool {
+	return c[i].Name < c[j].Name
+}
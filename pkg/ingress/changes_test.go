@@ -0,0 +1,163 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompareConfigurations(t *testing.T) {
+	upstream := func(name string, addresses ...string) *Upstream {
+		u := &Upstream{Name: name}
+		for _, a := range addresses {
+			u.Backends = append(u.Backends, UpstreamServer{Address: a, Port: "80"})
+		}
+		return u
+	}
+	server := func(name string, u *Upstream, path string) *Server {
+		return &Server{
+			Name:      name,
+			Locations: []*Location{{Path: path, Upstream: *u}},
+		}
+	}
+
+	fooUps := upstream("default-foo-80", "10.0.0.1", "10.0.0.2")
+	barUps := upstream("default-bar-80", "10.0.0.3")
+	prev := &Configuration{
+		Upstreams: []*Upstream{fooUps, barUps},
+		Servers: []*Server{
+			server("foo.bar", fooUps, "/"),
+			server("bar.baz", barUps, "/"),
+			server("removed.bar", barUps, "/"),
+		},
+	}
+
+	changes := CompareConfigurations(prev, prev)
+	if !changes.IsEmpty() {
+		t.Errorf("expected no changes but returned %v", changes)
+	}
+
+	newFooUps := upstream("default-foo-80", "10.0.0.2", "10.0.0.4")
+	newUps := upstream("default-new-80", "10.0.0.5")
+	cur := &Configuration{
+		Upstreams: []*Upstream{newFooUps, newUps},
+		Servers: []*Server{
+			// only the endpoints of the upstream changed
+			server("foo.bar", newFooUps, "/"),
+			server("bar.baz", newUps, "/"),
+			server("new.bar", newUps, "/"),
+		},
+	}
+
+	changes = CompareConfigurations(prev, cur)
+	expected := &ConfigurationChanges{
+		AddedServers:     []string{"new.bar"},
+		RemovedServers:   []string{"removed.bar"},
+		ChangedServers:   []string{"bar.baz"},
+		AddedUpstreams:   []string{"default-new-80"},
+		RemovedUpstreams: []string{"default-bar-80"},
+		ChangedUpstreams: []UpstreamChanges{
+			{
+				Name:           "default-foo-80",
+				AddedServers:   []string{"10.0.0.4:80"},
+				RemovedServers: []string{"10.0.0.1:80"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("expected \n%v\nbut returned \n%v", expected, changes)
+	}
+
+	changes = CompareConfigurations(nil, cur)
+	if len(changes.AddedServers) != 3 || len(changes.AddedUpstreams) != 2 {
+		t.Errorf("expected all the servers and upstreams as new but returned %v", changes)
+	}
+}
+
+func TestCompareConfigurationsCertificates(t *testing.T) {
+	server := func(name, cert, checksum string) *Server {
+		return &Server{
+			Name:           name,
+			SSL:            true,
+			SSLCertificate: cert,
+			SSLPemChecksum: checksum,
+		}
+	}
+
+	prev := &Configuration{
+		Servers: []*Server{
+			server("foo.bar", "/ssl/default-foo.pem", "1"),
+			server("bar.baz", "/ssl/default-bar.pem", "1"),
+		},
+	}
+	cur := &Configuration{
+		Servers: []*Server{
+			// only the content of the certificate changed
+			server("foo.bar", "/ssl/default-foo.pem", "2"),
+			// the server uses a different secret
+			server("bar.baz", "/ssl/default-other.pem", "2"),
+		},
+	}
+
+	changes := CompareConfigurations(prev, cur)
+	expected := &ConfigurationChanges{
+		ChangedServers:      []string{"bar.baz"},
+		ChangedCertificates: []string{"foo.bar"},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("expected \n%v\nbut returned \n%v", expected, changes)
+	}
+	if changes.IsEmpty() {
+		t.Errorf("expected changes in the certificates")
+	}
+}
+
+func TestCompareConfigurationsSSLFiles(t *testing.T) {
+	prev := &Configuration{
+		DHParam: &SSLFile{FileName: "/ssl/dhparam-default-dh.pem", Checksum: "1"},
+		SessionTicketKeys: []*SSLFile{
+			{FileName: "/ssl/ticket-default-keys-a.key", Checksum: "1"},
+		},
+	}
+
+	changes := CompareConfigurations(prev, prev)
+	if !changes.IsEmpty() {
+		t.Errorf("expected no changes but returned %v", changes)
+	}
+
+	cur := &Configuration{
+		DHParam: prev.DHParam,
+		SessionTicketKeys: []*SSLFile{
+			{FileName: "/ssl/ticket-default-keys-b.key", Checksum: "2"},
+			{FileName: "/ssl/ticket-default-keys-a.key", Checksum: "1"},
+		},
+	}
+	changes = CompareConfigurations(prev, cur)
+	if !changes.SSLFiles {
+		t.Errorf("expected changes in the session ticket keys but returned %v", changes)
+	}
+
+	cur = &Configuration{
+		DHParam:           &SSLFile{FileName: "/ssl/dhparam-default-dh.pem", Checksum: "2"},
+		SessionTicketKeys: prev.SessionTicketKeys,
+	}
+	changes = CompareConfigurations(prev, cur)
+	if !changes.SSLFiles {
+		t.Errorf("expected changes in the DH parameters but returned %v", changes)
+	}
+}
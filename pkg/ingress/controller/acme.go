@@ -0,0 +1,475 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+
+	"k8s.io/kubernetes/pkg/api"
+	k8s_errors "k8s.io/kubernetes/pkg/api/errors"
+	"k8s.io/kubernetes/pkg/apis/extensions"
+	"k8s.io/kubernetes/pkg/client/cache"
+	client "k8s.io/kubernetes/pkg/client/unversioned"
+	"k8s.io/kubernetes/pkg/fields"
+	"k8s.io/kubernetes/pkg/runtime"
+	"k8s.io/kubernetes/pkg/watch"
+
+	"github.com/aledbf/ingress-controller/pkg/acme"
+	"github.com/aledbf/ingress-controller/pkg/ingress"
+	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/proxy"
+	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/tlsacme"
+	"github.com/aledbf/ingress-controller/pkg/k8s"
+)
+
+const (
+	// port of the server with the responses of the ACME challenges
+	acmeChallengePort = "8183"
+	acmeChallengeAddr = "127.0.0.1:" + acmeChallengePort
+	// upstream used in the locations of the ACME challenges
+	acmeUpstreamName = "upstream-acme-challenge"
+
+	// interval between checks of the certificates obtained using ACME
+	acmeSyncInterval = 5 * time.Minute
+	// certificates are renewed when they expire in less than this time
+	acmeRenewBefore = 30 * 24 * time.Hour
+	// minimum time before requesting again a certificate after an error
+	acmeRetryInterval = time.Hour
+
+	// key in the account secret that contains the private key of the account
+	acmeAccountKey = "account.key"
+)
+
+// acmeManager obtains and renews the certificates of the Ingress rules
+// with the annotation ingress.kubernetes.io/tls-acme
+type acmeManager struct {
+	secrets      client.SecretsNamespacer
+	directoryURL string
+	httpClient   *http.Client
+	email        string
+	// namespace and name of the secret that contains the account key
+	namespace     string
+	accountSecret string
+
+	challenges *challengeStore
+	// client is nil until the account is registered
+	client *acme.Client
+	// time of the last error obtaining the certificate of a secret
+	failures map[string]time.Time
+}
+
+// newACMEManager returns an acmeManager or nil if ACME is not configured
+func newACMEManager(config *Configuration) (*acmeManager, error) {
+	if config.ACMEAccountSecret == "" {
+		return nil, nil
+	}
+
+	ns, name, err := k8s.ParseNameNS(config.ACMEAccountSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient := &http.Client{Timeout: 30 * time.Second}
+	if config.ACMECAFile != "" {
+		ca, err := ioutil.ReadFile(config.ACMECAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no valid certificates found in %v", config.ACMECAFile)
+		}
+		httpClient.Transport = &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: pool},
+		}
+	}
+
+	return &acmeManager{
+		secrets:       config.Client,
+		directoryURL:  config.ACMEDirectory,
+		httpClient:    httpClient,
+		email:         config.ACMEEmail,
+		namespace:     ns,
+		accountSecret: name,
+		challenges:    newChallengeStore(config.Client, ns, name+"-challenges"),
+		failures:      map[string]time.Time{},
+	}, nil
+}
+
+// isACMEEnabled returns true if the certificates of an Ingress rule must
+// be obtained using ACME
+func (ic *GenericController) isACMEEnabled(ing *extensions.Ingress) bool {
+	enabled, err := tlsacme.ParseAnnotations(ing)
+	ic.incAnnotationErrorCount("tlsacme", err)
+	return err == nil && enabled
+}
+
+// syncACME requests the certificates missing or about to expire of the
+// Ingress rules configured to use ACME. Only the leader of the
+// controllers requests certificates
+func (ic *GenericController) syncACME() {
+	if ic.acme == nil || !ic.syncStatus.IsLeader() {
+		return
+	}
+
+	now := time.Now()
+	for _, ingIf := range ic.ingLister.Store.List() {
+		ing := ingIf.(*extensions.Ingress)
+		if !IsValidClass(ing, ic.cfg.IngressClass) || !ic.isACMEEnabled(ing) {
+			continue
+		}
+
+		for _, tls := range ing.Spec.TLS {
+			if tls.SecretName == "" || len(tls.Hosts) == 0 {
+				continue
+			}
+
+			key := fmt.Sprintf("%v/%v", ing.Namespace, tls.SecretName)
+			var secret *api.Secret
+			if obj, exists, _ := ic.secrLister.Store.GetByKey(key); exists {
+				secret = obj.(*api.Secret)
+			}
+			if !acmeRenewalRequired(secret, tls.Hosts, now) {
+				continue
+			}
+
+			if last, ok := ic.acme.failures[key]; ok && now.Sub(last) < acmeRetryInterval {
+				continue
+			}
+
+			hosts := strings.Join(tls.Hosts, ",")
+			glog.Infof("requesting certificate for %v (secret %v) using ACME", hosts, key)
+			err := ic.acme.requestCertificate(ing.Namespace, tls.SecretName, tls.Hosts)
+			if err != nil {
+				ic.acme.failures[key] = now
+				glog.Warningf("error obtaining certificate for %v: %v", hosts, err)
+				ic.recorder.Eventf(ing, api.EventTypeWarning, "ACME", "error obtaining certificate for %v: %v", hosts, err)
+				continue
+			}
+
+			delete(ic.acme.failures, key)
+			ic.recorder.Eventf(ing, api.EventTypeNormal, "ACME", "certificate for %v stored in secret %v", hosts, key)
+		}
+	}
+}
+
+// acmeRenewalRequired returns true if the secret does not contain a
+// certificate valid for all the hosts or the certificate is about to expire
+func acmeRenewalRequired(secret *api.Secret, hosts []string, now time.Time) bool {
+	if secret == nil {
+		return true
+	}
+
+	block, _ := pem.Decode(secret.Data[api.TLSCertKey])
+	if block == nil {
+		return true
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return true
+	}
+
+	if cert.NotAfter.Sub(now) < acmeRenewBefore {
+		return true
+	}
+
+	sslCert := &ingress.SSLCert{CN: append([]string{cert.Subject.CommonName}, cert.DNSNames...)}
+	for _, host := range hosts {
+		if !isHostValid(host, sslCert) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// requestCertificate obtains a certificate for the hosts and stores
+// the certificate and the key in a secret
+func (m *acmeManager) requestCertificate(namespace, name string, hosts []string) error {
+	if m.client == nil {
+		key, err := m.accountKey()
+		if err != nil {
+			return fmt.Errorf("error obtaining account key: %v", err)
+		}
+
+		c := acme.NewClient(m.directoryURL, key)
+		c.HTTPClient = m.httpClient
+		if err := c.Register(m.email); err != nil {
+			return fmt.Errorf("error registering account: %v", err)
+		}
+		m.client = c
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return err
+	}
+
+	cert, err := m.client.ObtainCertificate(hosts, key, m.challenges)
+	if err != nil {
+		return err
+	}
+
+	keyPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	})
+
+	return m.storeCertificate(namespace, name, cert, keyPEM)
+}
+
+// storeCertificate creates or updates the secret with the certificate
+func (m *acmeManager) storeCertificate(namespace, name string, cert, key []byte) error {
+	secret, err := m.secrets.Secrets(namespace).Get(name)
+	if err != nil {
+		if !k8s_errors.IsNotFound(err) {
+			return err
+		}
+
+		_, err = m.secrets.Secrets(namespace).Create(&api.Secret{
+			ObjectMeta: api.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+			},
+			Type: api.SecretTypeTLS,
+			Data: map[string][]byte{
+				api.TLSCertKey:       cert,
+				api.TLSPrivateKeyKey: key,
+			},
+		})
+		return err
+	}
+
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	secret.Data[api.TLSCertKey] = cert
+	secret.Data[api.TLSPrivateKeyKey] = key
+	_, err = m.secrets.Secrets(namespace).Update(secret)
+	return err
+}
+
+// accountKey returns the key of the ACME account stored in the account
+// secret. If the secret does not exists a new key is created
+func (m *acmeManager) accountKey() (*ecdsa.PrivateKey, error) {
+	secret, err := m.secrets.Secrets(m.namespace).Get(m.accountSecret)
+	if err == nil {
+		block, _ := pem.Decode(secret.Data[acmeAccountKey])
+		if block == nil {
+			return nil, fmt.Errorf("secret %v/%v does not contain a valid %v", m.namespace, m.accountSecret, acmeAccountKey)
+		}
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	if !k8s_errors.IsNotFound(err) {
+		return nil, err
+	}
+
+	key, err := acme.NewAccountKey()
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	glog.Infof("creating ACME account key in secret %v/%v", m.namespace, m.accountSecret)
+	_, err = m.secrets.Secrets(m.namespace).Create(&api.Secret{
+		ObjectMeta: api.ObjectMeta{
+			Name:      m.accountSecret,
+			Namespace: m.namespace,
+		},
+		Data: map[string][]byte{
+			acmeAccountKey: pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// addACMEChallengeLocations adds a location for the ACME challenges in
+// the servers of the Ingress rules configured to use ACME
+func (ic *GenericController) addACMEChallengeLocations(ings []interface{},
+	upstreams map[string]*ingress.Upstream, servers map[string]*ingress.Server) {
+
+	if ic.acme == nil {
+		return
+	}
+
+	ups := newUpstream(acmeUpstreamName)
+	ups.Backends = append(ups.Backends, ingress.UpstreamServer{Address: "127.0.0.1", Port: acmeChallengePort})
+	prx := proxy.ParseAnnotations(ic.cfg.Backend.UpstreamDefaults(), nil)
+
+	for _, ingIf := range ings {
+		ing := ingIf.(*extensions.Ingress)
+		if !ic.isACMEEnabled(ing) {
+			continue
+		}
+
+		for _, tls := range ing.Spec.TLS {
+			for _, host := range tls.Hosts {
+				server, ok := servers[host]
+				if !ok || hasLocation(server, acme.ChallengePath) {
+					continue
+				}
+
+				upstreams[acmeUpstreamName] = ups
+				server.Locations = append(server.Locations, &ingress.Location{
+					Path:     acme.ChallengePath,
+					Upstream: *ups,
+					Proxy:    *prx,
+				})
+			}
+		}
+	}
+}
+
+func hasLocation(server *ingress.Server, path string) bool {
+	for _, loc := range server.Locations {
+		if loc.Path == path {
+			return true
+		}
+	}
+	return false
+}
+
+// challengeStore keeps the responses of the ACME challenges in a
+// ConfigMap so every replica of the controller is able to return them
+type challengeStore struct {
+	*acme.HTTP01Solver
+
+	configMaps client.ConfigMapsNamespacer
+	namespace  string
+	name       string
+
+	// local copy of the ConfigMap used to return the responses without
+	// requests to the API server (the challenge server is public)
+	mapStore      cache.Store
+	mapController *cache.Controller
+}
+
+func newChallengeStore(configMaps client.ConfigMapsNamespacer, namespace, name string) *challengeStore {
+	s := &challengeStore{
+		HTTP01Solver: acme.NewHTTP01Solver(),
+		configMaps:   configMaps,
+		namespace:    namespace,
+		name:         name,
+	}
+
+	selector := fields.OneTermEqualSelector("metadata.name", name)
+	s.mapStore, s.mapController = cache.NewInformer(
+		&cache.ListWatch{
+			ListFunc: func(opts api.ListOptions) (runtime.Object, error) {
+				opts.FieldSelector = selector
+				return configMaps.ConfigMaps(namespace).List(opts)
+			},
+			WatchFunc: func(opts api.ListOptions) (watch.Interface, error) {
+				opts.FieldSelector = selector
+				return configMaps.ConfigMaps(namespace).Watch(opts)
+			},
+		},
+		&api.ConfigMap{}, 0, cache.ResourceEventHandlerFuncs{})
+
+	return s
+}
+
+// run keeps the local copy of the ConfigMap updated until stopCh is closed
+func (s *challengeStore) run(stopCh chan struct{}) {
+	s.mapController.Run(stopCh)
+}
+
+// Present implements acme.ChallengeSolver
+func (s *challengeStore) Present(token, keyAuth string) error {
+	s.HTTP01Solver.Present(token, keyAuth)
+
+	cmap, err := s.configMaps.ConfigMaps(s.namespace).Get(s.name)
+	if err != nil {
+		if !k8s_errors.IsNotFound(err) {
+			return err
+		}
+		_, err = s.configMaps.ConfigMaps(s.namespace).Create(&api.ConfigMap{
+			ObjectMeta: api.ObjectMeta{
+				Name:      s.name,
+				Namespace: s.namespace,
+			},
+			Data: map[string]string{token: keyAuth},
+		})
+		return err
+	}
+
+	if cmap.Data == nil {
+		cmap.Data = map[string]string{}
+	}
+	cmap.Data[token] = keyAuth
+	_, err = s.configMaps.ConfigMaps(s.namespace).Update(cmap)
+	return err
+}
+
+// CleanUp implements acme.ChallengeSolver
+func (s *challengeStore) CleanUp(token string) error {
+	s.HTTP01Solver.CleanUp(token)
+
+	cmap, err := s.configMaps.ConfigMaps(s.namespace).Get(s.name)
+	if err != nil {
+		return err
+	}
+	if _, ok := cmap.Data[token]; !ok {
+		return nil
+	}
+	delete(cmap.Data, token)
+	_, err = s.configMaps.ConfigMaps(s.namespace).Update(cmap)
+	return err
+}
+
+// ServeHTTP returns the response of a challenge. If the challenge was not
+// created in this replica the response is obtained from the local copy
+// of the ConfigMap
+func (s *challengeStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.URL.Path, acme.ChallengePath)
+	if _, ok := s.KeyAuthorization(token); ok {
+		s.HTTP01Solver.ServeHTTP(w, r)
+		return
+	}
+
+	obj, exists, err := s.mapStore.GetByKey(s.namespace + "/" + s.name)
+	if err != nil || !exists || !strings.HasPrefix(r.URL.Path, acme.ChallengePath) {
+		http.NotFound(w, r)
+		return
+	}
+
+	keyAuth, ok := obj.(*api.ConfigMap).Data[token]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte(keyAuth))
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/client/unversioned/testclient"
+	"k8s.io/kubernetes/pkg/util/wait"
+
+	"github.com/aledbf/ingress-controller/pkg/acme"
+	"github.com/aledbf/ingress-controller/pkg/ingress"
+)
+
+func newCertSecret(t *testing.T, notAfter time.Time, hosts ...string) *api.Secret {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: hosts[0]},
+		NotBefore:    notAfter.Add(-90 * 24 * time.Hour),
+		NotAfter:     notAfter,
+		DNSNames:     hosts,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return &api.Secret{
+		Data: map[string][]byte{
+			api.TLSCertKey: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		},
+	}
+}
+
+func TestACMERenewalRequired(t *testing.T) {
+	now := time.Now()
+	valid := newCertSecret(t, now.Add(60*24*time.Hour), "foo.bar", "*.foo.bar")
+
+	tests := map[string]struct {
+		secret   *api.Secret
+		hosts    []string
+		expected bool
+	}{
+		"missing secret":   {nil, []string{"foo.bar"}, true},
+		"invalid secret":   {&api.Secret{}, []string{"foo.bar"}, true},
+		"valid":            {valid, []string{"foo.bar", "www.foo.bar"}, false},
+		"host not covered": {valid, []string{"foo.bar", "bar.baz"}, true},
+		"expiring":         {newCertSecret(t, now.Add(10*24*time.Hour), "foo.bar"), []string{"foo.bar"}, true},
+	}
+
+	for title, tc := range tests {
+		if r := acmeRenewalRequired(tc.secret, tc.hosts, now); r != tc.expected {
+			t.Errorf("%v: expected %v but returned %v", title, tc.expected, r)
+		}
+	}
+}
+
+func TestAddACMEChallengeLocations(t *testing.T) {
+	ic := &GenericController{
+		cfg:  &Configuration{Backend: &fakeBackend{}},
+		acme: &acmeManager{},
+	}
+
+	enabled := newTLSIngress("enabled", "foo", "foo.bar")
+	enabled.Annotations = map[string]string{"ingress.kubernetes.io/tls-acme": "true"}
+	disabled := newTLSIngress("disabled", "bar", "bar.baz")
+
+	upstreams := map[string]*ingress.Upstream{}
+	servers := map[string]*ingress.Server{
+		"foo.bar": {Name: "foo.bar", Locations: []*ingress.Location{{Path: "/"}}},
+		"bar.baz": {Name: "bar.baz", Locations: []*ingress.Location{{Path: "/"}}},
+	}
+
+	ic.addACMEChallengeLocations([]interface{}{enabled, disabled}, upstreams, servers)
+
+	if len(servers["foo.bar"].Locations) != 2 {
+		t.Fatalf("expected a location for the ACME challenges in foo.bar")
+	}
+	loc := servers["foo.bar"].Locations[1]
+	if loc.Path != acme.ChallengePath || loc.Upstream.Name != acmeUpstreamName {
+		t.Errorf("unexpected location for the ACME challenges: %v %v", loc.Path, loc.Upstream.Name)
+	}
+	if len(servers["bar.baz"].Locations) != 1 {
+		t.Errorf("expected no location for the ACME challenges in bar.baz")
+	}
+	if _, ok := upstreams[acmeUpstreamName]; !ok {
+		t.Errorf("expected the upstream %v", acmeUpstreamName)
+	}
+
+	ic.addACMEChallengeLocations([]interface{}{enabled}, upstreams, servers)
+	if len(servers["foo.bar"].Locations) != 2 {
+		t.Errorf("expected only one location for the ACME challenges")
+	}
+}
+
+func TestChallengeStore(t *testing.T) {
+	fk := testclient.NewSimpleFake(&api.ConfigMap{
+		ObjectMeta: api.ObjectMeta{Name: "acme-challenges", Namespace: api.NamespaceDefault},
+	})
+	s := newChallengeStore(fk, api.NamespaceDefault, "acme-challenges")
+	if err := s.Present("abc", "abc.thumbprint"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var updated *api.ConfigMap
+	for _, action := range fk.Actions() {
+		if a, ok := action.(testclient.UpdateAction); ok {
+			updated = a.GetObject().(*api.ConfigMap)
+		}
+	}
+	if updated == nil || updated.Data["abc"] != "abc.thumbprint" {
+		t.Fatalf("expected the challenge in the configmap but returned %v", updated)
+	}
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", acme.ChallengePath+"abc", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "abc.thumbprint" {
+		t.Errorf("expected the response of the challenge but returned %v %v", w.Code, w.Body.String())
+	}
+
+	// a challenge created by other replica is obtained from the configmap
+	otherClient := testclient.NewSimpleFake(updated)
+	other := newChallengeStore(otherClient, api.NamespaceDefault, "acme-challenges")
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go other.run(stopCh)
+	if err := wait.Poll(10*time.Millisecond, time.Second, func() (bool, error) {
+		return other.mapController.HasSynced(), nil
+	}); err != nil {
+		t.Fatalf("the configmap was not synced: %v", err)
+	}
+	actions := len(otherClient.Actions())
+
+	w = httptest.NewRecorder()
+	other.ServeHTTP(w, httptest.NewRequest("GET", acme.ChallengePath+"abc", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "abc.thumbprint" {
+		t.Errorf("expected the response of the challenge but returned %v %v", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	other.ServeHTTP(w, httptest.NewRequest("GET", acme.ChallengePath+"xyz", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected %v for an unknown token but returned %v", http.StatusNotFound, w.Code)
+	}
+
+	if n := len(otherClient.Actions()); n != actions {
+		t.Errorf("expected no requests to the API server but returned %v", otherClient.Actions()[actions:])
+	}
+}
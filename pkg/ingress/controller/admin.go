@@ -0,0 +1,258 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/golang/groupcache/lru"
+
+	"k8s.io/kubernetes/pkg/api"
+	authenticationapi "k8s.io/kubernetes/pkg/apis/authentication"
+	authentication "k8s.io/kubernetes/pkg/client/clientset_generated/internalclientset/typed/authentication/unversioned"
+	client "k8s.io/kubernetes/pkg/client/unversioned"
+
+	"github.com/aledbf/ingress-controller/pkg/k8s"
+)
+
+// adminOptions configures the server of the admin and debug endpoints
+type adminOptions struct {
+	// address where the server listens
+	address string
+	// enableProfiling exposes the pprof endpoints
+	enableProfiling bool
+	// enableStop exposes the /stop endpoint that stops the controller
+	enableStop bool
+	// authn checks the bearer token of each request. If nil the
+	// requests are not authenticated
+	authn authenticator
+	// tlsConfig enables HTTPS. If nil the server uses HTTP
+	tlsConfig *tls.Config
+}
+
+// authenticator checks if a bearer token is allowed to use the admin endpoints
+type authenticator interface {
+	authenticate(token string) (bool, error)
+}
+
+// staticToken allows only requests with a fixed token
+type staticToken string
+
+func (s staticToken) authenticate(token string) (bool, error) {
+	return subtle.ConstantTimeCompare([]byte(s), []byte(token)) == 1, nil
+}
+
+const (
+	// tokenReviewTTL is the time the result of a TokenReview is reused
+	// before asking the apiserver again
+	tokenReviewTTL = 10 * time.Second
+
+	// tokenReviewCacheSize is the maximum number of tokens with a
+	// cached result
+	tokenReviewCacheSize = 1024
+)
+
+// tokenReview validates the token using the TokenReview API of the
+// apiserver. The user of the token must be one of the users in the list.
+// The results are cached for tokenReviewTTL
+type tokenReview struct {
+	client authentication.TokenReviewsGetter
+	users  []string
+
+	mu    sync.Mutex
+	cache *lru.Cache
+}
+
+// reviewResult is the cached result of a TokenReview
+type reviewResult struct {
+	allowed bool
+	expires time.Time
+}
+
+func newTokenReview(client authentication.TokenReviewsGetter, users []string) *tokenReview {
+	return &tokenReview{
+		client: client,
+		users:  users,
+		cache:  lru.New(tokenReviewCacheSize),
+	}
+}
+
+func (t *tokenReview) authenticate(token string) (bool, error) {
+	key := fmt.Sprintf("%x", sha256.Sum256([]byte(token)))
+
+	t.mu.Lock()
+	if v, ok := t.cache.Get(key); ok {
+		r := v.(reviewResult)
+		if time.Now().Before(r.expires) {
+			t.mu.Unlock()
+			return r.allowed, nil
+		}
+		t.cache.Remove(key)
+	}
+	t.mu.Unlock()
+
+	allowed, err := t.review(token)
+	if err != nil {
+		return false, err
+	}
+
+	t.mu.Lock()
+	t.cache.Add(key, reviewResult{allowed, time.Now().Add(tokenReviewTTL)})
+	t.mu.Unlock()
+
+	return allowed, nil
+}
+
+// review creates a TokenReview and checks if the user is in the list
+func (t *tokenReview) review(token string) (bool, error) {
+	tr, err := t.client.TokenReviews().Create(&authenticationapi.TokenReview{
+		Spec: authenticationapi.TokenReviewSpec{
+			Token: token,
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if tr.Status.Error != "" {
+		return false, fmt.Errorf("%v", tr.Status.Error)
+	}
+
+	if !tr.Status.Authenticated {
+		return false, nil
+	}
+
+	for _, user := range t.users {
+		if user == tr.Status.User.Username {
+			return true, nil
+		}
+	}
+
+	glog.Warningf("user %v is not allowed to use the admin endpoints", tr.Status.User.Username)
+	return false, nil
+}
+
+// withAuthentication returns a handler that only executes h if the
+// request contains a valid bearer token
+func withAuthentication(authn authenticator, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := bearerToken(r)
+		if token == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="ingress-controller"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ok, err := authn.authenticate(token)
+		if err != nil {
+			glog.Errorf("error authenticating request to %v: %v", r.URL.Path, err)
+		}
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// bearerToken returns the token in the Authorization header of a request
+func bearerToken(r *http.Request) string {
+	parts := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+	if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+		return ""
+	}
+	return strings.TrimSpace(parts[1])
+}
+
+// newAdminHandler returns the handler of the admin and debug endpoints
+func newAdminHandler(opts adminOptions, ic *GenericController) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.Handle(historyPath, ic.history)
+	mux.Handle(historyPath+"/", ic.history)
+
+	mux.HandleFunc("/build", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, ic.Info())
+	})
+
+	if opts.enableStop {
+		mux.HandleFunc("/stop", func(w http.ResponseWriter, r *http.Request) {
+			syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
+		})
+	}
+
+	if opts.enableProfiling {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+
+	if opts.authn == nil {
+		return mux
+	}
+	return withAuthentication(opts.authn, mux)
+}
+
+// runAdminServer starts the server of the admin and debug endpoints
+func runAdminServer(opts adminOptions, ic *GenericController) {
+	server := &http.Server{
+		Addr:      opts.address,
+		Handler:   newAdminHandler(opts, ic),
+		TLSConfig: opts.tlsConfig,
+	}
+
+	if opts.tlsConfig != nil {
+		glog.Fatal(server.ListenAndServeTLS("", ""))
+	}
+	glog.Fatal(server.ListenAndServe())
+}
+
+// getAdminTLSConfig returns a TLS configuration that uses the certificate
+// and key from the secret with the format namespace/name
+func getAdminTLSConfig(kubeClient *client.Client, secretName string) (*tls.Config, error) {
+	ns, name, err := k8s.ParseNameNS(secretName)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := kubeClient.Secrets(ns).Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("error obtaining secret %v: %v", secretName, err)
+	}
+
+	cert, err := tls.X509KeyPair(secret.Data[api.TLSCertKey], secret.Data[api.TLSPrivateKeyKey])
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate in secret %v: %v", secretName, err)
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+	}, nil
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	authenticationapi "k8s.io/kubernetes/pkg/apis/authentication"
+	authentication "k8s.io/kubernetes/pkg/client/clientset_generated/internalclientset/typed/authentication/unversioned"
+)
+
+// fakeTokenReviews authenticates the tokens in the map returning the user
+type fakeTokenReviews map[string]string
+
+func (f fakeTokenReviews) TokenReviews() authentication.TokenReviewInterface {
+	return f
+}
+
+func (f fakeTokenReviews) Create(tr *authenticationapi.TokenReview) (*authenticationapi.TokenReview, error) {
+	user, ok := f[tr.Spec.Token]
+	tr.Status.Authenticated = ok
+	tr.Status.User.Username = user
+	return tr, nil
+}
+
+func TestAdminAuthentication(t *testing.T) {
+	ic := &GenericController{
+		cfg:     &Configuration{Backend: &fakeBackend{}},
+		history: newConfigurationHistory(1),
+	}
+
+	reviews := fakeTokenReviews{
+		"admin-token": "admin",
+		"other-token": "system:serviceaccount:default:default",
+	}
+
+	testCases := map[string]struct {
+		authn  authenticator
+		header string
+		code   int
+	}{
+		"no authentication":            {nil, "", http.StatusOK},
+		"missing token":                {staticToken("secret"), "", http.StatusUnauthorized},
+		"invalid token":                {staticToken("secret"), "Bearer invalid", http.StatusUnauthorized},
+		"basic authentication":         {staticToken("secret"), "Basic secret", http.StatusUnauthorized},
+		"valid token":                  {staticToken("secret"), "Bearer secret", http.StatusOK},
+		"token review unknown token":   {newTokenReview(reviews, []string{"admin"}), "Bearer invalid", http.StatusUnauthorized},
+		"token review no users":        {newTokenReview(reviews, []string{}), "Bearer admin-token", http.StatusUnauthorized},
+		"token review user not listed": {newTokenReview(reviews, []string{"admin"}), "Bearer other-token", http.StatusUnauthorized},
+		"token review user listed":     {newTokenReview(reviews, []string{"admin"}), "Bearer admin-token", http.StatusOK},
+	}
+
+	for n, tc := range testCases {
+		h := newAdminHandler(adminOptions{authn: tc.authn}, ic)
+		req, _ := http.NewRequest("GET", "/build", nil)
+		if tc.header != "" {
+			req.Header.Set("Authorization", tc.header)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != tc.code {
+			t.Errorf("%v: expected status code %v but returned %v", n, tc.code, w.Code)
+		}
+	}
+}
+
+// countingTokenReviews counts the TokenReviews created
+type countingTokenReviews struct {
+	fakeTokenReviews
+	count int
+}
+
+func (c *countingTokenReviews) TokenReviews() authentication.TokenReviewInterface {
+	return c
+}
+
+func (c *countingTokenReviews) Create(tr *authenticationapi.TokenReview) (*authenticationapi.TokenReview, error) {
+	c.count++
+	return c.fakeTokenReviews.Create(tr)
+}
+
+func TestTokenReviewCache(t *testing.T) {
+	reviews := &countingTokenReviews{fakeTokenReviews: fakeTokenReviews{"admin-token": "admin"}}
+	tr := newTokenReview(reviews, []string{"admin"})
+
+	for i := 0; i < 3; i++ {
+		for token, expected := range map[string]bool{"admin-token": true, "invalid": false} {
+			ok, err := tr.authenticate(token)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ok != expected {
+				t.Errorf("expected %v for token %v but returned %v", expected, token, ok)
+			}
+		}
+	}
+
+	if reviews.count != 2 {
+		t.Errorf("expected 2 token reviews but %v were created", reviews.count)
+	}
+
+	key := fmt.Sprintf("%x", sha256.Sum256([]byte("admin-token")))
+	tr.cache.Add(key, reviewResult{true, time.Now().Add(-time.Second)})
+	if _, err := tr.authenticate("admin-token"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if reviews.count != 3 {
+		t.Errorf("expected a new token review after the expiration but %v were created", reviews.count)
+	}
+}
+
+func TestAdminDisabledEndpoints(t *testing.T) {
+	ic := &GenericController{
+		cfg:     &Configuration{Backend: &fakeBackend{}},
+		history: newConfigurationHistory(1),
+	}
+
+	h := newAdminHandler(adminOptions{}, ic)
+	for _, path := range []string{"/stop", "/debug/pprof/"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status code %v for %v but returned %v", http.StatusNotFound, path, w.Code)
+		}
+	}
+}
@@ -18,6 +18,7 @@ package controller
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -27,6 +28,7 @@ import (
 
 	"github.com/aledbf/ingress-controller/pkg/ingress"
 	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/parser"
+	"github.com/aledbf/ingress-controller/pkg/k8s"
 	ssl "github.com/aledbf/ingress-controller/pkg/net/ssl"
 	"github.com/golang/glog"
 )
@@ -42,30 +44,12 @@ func (ic *GenericController) syncSecret(k interface{}) error {
 		return fmt.Errorf("deferring sync till endpoints controller has synced")
 	}
 
-	// check if the default certificate is configured
-	key := fmt.Sprintf("default/%v", defServerName)
-	_, exists := ic.sslCertTracker.Get(key)
-	var cert *ingress.SSLCert
-	var err error
-	if !exists {
-		if ic.cfg.DefaultSSLCertificate != "" {
-			cert, err = ic.getPemCertificate(ic.cfg.DefaultSSLCertificate)
-			if err != nil {
-				return err
-			}
-		} else {
-			defCert, defKey := ssl.GetFakeSSLCert()
-			cert, err = ssl.AddOrUpdateCertAndKey("system-snake-oil-certificate", defCert, defKey, []byte{})
-			if err != nil {
-				return nil
-			}
-		}
-		cert.Name = defServerName
-		cert.Namespace = api.NamespaceDefault
-		ic.sslCertTracker.Add(key, cert)
+	err := ic.syncDefaultSSLCertificate()
+	if err != nil {
+		return err
 	}
 
-	key = k.(string)
+	key := k.(string)
 
 	// get secret
 	secObj, exists, err := ic.secrLister.Store.GetByKey(key)
@@ -81,7 +65,7 @@ func (ic *GenericController) syncSecret(k interface{}) error {
 		return nil
 	}
 
-	cert, err = ic.getPemCertificate(key)
+	cert, err := ic.getPemCertificate(key)
 	if err != nil {
 		return err
 	}
@@ -96,6 +80,69 @@ func (ic *GenericController) syncSecret(k interface{}) error {
 	return nil
 }
 
+// syncDefaultSSLCertificate adds the default certificate to the tracker.
+// Without a default certificate configured a self-signed certificate is
+// used, generated again when it is about to expire
+func (ic *GenericController) syncDefaultSSLCertificate() error {
+	key := fmt.Sprintf("default/%v", defServerName)
+	current, exists := ic.sslCertTracker.Get(key)
+	if exists && (ic.cfg.DefaultSSLCertificate != "" ||
+		!ssl.FakeSSLCertRenewalRequired(current.(*ingress.SSLCert), time.Now())) {
+		return nil
+	}
+
+	var cert *ingress.SSLCert
+	var err error
+	if ic.cfg.DefaultSSLCertificate != "" {
+		cert, err = ic.getPemCertificate(ic.cfg.DefaultSSLCertificate)
+		if err != nil {
+			return err
+		}
+	} else {
+		defCert, defKey, err := ssl.GetFakeSSLCert(ic.cfg.FakeCertificateCN, ic.cfg.FakeCertificateKeyType)
+		if err != nil {
+			return fmt.Errorf("error generating fake certificate: %v", err)
+		}
+		cert, err = ssl.AddOrUpdateCertAndKey(ssl.FakeCertificateName, defCert, defKey, []byte{})
+		if err != nil {
+			return fmt.Errorf("error creating fake certificate: %v", err)
+		}
+		glog.Infof("using fake certificate %v (expires %v)", cert.PemFileName, cert.NotAfter)
+	}
+
+	cert.Name = defServerName
+	cert.Namespace = api.NamespaceDefault
+	if exists {
+		ic.sslCertTracker.Update(key, cert)
+		return nil
+	}
+	ic.sslCertTracker.Add(key, cert)
+	return nil
+}
+
+// checkFakeSSLCertificate generates again the fake certificate when it is
+// about to expire and updates the configuration of the backend
+func (ic *GenericController) checkFakeSSLCertificate() {
+	key := fmt.Sprintf("default/%v", defServerName)
+	current, exists := ic.sslCertTracker.Get(key)
+	if ic.cfg.DefaultSSLCertificate != "" || !exists ||
+		!ssl.FakeSSLCertRenewalRequired(current.(*ingress.SSLCert), time.Now()) {
+		return
+	}
+
+	err := ic.syncDefaultSSLCertificate()
+	if err != nil {
+		glog.Warningf("%v", err)
+		return
+	}
+	ic.syncQueue.Enqueue(&api.Secret{
+		ObjectMeta: api.ObjectMeta{
+			Namespace: api.NamespaceDefault,
+			Name:      ssl.FakeCertificateName,
+		},
+	})
+}
+
 func (ic *GenericController) getPemCertificate(secretName string) (*ingress.SSLCert, error) {
 	secretInterface, exists, err := ic.secrLister.Store.GetByKey(secretName)
 	if err != nil {
@@ -133,7 +180,7 @@ func (ic *GenericController) secrReferenced(name, namespace string) bool {
 	for _, ingIf := range ic.ingLister.Store.List() {
 		ing := ingIf.(*extensions.Ingress)
 		str, err := parser.GetStringAnnotation("ingress.kubernetes.io/auth-tls-secret", ing)
-		if err == nil && str == fmt.Sprintf("%v/%v", namespace, name) {
+		if err == nil && str == fmt.Sprintf("%v/%v", namespace, name) && ic.secretAllowed(ing, str) {
 			return true
 		}
 
@@ -149,6 +196,147 @@ func (ic *GenericController) secrReferenced(name, namespace string) bool {
 	return false
 }
 
+// secretAllowed checks if an Ingress rule can reference a Secret
+// (namespace/name) of other namespace
+func (ic *GenericController) secretAllowed(ing *extensions.Ingress, secretName string) bool {
+	if !ic.cfg.RestrictSecretNamespaces {
+		return true
+	}
+	namespace, _, err := k8s.ParseNameNS(secretName)
+	if err != nil {
+		return false
+	}
+	if namespace == ing.Namespace {
+		return true
+	}
+	for _, ns := range ic.cfg.AllowedSecretNamespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// syncSecrReferenced checks if a secret is written in the sync of
+// the configuration instead of the sync of the secrets
+func (ic *GenericController) syncSecrReferenced(sec *api.Secret) bool {
+	return ic.authSecrReferenced(sec.Name, sec.Namespace) ||
+		ic.sslFilesSecrReferenced(sec.Name, sec.Namespace)
+}
+
+// authSecrReferenced checks if a secret is used for authentication in an Ingress rule
+func (ic *GenericController) authSecrReferenced(name, namespace string) bool {
+	for _, ingIf := range ic.ingLister.Store.List() {
+		ing := ingIf.(*extensions.Ingress)
+		if ing.Namespace != namespace {
+			continue
+		}
+		str, err := parser.GetStringAnnotation("ingress.kubernetes.io/auth-secret", ing)
+		if err == nil && str == name {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	// keys of the configmap with the name (<namespace>/<name>) of the Secrets
+	// with the DH parameters and the TLS session ticket keys
+	dhParamConfigKey          = "ssl-dh-param"
+	sessionTicketKeyConfigKey = "ssl-session-ticket-key"
+
+	// key of the Secret with the DH parameters
+	dhParamSecretKey = "dhparam.pem"
+)
+
+// getSSLFiles writes the DH parameters and the session ticket keys
+// contained in the Secrets referenced in the configmap. Invalid or
+// missing Secrets are ignored and the backend uses its defaults
+func (ic *GenericController) getSSLFiles(cfg *api.ConfigMap) (*ingress.SSLFile, []*ingress.SSLFile) {
+	var dhParam *ingress.SSLFile
+	if name := cfg.Data[dhParamConfigKey]; name != "" {
+		f, err := ic.getDHParam(name)
+		if err != nil {
+			glog.Warningf("ignoring DH parameters from secret %v: %v", name, err)
+		} else {
+			dhParam = f
+		}
+	}
+
+	var ticketKeys []*ingress.SSLFile
+	if name := cfg.Data[sessionTicketKeyConfigKey]; name != "" {
+		keys, err := ic.getSessionTicketKeys(name)
+		if err != nil {
+			glog.Warningf("ignoring session ticket keys from secret %v: %v", name, err)
+		} else {
+			ticketKeys = keys
+		}
+	}
+
+	return dhParam, ticketKeys
+}
+
+func (ic *GenericController) getDHParam(secretName string) (*ingress.SSLFile, error) {
+	secret, err := ic.getSecret(secretName)
+	if err != nil {
+		return nil, err
+	}
+	dh, ok := secret.Data[dhParamSecretKey]
+	if !ok {
+		return nil, fmt.Errorf("secret has no %v", dhParamSecretKey)
+	}
+	return ssl.AddOrUpdateDHParam(strings.Replace(secretName, "/", "-", -1), dh)
+}
+
+// getSessionTicketKeys returns the keys contained in a Secret. All the
+// replicas of the controller use the same keys, so tickets created by
+// one replica are accepted by the rest. The keys are sorted by name and
+// the last one is used to encrypt new tickets, which allows rotating
+// them adding a new key (ie named with a timestamp) and removing the
+// oldest key once the tickets encrypted with it expired
+func (ic *GenericController) getSessionTicketKeys(secretName string) ([]*ingress.SSLFile, error) {
+	secret, err := ic.getSecret(secretName)
+	if err != nil {
+		return nil, err
+	}
+	if len(secret.Data) == 0 {
+		return nil, fmt.Errorf("secret has no keys")
+	}
+
+	names := []string{}
+	for name := range secret.Data {
+		names = append(names, name)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+
+	prefix := strings.Replace(secretName, "/", "-", -1)
+	keys := []*ingress.SSLFile{}
+	for _, name := range names {
+		f, err := ssl.AddOrUpdateSessionTicketKey(fmt.Sprintf("%v-%v", prefix, name), secret.Data[name])
+		if err != nil {
+			return nil, fmt.Errorf("key %v: %v", name, err)
+		}
+		keys = append(keys, f)
+	}
+	return keys, nil
+}
+
+// sslFilesSecrReferenced checks if a secret contains the DH parameters
+// or the session ticket keys defined in the configmap
+func (ic *GenericController) sslFilesSecrReferenced(name, namespace string) bool {
+	if ic.cfg.ConfigMapName == "" {
+		return false
+	}
+	obj, exists, err := ic.mapLister.Store.GetByKey(ic.cfg.ConfigMapName)
+	if err != nil || !exists {
+		return false
+	}
+
+	key := fmt.Sprintf("%v/%v", namespace, name)
+	cfg := obj.(*api.ConfigMap)
+	return cfg.Data[dhParamConfigKey] == key || cfg.Data[sessionTicketKeyConfigKey] == key
+}
+
 // sslCertTracker ...
 type sslCertTracker struct {
 	cache.ThreadSafeStore
@@ -0,0 +1,155 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/pem"
+	"os"
+	"strings"
+	"testing"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/apis/extensions"
+	"k8s.io/kubernetes/pkg/client/cache"
+)
+
+func TestGetSSLFiles(t *testing.T) {
+	dh := pem.EncodeToMemory(&pem.Block{Type: "DH PARAMETERS", Bytes: []byte("dh")})
+	secrets := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	secrets.Add(&api.Secret{
+		ObjectMeta: api.ObjectMeta{Namespace: api.NamespaceDefault, Name: "dh"},
+		Data:       map[string][]byte{dhParamSecretKey: dh},
+	})
+	secrets.Add(&api.Secret{
+		ObjectMeta: api.ObjectMeta{Namespace: api.NamespaceDefault, Name: "tickets"},
+		Data: map[string][]byte{
+			"1490000000": []byte(strings.Repeat("a", 48)),
+			"1490100000": []byte(strings.Repeat("b", 80)),
+		},
+	})
+	secrets.Add(&api.Secret{
+		ObjectMeta: api.ObjectMeta{Namespace: api.NamespaceDefault, Name: "invalid"},
+		Data:       map[string][]byte{dhParamSecretKey: []byte("dh"), "key": []byte("short")},
+	})
+
+	maps := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	cfg := &api.ConfigMap{
+		ObjectMeta: api.ObjectMeta{Namespace: api.NamespaceDefault, Name: "nginx"},
+		Data: map[string]string{
+			dhParamConfigKey:          "default/dh",
+			sessionTicketKeyConfigKey: "default/tickets",
+		},
+	}
+	maps.Add(cfg)
+
+	ic := &GenericController{
+		cfg: &Configuration{ConfigMapName: "default/nginx"},
+	}
+	ic.secrLister.Store = secrets
+	ic.mapLister.Store = maps
+
+	dhParam, keys := ic.getSSLFiles(cfg)
+	if dhParam == nil {
+		t.Fatalf("expected DH parameters but returned nil")
+	}
+	defer os.Remove(dhParam.FileName)
+	if dhParam.Checksum == "" {
+		t.Errorf("expected a checksum of the DH parameters")
+	}
+	if len(keys) != 2 {
+		t.Fatalf("expected 2 session ticket keys but returned %v", len(keys))
+	}
+	for _, key := range keys {
+		defer os.Remove(key.FileName)
+	}
+	// the newest key encrypts the tickets
+	if !strings.HasSuffix(keys[0].FileName, "1490100000.key") {
+		t.Errorf("expected the key 1490100000 first but returned %v", keys[0].FileName)
+	}
+
+	if !ic.sslFilesSecrReferenced("dh", api.NamespaceDefault) ||
+		!ic.sslFilesSecrReferenced("tickets", api.NamespaceDefault) {
+		t.Errorf("expected the secrets referenced in the configmap")
+	}
+	if ic.sslFilesSecrReferenced("invalid", api.NamespaceDefault) {
+		t.Errorf("expected the secret invalid not referenced")
+	}
+
+	invalid := &api.ConfigMap{
+		Data: map[string]string{
+			dhParamConfigKey:          "default/invalid",
+			sessionTicketKeyConfigKey: "default/invalid",
+		},
+	}
+	dhParam, keys = ic.getSSLFiles(invalid)
+	if dhParam != nil || keys != nil {
+		t.Errorf("expected invalid secrets to be ignored but returned %v %v", dhParam, keys)
+	}
+
+	dhParam, keys = ic.getSSLFiles(&api.ConfigMap{})
+	if dhParam != nil || keys != nil {
+		t.Errorf("expected no SSL files without configuration but returned %v %v", dhParam, keys)
+	}
+}
+
+func TestSecretAllowed(t *testing.T) {
+	ing := &extensions.Ingress{ObjectMeta: api.ObjectMeta{Namespace: "tenant-a", Name: "foo"}}
+
+	tests := []struct {
+		restrict bool
+		secret   string
+		expected bool
+	}{
+		{false, "tenant-b/ca", true},
+		{true, "tenant-a/ca", true},
+		{true, "tenant-b/ca", false},
+		{true, "shared/ca", true},
+		{true, "invalid", false},
+	}
+
+	for _, test := range tests {
+		ic := &GenericController{cfg: &Configuration{
+			RestrictSecretNamespaces: test.restrict,
+			AllowedSecretNamespaces:  []string{"shared"},
+		}}
+		if r := ic.secretAllowed(ing, test.secret); r != test.expected {
+			t.Errorf("expected %v but returned %v for secret %v (restricted: %v)", test.expected, r, test.secret, test.restrict)
+		}
+	}
+}
+
+func TestSecrReferencedRestricted(t *testing.T) {
+	ings := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	ings.Add(&extensions.Ingress{
+		ObjectMeta: api.ObjectMeta{
+			Namespace:   "tenant-a",
+			Name:        "foo",
+			Annotations: map[string]string{"ingress.kubernetes.io/auth-tls-secret": "tenant-b/ca"},
+		},
+	})
+
+	ic := &GenericController{cfg: &Configuration{}}
+	ic.ingLister.Store = ings
+	if !ic.secrReferenced("ca", "tenant-b") {
+		t.Errorf("expected secret tenant-b/ca referenced without restrictions")
+	}
+
+	ic.cfg.RestrictSecretNamespaces = true
+	if ic.secrReferenced("ca", "tenant-b") {
+		t.Errorf("expected secret tenant-b/ca not referenced with restrictions")
+	}
+}
@@ -38,24 +38,32 @@ import (
 	"k8s.io/kubernetes/pkg/runtime"
 	"k8s.io/kubernetes/pkg/util/flowcontrol"
 	"k8s.io/kubernetes/pkg/util/intstr"
+	"k8s.io/kubernetes/pkg/util/wait"
 	"k8s.io/kubernetes/pkg/watch"
 
 	cache_store "github.com/aledbf/ingress-controller/pkg/cache"
+	"github.com/aledbf/ingress-controller/pkg/errorpage"
 	"github.com/aledbf/ingress-controller/pkg/ingress"
 	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/auth"
 	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/authreq"
 	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/authtls"
 	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/cors"
+	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/customerrors"
+	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/defaultbackend"
+	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/globalratelimit"
 	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/healthcheck"
 	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/ipwhitelist"
+	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/parser"
 	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/proxy"
 	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/ratelimit"
 	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/rewrite"
 	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/secureupstream"
 	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/service"
 	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/sslpassthrough"
+	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/tlssettings"
 	"github.com/aledbf/ingress-controller/pkg/ingress/status"
 	"github.com/aledbf/ingress-controller/pkg/k8s"
+	"github.com/aledbf/ingress-controller/pkg/net/ssl"
 	local_strings "github.com/aledbf/ingress-controller/pkg/strings"
 	"github.com/aledbf/ingress-controller/pkg/task"
 )
@@ -66,6 +74,10 @@ const (
 	podStoreSyncedPollPeriod = 1 * time.Second
 	rootLocation             = "/"
 
+	// builtinBackendPort is the port of the built-in default backend
+	builtinBackendPort = "8182"
+	builtinBackendAddr = "127.0.0.1:" + builtinBackendPort
+
 	// ingressClassKey picks a specific "class" for the Ingress. The controller
 	// only processes Ingresses with this annotation either unset, or set
 	// to either the configured value or the empty string.
@@ -74,7 +86,7 @@ const (
 
 var (
 	// list of ports that cannot be used by TCP or UDP services
-	reservedPorts = []string{"80", "443", "8181", "18080"}
+	reservedPorts = []string{"80", "443", "8181", "8182", "8183", "10247", "10248", "18080"}
 )
 
 // Interface holds the methods to handle an Ingress backend
@@ -125,6 +137,35 @@ type GenericController struct {
 	stopLock *sync.Mutex
 
 	stopCh chan struct{}
+
+	// built-in default backend used when there is no default backend service
+	errorPages *errorpage.Server
+
+	// last configuration successfully applied in the backend
+	lastGood *lastKnownGood
+
+	// configurations applied in the backend
+	history *configurationHistory
+
+	// state of the backend process
+	backendStatus *processStatus
+
+	// last warning about the expiration of a SSL certificate in an Ingress rule
+	sslWarnings map[string]time.Time
+
+	// last warning about the configuration of an Ingress rule. Only used
+	// in the sync of the configuration
+	configurationWarnings map[string]time.Time
+
+	// obtains certificates using ACME. nil if ACME is not configured
+	acme *acmeManager
+
+	// ocsp is nil when OCSP stapling is disabled
+	ocsp *ocspStapler
+
+	// quiet disables the events and the metrics in the copies of the
+	// controller used to build candidate configurations
+	quiet bool
 }
 
 // Configuration contains all the settings required by an Ingress controller
@@ -132,25 +173,67 @@ type Configuration struct {
 	Client         *client.Client
 	ElectionClient *clientset.Clientset
 
-	ResyncPeriod   time.Duration
+	ResyncPeriod time.Duration
+	// optional. Without a service the built-in default backend is used
 	DefaultService string
-	IngressClass   string
-	Namespace      string
-	ConfigMapName  string
+	// optional
+	DefaultBackendConfigMapName string
+	IngressClass                string
+	Namespace                   string
+	ConfigMapName               string
 	// optional
 	TCPConfigMapName string
 	// optional
 	UDPConfigMapName      string
 	DefaultSSLCertificate string
 	DefaultHealthzURL     string
+	// FakeCertificateCN is the common name of the self-signed certificate
+	// used when DefaultSSLCertificate is empty
+	FakeCertificateCN string
+	// FakeCertificateKeyType is the type of key (rsa or ecdsa) of the
+	// self-signed certificate
+	FakeCertificateKeyType string
 	// optional
 	PublishService string
+	// HistorySize is the number of configurations kept in the history
+	HistorySize int
+	// BisectReloadFailures enables the search of the Ingress rule that
+	// contains an invalid configuration when a reload fails
+	BisectReloadFailures bool
+	// ShutdownGracePeriod is the time to wait before stopping the backend
+	// after the IP address was removed from the status of the Ingress rules
+	ShutdownGracePeriod time.Duration
+	// ShutdownTimeout is the maximum time to wait until the backend
+	// process exits after it was stopped
+	ShutdownTimeout time.Duration
+	// SSLExpirationWarning is the time before the expiration of a SSL
+	// certificate when the Ingress rules using it receive a Warning event
+	SSLExpirationWarning time.Duration
+
+	// ACMEDirectory is the URL of the directory of the ACME server
+	ACMEDirectory string
+	// ACMEEmail is the contact address of the ACME account
+	ACMEEmail string
+	// optional. Secret (namespace/name) with the key of the ACME account.
+	// Without a secret certificates are not obtained using ACME
+	ACMEAccountSecret string
+	// optional. CA used to verify the certificate of the ACME server
+	ACMECAFile string
+
+	// EnableOCSPStapling obtains the OCSP responses of the certificates
+	// to be stapled in the TLS handshake
+	EnableOCSPStapling bool
+
+	// RestrictSecretNamespaces only allows references to Secrets in the
+	// namespace of the Ingress rule or in AllowedSecretNamespaces
+	RestrictSecretNamespaces bool
+	AllowedSecretNamespaces  []string
 
 	Backend ingress.Controller
 }
 
 // newIngressController creates an Ingress controller
-func newIngressController(config *Configuration) Interface {
+func newIngressController(config *Configuration) *GenericController {
 
 	eventBroadcaster := record.NewBroadcaster()
 	eventBroadcaster.StartLogging(glog.Infof)
@@ -164,7 +247,13 @@ func newIngressController(config *Configuration) Interface {
 		recorder: eventBroadcaster.NewRecorder(api.EventSource{
 			Component: "ingress-controller",
 		}),
-		sslCertTracker: newSSLCertTracker(),
+		sslCertTracker:        newSSLCertTracker(),
+		errorPages:            errorpage.NewServer(),
+		lastGood:              newLastKnownGood(),
+		history:               newConfigurationHistory(config.HistorySize),
+		backendStatus:         &processStatus{},
+		sslWarnings:           map[string]time.Time{},
+		configurationWarnings: map[string]time.Time{},
 	}
 
 	ic.syncQueue = task.NewTaskQueue(ic.sync)
@@ -180,7 +269,7 @@ func newIngressController(config *Configuration) Interface {
 				glog.Infof("ignoring add for ingress %v based on annotation %v", addIng.Name, ingressClassKey)
 				return
 			}
-			ic.recorder.Eventf(addIng, api.EventTypeNormal, "CREATE", fmt.Sprintf("Ingress %s/%s", addIng.Namespace, addIng.Name))
+			ic.recorder.Eventf(addIng, api.EventTypeNormal, "CREATE", "Ingress %s/%s", addIng.Namespace, addIng.Name)
 			ic.syncQueue.Enqueue(obj)
 		},
 		DeleteFunc: func(obj interface{}) {
@@ -189,7 +278,7 @@ func newIngressController(config *Configuration) Interface {
 				glog.Infof("ignoring add for ingress %v based on annotation %v", delIng.Name, ingressClassKey)
 				return
 			}
-			ic.recorder.Eventf(delIng, api.EventTypeNormal, "DELETE", fmt.Sprintf("Ingress %s/%s", delIng.Namespace, delIng.Name))
+			ic.recorder.Eventf(delIng, api.EventTypeNormal, "DELETE", "Ingress %s/%s", delIng.Namespace, delIng.Name)
 			ic.syncQueue.Enqueue(obj)
 		},
 		UpdateFunc: func(old, cur interface{}) {
@@ -200,7 +289,7 @@ func newIngressController(config *Configuration) Interface {
 
 			if !reflect.DeepEqual(old, cur) {
 				upIng := cur.(*extensions.Ingress)
-				ic.recorder.Eventf(upIng, api.EventTypeNormal, "UPDATE", fmt.Sprintf("Ingress %s/%s", upIng.Namespace, upIng.Name))
+				ic.recorder.Eventf(upIng, api.EventTypeNormal, "UPDATE", "Ingress %s/%s", upIng.Namespace, upIng.Name)
 				ic.syncQueue.Enqueue(cur)
 			}
 		},
@@ -210,15 +299,26 @@ func newIngressController(config *Configuration) Interface {
 		AddFunc: func(obj interface{}) {
 			sec := obj.(*api.Secret)
 			ic.secretQueue.Enqueue(sec)
+			// secrets used for authentication, the DH parameters and
+			// the session ticket keys are written in the sync
+			if ic.syncSecrReferenced(sec) {
+				ic.syncQueue.Enqueue(sec)
+			}
 		},
 		DeleteFunc: func(obj interface{}) {
 			sec := obj.(*api.Secret)
 			ic.sslCertTracker.Delete(fmt.Sprintf("%v/%v", sec.Namespace, sec.Name))
+			if ic.syncSecrReferenced(sec) {
+				ic.syncQueue.Enqueue(sec)
+			}
 		},
 		UpdateFunc: func(old, cur interface{}) {
 			if !reflect.DeepEqual(old, cur) {
 				sec := cur.(*api.Secret)
 				ic.secretQueue.Enqueue(sec)
+				if ic.syncSecrReferenced(sec) {
+					ic.syncQueue.Enqueue(sec)
+				}
 			}
 		},
 	}
@@ -238,13 +338,23 @@ func newIngressController(config *Configuration) Interface {
 	}
 
 	mapEventHandler := cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			ic.updateErrorPages(obj.(*api.ConfigMap))
+		},
+		DeleteFunc: func(obj interface{}) {
+			upCmap, ok := obj.(*api.ConfigMap)
+			if ok && fmt.Sprintf("%s/%s", upCmap.Namespace, upCmap.Name) == ic.cfg.DefaultBackendConfigMapName {
+				ic.errorPages.Update(nil)
+			}
+		},
 		UpdateFunc: func(old, cur interface{}) {
 			if !reflect.DeepEqual(old, cur) {
 				upCmap := cur.(*api.ConfigMap)
+				ic.updateErrorPages(upCmap)
 				mapKey := fmt.Sprintf("%s/%s", upCmap.Namespace, upCmap.Name)
 				// updates to configuration configmaps can trigger an update
 				if mapKey == ic.cfg.ConfigMapName || mapKey == ic.cfg.TCPConfigMapName || mapKey == ic.cfg.UDPConfigMapName {
-					ic.recorder.Eventf(upCmap, api.EventTypeNormal, "UPDATE", fmt.Sprintf("ConfigMap %v", mapKey))
+					ic.recorder.Eventf(upCmap, api.EventTypeNormal, "UPDATE", "ConfigMap %v", mapKey)
 					ic.syncQueue.Enqueue(cur)
 				}
 			}
@@ -316,7 +426,17 @@ func newIngressController(config *Configuration) Interface {
 		IngressLister:  ic.ingLister,
 	})
 
-	return ic
+	acmeMgr, err := newACMEManager(config)
+	if err != nil {
+		glog.Fatalf("error configuring ACME: %v", err)
+	}
+	ic.acme = acmeMgr
+
+	if config.EnableOCSPStapling {
+		ic.ocsp = newOCSPStapler(newOCSPClient())
+	}
+
+	return &ic
 }
 
 func (ic *GenericController) controllersInSync() bool {
@@ -332,8 +452,18 @@ func (ic GenericController) Name() string {
 	return "Ingress Controller"
 }
 
-// Check returns if the nginx healthz endpoint is returning ok (status code 200)
+// Check returns if the backend process is running and healthy. If the
+// backend does not implement a health check the nginx healthz endpoint
+// must return ok (status code 200)
 func (ic GenericController) Check(_ *http.Request) error {
+	if !ic.backendStatus.isRunning() {
+		return fmt.Errorf("the backend process is not running")
+	}
+
+	if hc, ok := ic.cfg.Backend.(ingress.HealthChecker); ok {
+		return hc.HealthCheck()
+	}
+
 	res, err := http.Get("http://127.0.0.1:18080/healthz")
 	if err != nil {
 		return err
@@ -345,6 +475,20 @@ func (ic GenericController) Check(_ *http.Request) error {
 	return nil
 }
 
+// Ready returns if the controller is ready to receive traffic. This
+// requires a configuration from the Ingress rules applied in the backend
+func (ic GenericController) Ready(r *http.Request) error {
+	if ic.backendStatus.isShuttingDown() {
+		return fmt.Errorf("the controller is shutting down")
+	}
+
+	if !ic.backendStatus.isConfigured() {
+		return fmt.Errorf("waiting for the first configuration of the backend")
+	}
+
+	return ic.Check(r)
+}
+
 // Info returns information about the backend
 func (ic GenericController) Info() string {
 	return ic.cfg.Backend.Info()
@@ -372,6 +516,18 @@ func (ic *GenericController) getConfigMap(ns, name string) (*api.ConfigMap, erro
 	return ic.cfg.Client.ConfigMaps(ns).Get(name)
 }
 
+// updateErrorPages replaces the templates of the built-in default backend
+// if the configmap is the one defined in --default-backend-configmap
+func (ic *GenericController) updateErrorPages(cmap *api.ConfigMap) {
+	mapKey := fmt.Sprintf("%s/%s", cmap.Namespace, cmap.Name)
+	if mapKey != ic.cfg.DefaultBackendConfigMapName {
+		return
+	}
+
+	glog.Infof("updating templates of the built-in default backend from configmap %v", mapKey)
+	ic.errorPages.Update(cmap.Data)
+}
+
 // sync collects all the pieces required to assemble the configuration file and
 // then sends the content to the backend (OnUpdate) receiving the populated
 // template as response reloading the backend if is required.
@@ -382,11 +538,15 @@ func (ic *GenericController) sync(key interface{}) error {
 		return nil
 	}
 
+	ic.history.trigger(key)
+
 	if !ic.controllersInSync() {
 		time.Sleep(podStoreSyncedPollPeriod)
 		return fmt.Errorf("deferring sync till endpoints controller has synced")
 	}
 
+	defer observeOperationDuration(syncOperation, time.Now())
+
 	// by default no custom configuration
 	cfg := &api.ConfigMap{}
 
@@ -401,7 +561,68 @@ func (ic *GenericController) sync(key interface{}) error {
 		}
 	}
 
-	upstreams, servers := ic.getUpstreamServers()
+	ings := ic.ingLister.Store.List()
+	pcfg := ic.getConfiguration(cfg, ings)
+
+	changes := ic.lastGood.compare(cfg, &pcfg)
+	if !ic.cfg.Backend.IsReloadRequired(changes) {
+		return ic.updateCertificates(&pcfg)
+	}
+
+	glog.V(2).Infof("configuration changes:\n%v", changes)
+
+	start := time.Now()
+	data, err := ic.cfg.Backend.OnUpdate(cfg, pcfg)
+	observeOperationDuration(backendUpdateOperation, start)
+	if err != nil {
+		// the configuration was not applied. There is nothing to rollback
+		ic.onReloadFailure(cfg, ings, err, false)
+		return err
+	}
+
+	glog.Infof("reloading ingress backend...")
+	start = time.Now()
+	out, err := ic.cfg.Backend.Restart(data)
+	observeOperationDuration(reloadOperationName, start)
+	if err != nil {
+		incReloadErrorCount()
+		glog.Errorf("unexpected failure restarting the backend: \n%v", string(out))
+		ic.onReloadFailure(cfg, ings, err, true)
+		return err
+	}
+	glog.Infof("ingress backend successfully reloaded...")
+	incReloadCount()
+	ic.lastGood.update(data, ings, cfg, &pcfg)
+	ic.history.add(data)
+	ic.backendStatus.setConfigured()
+	setConfigurationMetrics(&pcfg, data)
+	return ic.updateCertificates(&pcfg)
+}
+
+// updateCertificates sends the certificates of the servers to backends
+// able to update them without a reload
+func (ic *GenericController) updateCertificates(pcfg *ingress.Configuration) error {
+	cu, ok := ic.cfg.Backend.(ingress.CertificateUpdater)
+	if !ok {
+		return nil
+	}
+
+	err := cu.UpdateCertificates(pcfg.Servers)
+	if err != nil {
+		return fmt.Errorf("error updating SSL certificates: %v", err)
+	}
+	return nil
+}
+
+// getConfiguration returns the configuration of the backend
+// generated from a list of Ingress rules and the configmap
+func (ic *GenericController) getConfiguration(cfg *api.ConfigMap, ings []interface{}) ingress.Configuration {
+	start := time.Now()
+	upstreams, servers := ic.getUpstreamServers(ings)
+	if !ic.quiet {
+		observeOperationDuration(getUpstreamServersOperation, start)
+	}
+
 	var passUpstreams []*ingress.SSLPassthroughUpstreams
 	for _, server := range servers {
 		if !server.SSLPassthrough {
@@ -420,32 +641,18 @@ func (ic *GenericController) sync(key interface{}) error {
 		}
 	}
 
-	data, err := ic.cfg.Backend.OnUpdate(cfg, ingress.Configuration{
+	dhParam, ticketKeys := ic.getSSLFiles(cfg)
+
+	return ingress.Configuration{
 		HealthzURL:           ic.cfg.DefaultHealthzURL,
 		Upstreams:            upstreams,
 		Servers:              servers,
 		TCPUpstreams:         ic.getTCPServices(),
 		UDPUpstreams:         ic.getUDPServices(),
 		PassthroughUpstreams: passUpstreams,
-	})
-	if err != nil {
-		return err
-	}
-
-	if !ic.cfg.Backend.IsReloadRequired(data) {
-		return nil
+		DHParam:              dhParam,
+		SessionTicketKeys:    ticketKeys,
 	}
-
-	glog.Infof("reloading ingress backend...")
-	out, err := ic.cfg.Backend.Restart(data)
-	if err != nil {
-		incReloadErrorCount()
-		glog.Errorf("unexpected failure restarting the backend: \n%v", string(out))
-		return err
-	}
-	glog.Infof("ingress backend successfully reloaded...")
-	incReloadCount()
-	return nil
 }
 
 func (ic *GenericController) getTCPServices() []*ingress.Location {
@@ -574,23 +781,29 @@ func (ic *GenericController) getStreamServices(data map[string]string, proto api
 }
 
 // getDefaultUpstream returns an upstream associated with the
-// default backend service. In case of error retrieving information
-// configure the upstream to return http code 503.
+// default backend service. If the service is not configured or in case
+// of error retrieving information the upstream uses the built-in
+// default backend.
 func (ic *GenericController) getDefaultUpstream() *ingress.Upstream {
 	upstream := &ingress.Upstream{
 		Name: defUpstreamName,
 	}
 	svcKey := ic.cfg.DefaultService
+	if svcKey == "" {
+		upstream.Backends = append(upstream.Backends, newBuiltinDefaultServer())
+		return upstream
+	}
+
 	svcObj, svcExists, err := ic.svcLister.Indexer.GetByKey(svcKey)
 	if err != nil {
 		glog.Warningf("unexpected error searching the default backend %v: %v", ic.cfg.DefaultService, err)
-		upstream.Backends = append(upstream.Backends, newDefaultServer())
+		upstream.Backends = append(upstream.Backends, newBuiltinDefaultServer())
 		return upstream
 	}
 
 	if !svcExists {
 		glog.Warningf("service %v does not exists", svcKey)
-		upstream.Backends = append(upstream.Backends, newDefaultServer())
+		upstream.Backends = append(upstream.Backends, newBuiltinDefaultServer())
 		return upstream
 	}
 
@@ -598,7 +811,7 @@ func (ic *GenericController) getDefaultUpstream() *ingress.Upstream {
 	endps := ic.getEndpoints(svc, svc.Spec.Ports[0].TargetPort, api.ProtocolTCP, &healthcheck.Upstream{})
 	if len(endps) == 0 {
 		glog.Warningf("service %v does not have any active endpoints", svcKey)
-		endps = []ingress.UpstreamServer{newDefaultServer()}
+		endps = []ingress.UpstreamServer{newBuiltinDefaultServer()}
 	}
 
 	upstream.Backends = append(upstream.Backends, endps...)
@@ -617,8 +830,7 @@ func (c ingressByRevision) Less(i, j int) bool {
 
 // getUpstreamServers returns a list of Upstream and Server to be used by the backend
 // An upstream can be used in multiple servers if the namespace, service name and port are the same
-func (ic *GenericController) getUpstreamServers() ([]*ingress.Upstream, []*ingress.Server) {
-	ings := ic.ingLister.Store.List()
+func (ic *GenericController) getUpstreamServers(ings []interface{}) ([]*ingress.Upstream, []*ingress.Server) {
 	sort.Sort(ingressByRevision(ings))
 
 	upstreams := ic.createUpstreams(ings)
@@ -631,39 +843,53 @@ func (ic *GenericController) getUpstreamServers() ([]*ingress.Upstream, []*ingre
 
 		nginxAuth, err := auth.ParseAnnotations(ing, auth.DefAuthDirectory, ic.getSecret)
 		glog.V(5).Infof("auth annotation: %v", nginxAuth)
+		ic.incAnnotationErrorCount("auth", err)
 		if err != nil {
 			glog.V(5).Infof("error reading authentication in Ingress %v/%v: %v", ing.GetNamespace(), ing.GetName(), err)
 		}
 
 		rl, err := ratelimit.ParseAnnotations(ing)
 		glog.V(5).Infof("rate limit annotation: %v", rl)
+		ic.incAnnotationErrorCount("ratelimit", err)
 		if err != nil {
 			glog.V(5).Infof("error reading rate limit annotation in Ingress %v/%v: %v", ing.GetNamespace(), ing.GetName(), err)
 		}
 
+		grl, err := globalratelimit.ParseAnnotations(ing)
+		glog.V(5).Infof("global rate limit annotation: %v", grl)
+		ic.incAnnotationErrorCount("globalratelimit", err)
+		if err != nil {
+			glog.V(5).Infof("error reading global rate limit annotation in Ingress %v/%v: %v", ing.GetNamespace(), ing.GetName(), err)
+		}
+
 		secUpstream, err := secureupstream.ParseAnnotations(ing)
+		ic.incAnnotationErrorCount("secureupstream", err)
 		if err != nil {
 			glog.V(5).Infof("error reading secure upstream in Ingress %v/%v: %v", ing.GetNamespace(), ing.GetName(), err)
 		}
 
 		locRew, err := rewrite.ParseAnnotations(upsDefaults, ing)
+		ic.incAnnotationErrorCount("rewrite", err)
 		if err != nil {
 			glog.V(5).Infof("error parsing rewrite annotations for Ingress rule %v/%v: %v", ing.GetNamespace(), ing.GetName(), err)
 		}
 
 		wl, err := ipwhitelist.ParseAnnotations(upsDefaults, ing)
 		glog.V(5).Infof("white list annotation: %v", wl)
+		ic.incAnnotationErrorCount("ipwhitelist", err)
 		if err != nil {
 			glog.V(5).Infof("error reading white list annotation in Ingress %v/%v: %v", ing.GetNamespace(), ing.GetName(), err)
 		}
 
 		eCORS, err := cors.ParseAnnotations(ing)
+		ic.incAnnotationErrorCount("cors", err)
 		if err != nil {
 			glog.V(5).Infof("error reading CORS annotation in Ingress %v/%v: %v", ing.GetNamespace(), ing.GetName(), err)
 		}
 
 		ra, err := authreq.ParseAnnotations(ing)
 		glog.V(5).Infof("auth request annotation: %v", ra)
+		ic.incAnnotationErrorCount("authreq", err)
 		if err != nil {
 			glog.V(5).Infof("error reading auth request annotation in Ingress %v/%v: %v", ing.GetNamespace(), ing.GetName(), err)
 		}
@@ -671,12 +897,35 @@ func (ic *GenericController) getUpstreamServers() ([]*ingress.Upstream, []*ingre
 		prx := proxy.ParseAnnotations(upsDefaults, ing)
 		glog.V(5).Infof("proxy timeouts annotation: %v", prx)
 
-		certAuth, err := authtls.ParseAnnotations(ing, ic.getAuthCertificate)
+		certAuth, err := authtls.ParseAnnotations(ing, func(secretName string) (*authtls.SSLCert, error) {
+			if !ic.secretAllowed(ing, secretName) {
+				ic.configurationWarning(ing, "SECRET",
+					"secret %v cannot be referenced from namespace %v", secretName, ing.Namespace)
+				return &authtls.SSLCert{}, fmt.Errorf("secret %v not allowed in namespace %v", secretName, ing.Namespace)
+			}
+			return ic.getAuthCertificate(secretName)
+		})
 		glog.V(5).Infof("auth request annotation: %v", certAuth)
+		ic.incAnnotationErrorCount("authtls", err)
 		if err != nil {
 			glog.V(5).Infof("error reading certificate auth annotation in Ingress %v/%v: %v", ing.GetNamespace(), ing.GetName(), err)
 		}
 
+		errCodes, err := customerrors.ParseAnnotations(ing)
+		glog.V(5).Infof("custom http errors annotation: %v", errCodes)
+		ic.incAnnotationErrorCount("customerrors", err)
+		if err != nil {
+			glog.V(5).Infof("error reading custom http errors annotation in Ingress %v/%v: %v", ing.GetNamespace(), ing.GetName(), err)
+		}
+
+		// upstream of the service configured with the default-backend annotation
+		ingDefBackend := ""
+		if _, name, _, err := ic.ingressDefaultBackend(ing); err == nil {
+			if _, ok := upstreams[name]; ok {
+				ingDefBackend = name
+			}
+		}
+
 		for _, rule := range ing.Spec.Rules {
 			host := rule.Host
 			if host == "" {
@@ -705,6 +954,8 @@ func (ic *GenericController) getUpstreamServers() ([]*ingress.Upstream, []*ingre
 				if defUps, ok := upstreams[name]; ok {
 					defBackend = defUps
 				}
+			} else if ingDefBackend != "" {
+				defBackend = upstreams[ingDefBackend]
 			}
 
 			if rule.HTTP == nil &&
@@ -712,6 +963,11 @@ func (ic *GenericController) getUpstreamServers() ([]*ingress.Upstream, []*ingre
 				host != defServerName {
 				glog.V(3).Infof("ingress rule %v/%v does not contains HTTP or TLS rules. using default backend", ing.Namespace, ing.Name)
 				server.Locations[0].Upstream = *defBackend
+				if ing.Spec.Backend != nil {
+					server.Locations[0].Namespace = ing.GetNamespace()
+					server.Locations[0].Ingress = ing.GetName()
+					server.Locations[0].Service = ing.Spec.Backend.ServiceName
+				}
 				continue
 			}
 
@@ -744,6 +1000,7 @@ func (ic *GenericController) getUpstreamServers() ([]*ingress.Upstream, []*ingre
 						loc.IsDefBackend = false
 						loc.BasicDigestAuth = *nginxAuth
 						loc.RateLimit = *rl
+						loc.GlobalRateLimit = *grl
 						loc.Redirect = *locRew
 						loc.SecureUpstream = secUpstream
 						loc.Whitelist = *wl
@@ -752,31 +1009,44 @@ func (ic *GenericController) getUpstreamServers() ([]*ingress.Upstream, []*ingre
 						loc.ExternalAuth = ra
 						loc.Proxy = *prx
 						loc.CertificateAuth = *certAuth
+						loc.DefaultBackend = ingDefBackend
+						loc.CustomHTTPErrors = errCodes
+						loc.Namespace = ing.GetNamespace()
+						loc.Ingress = ing.GetName()
+						loc.Service = path.Backend.ServiceName
 						break
 					}
 				}
 				// is a new location
 				if addLoc {
-					glog.V(3).Infof("adding location %v in ingress rule %v/%v upstream", nginxPath, ing.Namespace, ing.Name, ups.Name)
+					glog.V(3).Infof("adding location %v in ingress rule %v/%v upstream %v", nginxPath, ing.Namespace, ing.Name, ups.Name)
 					server.Locations = append(server.Locations, &ingress.Location{
-						Path:            nginxPath,
-						Upstream:        *ups,
-						IsDefBackend:    false,
-						BasicDigestAuth: *nginxAuth,
-						RateLimit:       *rl,
-						Redirect:        *locRew,
-						SecureUpstream:  secUpstream,
-						Whitelist:       *wl,
-						EnableCORS:      eCORS,
-						ExternalAuth:    ra,
-						Proxy:           *prx,
-						CertificateAuth: *certAuth,
+						Path:             nginxPath,
+						Upstream:         *ups,
+						IsDefBackend:     false,
+						BasicDigestAuth:  *nginxAuth,
+						RateLimit:        *rl,
+						GlobalRateLimit:  *grl,
+						Redirect:         *locRew,
+						SecureUpstream:   secUpstream,
+						Whitelist:        *wl,
+						EnableCORS:       eCORS,
+						ExternalAuth:     ra,
+						Proxy:            *prx,
+						CertificateAuth:  *certAuth,
+						DefaultBackend:   ingDefBackend,
+						CustomHTTPErrors: errCodes,
+						Namespace:        ing.GetNamespace(),
+						Ingress:          ing.GetName(),
+						Service:          path.Backend.ServiceName,
 					})
 				}
 			}
 		}
 	}
 
+	ic.addACMEChallengeLocations(ings, upstreams, servers)
+
 	// TODO: find a way to make this more readable
 	// The structs must be ordered to always generate the same file
 	// if the content does not change.
@@ -801,6 +1071,83 @@ func (ic *GenericController) getUpstreamServers() ([]*ingress.Upstream, []*ingre
 	return aUpstreams, aServers
 }
 
+// configureDualCertificate adds a certificate with a different type of
+// key (RSA or ECDSA) to the server if other TLS section of the Ingress
+// rule contains the host. The RSA certificate is always the first one
+func (ic *GenericController) configureDualCertificate(ing *extensions.Ingress, host string,
+	server *ingress.Server, primary *ingress.SSLCert) {
+
+	for _, tls := range ing.Spec.TLS[1:] {
+		if !local_strings.StringInSlice(host, tls.Hosts) {
+			continue
+		}
+
+		key := fmt.Sprintf("%v/%v", ing.Namespace, tls.SecretName)
+		bc, exists := ic.sslCertTracker.Get(key)
+		if !exists {
+			continue
+		}
+		cert := bc.(*ingress.SSLCert)
+		if cert.KeyType == primary.KeyType {
+			continue
+		}
+
+		rsaCert, ecdsaCert := primary, cert
+		if primary.KeyType == ssl.KeyTypeECDSA {
+			rsaCert, ecdsaCert = cert, primary
+		}
+
+		err := ssl.ValidateDualCertificates(host, rsaCert, ecdsaCert)
+		if err != nil {
+			ic.configurationWarning(ing, "SSL", "error using secret %v as second certificate: %v", key, err)
+			continue
+		}
+
+		server.SSLCertificate = rsaCert.PemFileName
+		server.SSLPemChecksum = rsaCert.PemSHA
+		server.SSLCertificateECDSA = ecdsaCert.PemFileName
+		server.SSLPemChecksumECDSA = ecdsaCert.PemSHA
+		return
+	}
+}
+
+// configureTLSSettings applies the TLS settings defined in the annotations
+// of the Ingress rules to the servers. When the rules of the same host
+// define different values the value of the first rule is used and the
+// rest receive a Warning event
+func (ic *GenericController) configureTLSSettings(data []interface{}, servers map[string]*ingress.Server) {
+	for _, ingIf := range data {
+		ing := ingIf.(*extensions.Ingress)
+		settings, err := tlssettings.ParseAnnotations(ing)
+		ic.incAnnotationErrorCount("tlssettings", err)
+		if err != nil {
+			if err != parser.ErrMissingAnnotations {
+				ic.recorder.Eventf(ing, api.EventTypeWarning, "TLS", "invalid TLS settings: %v", err)
+			}
+			continue
+		}
+		if settings.IsEmpty() {
+			continue
+		}
+
+		for _, rule := range ing.Spec.Rules {
+			host := rule.Host
+			if host == "" {
+				host = defServerName
+			}
+			server, ok := servers[host]
+			if !ok {
+				continue
+			}
+			conflicts := server.TLSSettings.Merge(settings)
+			if len(conflicts) > 0 {
+				ic.recorder.Eventf(ing, api.EventTypeWarning, "TLS",
+					"ignoring %v in host %v: other Ingress rule defines a different value", strings.Join(conflicts, ", "), host)
+			}
+		}
+	}
+}
+
 func (ic *GenericController) getAuthCertificate(secretName string) (*authtls.SSLCert, error) {
 	bc, exists := ic.sslCertTracker.Get(secretName)
 	if !exists {
@@ -845,6 +1192,24 @@ func (ic *GenericController) createUpstreams(data []interface{}) map[string]*ing
 			}
 		}
 
+		svcKey, name, port, err := ic.ingressDefaultBackend(ing)
+		ic.incAnnotationErrorCount("defaultbackend", err)
+		if err != nil && err != parser.ErrMissingAnnotations {
+			glog.Warningf("error reading default backend annotation in Ingress %v/%v: %v", ing.GetNamespace(), ing.GetName(), err)
+		}
+		if err == nil {
+			if _, ok := upstreams[name]; !ok {
+				glog.V(3).Infof("creating upstream %v", name)
+				upstreams[name] = newUpstream(name)
+
+				endps, err := ic.serviceEndpoints(svcKey, port, hz)
+				upstreams[name].Backends = append(upstreams[name].Backends, endps...)
+				if err != nil {
+					glog.Warningf("error creating upstream %v: %v", name, err)
+				}
+			}
+		}
+
 		for _, rule := range ing.Spec.Rules {
 			if rule.HTTP == nil {
 				continue
@@ -877,6 +1242,34 @@ func (ic *GenericController) createUpstreams(data []interface{}) map[string]*ing
 	return upstreams
 }
 
+// ingressDefaultBackend returns the key of the service configured in the
+// default-backend annotation of an Ingress rule, the name of the upstream
+// and the port used to reach the service (the first port of the service)
+func (ic *GenericController) ingressDefaultBackend(ing *extensions.Ingress) (string, string, string, error) {
+	svcName, err := defaultbackend.ParseAnnotations(ing)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	svcKey := fmt.Sprintf("%v/%v", ing.GetNamespace(), svcName)
+	svcObj, svcExists, err := ic.svcLister.Indexer.GetByKey(svcKey)
+	if err != nil {
+		return "", "", "", fmt.Errorf("error getting service %v from the cache: %v", svcKey, err)
+	}
+
+	if !svcExists {
+		return "", "", "", fmt.Errorf("service %v does not exists", svcKey)
+	}
+
+	svc := svcObj.(*api.Service)
+	if len(svc.Spec.Ports) == 0 {
+		return "", "", "", fmt.Errorf("service %v does not contain ports", svcKey)
+	}
+
+	port := strconv.Itoa(int(svc.Spec.Ports[0].Port))
+	return svcKey, fmt.Sprintf("%v-%v-%v", ing.GetNamespace(), svcName, port), port, nil
+}
+
 // serviceEndpoints returns the upstream servers (endpoints) associated
 // to a service.
 func (ic *GenericController) serviceEndpoints(svcKey, backendPort string,
@@ -937,6 +1330,7 @@ func (ic *GenericController) createServers(data []interface{}, upstreams map[str
 		ing := ingIf.(*extensions.Ingress)
 		// check if ssl passthrough is configured
 		sslpt, err := sslpassthrough.ParseAnnotations(upsDefaults, ing)
+		ic.incAnnotationErrorCount("sslpassthrough", err)
 		if err != nil {
 			glog.V(5).Infof("error reading ssl passthrough annotation in Ingress %v/%v: %v", ing.GetNamespace(), ing.GetName(), err)
 		}
@@ -984,6 +1378,8 @@ func (ic *GenericController) createServers(data []interface{}, upstreams map[str
 						servers[host].SSLCertificate = cert.PemFileName
 						//servers[host].SSLCertificateKey = cert.PemFileName
 						servers[host].SSLPemChecksum = cert.PemSHA
+						ic.configureDualCertificate(ing, host, servers[host], cert)
+						ic.configureOCSPStapling(servers[host])
 					}
 				}
 			}
@@ -998,9 +1394,29 @@ func (ic *GenericController) createServers(data []interface{}, upstreams map[str
 					servers[host].Locations[0].Upstream = *backendUpstream
 				}
 			}
+
+			// the service in the default-backend annotation handles the requests
+			// that do not match any path and the custom error pages of the server
+			if _, name, _, err := ic.ingressDefaultBackend(ing); err == nil {
+				if host == defServerName {
+					ic.recorder.Eventf(ing, api.EventTypeWarning, "MAPPING", "error: the default backend annotation is allowed only with hostnames")
+					continue
+				}
+				if backendUpstream, ok := upstreams[name]; ok && servers[host].Locations[0].IsDefBackend {
+					if ing.Spec.Backend == nil {
+						servers[host].Locations[0].Upstream = *backendUpstream
+					}
+					servers[host].Locations[0].DefaultBackend = name
+					if codes, err := customerrors.ParseAnnotations(ing); err == nil {
+						servers[host].Locations[0].CustomHTTPErrors = codes
+					}
+				}
+			}
 		}
 	}
 
+	ic.configureTLSSettings(data, servers)
+
 	return servers
 }
 
@@ -1074,28 +1490,51 @@ func (ic *GenericController) getEndpoints(
 	return upsServers
 }
 
-// Stop stops the loadbalancer controller.
+// Stop stops the loadbalancer controller. The shutdown is executed in
+// steps to avoid dropping requests:
+// - the controller is marked as not ready and the IP address of the
+// node is removed from the status of the Ingress rules
+// - waits the grace period so load balancers stop sending new traffic
+// - stops the controller queues and the backend and waits until the
+// backend process exits or the shutdown timeout expires
 func (ic GenericController) Stop() error {
 	ic.stopLock.Lock()
-	defer ic.stopLock.Unlock()
-
-	// Only try draining the workqueue if we haven't already.
-	if !ic.syncQueue.IsShuttingDown() {
-		glog.Infof("shutting down controller queues")
-		close(ic.stopCh)
-		go ic.syncQueue.Shutdown()
-		go ic.secretQueue.Shutdown()
-		ic.syncStatus.Shutdown()
-		return nil
+	if ic.backendStatus.isShuttingDown() {
+		ic.stopLock.Unlock()
+		return fmt.Errorf("shutdown already in progress")
 	}
+	ic.backendStatus.setShuttingDown()
+	ic.stopLock.Unlock()
+
+	glog.Infof("updating status of Ingress rules")
+	ic.syncStatus.Shutdown()
 
-	return fmt.Errorf("shutdown already in progress")
+	if ic.cfg.ShutdownGracePeriod > 0 {
+		glog.Infof("waiting %v before stopping the backend", ic.cfg.ShutdownGracePeriod)
+		time.Sleep(ic.cfg.ShutdownGracePeriod)
+	}
+
+	glog.Infof("shutting down controller queues")
+	close(ic.stopCh)
+	go ic.syncQueue.Shutdown()
+	go ic.secretQueue.Shutdown()
+
+	glog.Infof("stopping the backend")
+	if err := ic.cfg.Backend.Stop(); err != nil {
+		return fmt.Errorf("error stopping the backend: %v", err)
+	}
+
+	return ic.waitBackendExit(ic.cfg.ShutdownTimeout)
 }
 
 // Start starts the Ingress controller.
 func (ic GenericController) Start() {
 	glog.Infof("starting Ingress controller")
-	go ic.cfg.Backend.Start()
+	go ic.superviseBackend()
+
+	go func() {
+		glog.Fatal(http.ListenAndServe(builtinBackendAddr, ic.errorPages))
+	}()
 
 	go ic.ingController.Run(ic.stopCh)
 	go ic.endpController.Run(ic.stopCh)
@@ -1108,5 +1547,21 @@ func (ic GenericController) Start() {
 
 	go ic.syncStatus.Run(ic.stopCh)
 
+	go wait.Until(ic.checkSSLExpiration, sslExpirationCheckInterval, ic.stopCh)
+	go wait.Until(ic.checkFakeSSLCertificate, sslExpirationCheckInterval, ic.stopCh)
+	go wait.Until(ic.removeUnusedFiles, unusedFilesCheckInterval, ic.stopCh)
+
+	if ic.acme != nil {
+		go ic.acme.challenges.run(ic.stopCh)
+		go func() {
+			glog.Fatal(http.ListenAndServe(acmeChallengeAddr, ic.acme.challenges))
+		}()
+		go wait.Until(ic.syncACME, acmeSyncInterval, ic.stopCh)
+	}
+
+	if ic.ocsp != nil {
+		go wait.Until(ic.checkOCSP, ocspCheckInterval, ic.stopCh)
+	}
+
 	<-ic.stopCh
 }
@@ -0,0 +1,130 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/kubernetes/pkg/apis/extensions"
+	"k8s.io/kubernetes/pkg/client/record"
+
+	"github.com/aledbf/ingress-controller/pkg/ingress"
+	"github.com/aledbf/ingress-controller/pkg/net/ssl"
+)
+
+func TestConfigureDualCertificate(t *testing.T) {
+	ecdsaCert := &ingress.SSLCert{PemFileName: "ecdsa.pem", PemSHA: "2", KeyType: ssl.KeyTypeECDSA, CN: []string{"foo.bar"}}
+	rsaCert := &ingress.SSLCert{PemFileName: "rsa.pem", PemSHA: "1", KeyType: ssl.KeyTypeRSA, CN: []string{"foo.bar"}}
+	otherCert := &ingress.SSLCert{PemFileName: "other.pem", PemSHA: "3", KeyType: ssl.KeyTypeRSA, CN: []string{"bar.baz"}}
+
+	tracker := newSSLCertTracker()
+	tracker.Add("default/ecdsa", ecdsaCert)
+	tracker.Add("default/rsa", rsaCert)
+	tracker.Add("default/other", otherCert)
+
+	recorder := record.NewFakeRecorder(10)
+	ic := &GenericController{
+		sslCertTracker:        tracker,
+		recorder:              recorder,
+		configurationWarnings: map[string]time.Time{},
+	}
+
+	ing := newIngress("dual", "1")
+	ing.Spec.TLS = []extensions.IngressTLS{
+		{Hosts: []string{"foo.bar"}, SecretName: "ecdsa"},
+		{Hosts: []string{"foo.bar"}, SecretName: "rsa"},
+	}
+	server := &ingress.Server{Name: "foo.bar", SSLCertificate: "ecdsa.pem", SSLPemChecksum: "2"}
+	ic.configureDualCertificate(ing, "foo.bar", server, ecdsaCert)
+
+	if server.SSLCertificate != "rsa.pem" || server.SSLPemChecksum != "1" {
+		t.Errorf("expected the RSA certificate as first certificate but returned %v", server.SSLCertificate)
+	}
+	if server.SSLCertificateECDSA != "ecdsa.pem" || server.SSLPemChecksumECDSA != "2" {
+		t.Errorf("expected the ECDSA certificate as second certificate but returned %v", server.SSLCertificateECDSA)
+	}
+
+	// a second certificate not valid for the host is ignored
+	ing.Spec.TLS[1] = extensions.IngressTLS{Hosts: []string{"foo.bar"}, SecretName: "other"}
+	server = &ingress.Server{Name: "foo.bar", SSLCertificate: "ecdsa.pem", SSLPemChecksum: "2"}
+	ic.configureDualCertificate(ing, "foo.bar", server, ecdsaCert)
+
+	if server.SSLCertificate != "ecdsa.pem" || server.SSLCertificateECDSA != "" {
+		t.Errorf("expected only the ECDSA certificate but returned %v and %v", server.SSLCertificate, server.SSLCertificateECDSA)
+	}
+	if len(recorder.Events) != 1 {
+		t.Errorf("expected a warning about the invalid certificate but returned %v events", len(recorder.Events))
+	}
+
+	// the warning is not repeated in the next sync
+	ic.configureDualCertificate(ing, "foo.bar", server, ecdsaCert)
+	if len(recorder.Events) != 1 {
+		t.Errorf("expected only one warning about the invalid certificate but returned %v events", len(recorder.Events))
+	}
+}
+
+func TestConfigureTLSSettings(t *testing.T) {
+	withRules := func(ing *extensions.Ingress, annotations map[string]string, hosts ...string) *extensions.Ingress {
+		ing.Annotations = annotations
+		for _, host := range hosts {
+			ing.Spec.Rules = append(ing.Spec.Rules, extensions.IngressRule{Host: host})
+		}
+		return ing
+	}
+
+	partner := withRules(newIngress("partner", "1"), map[string]string{
+		"ingress.kubernetes.io/ssl-protocols": "TLSv1 TLSv1.1 TLSv1.2",
+		"ingress.kubernetes.io/hsts-max-age":  "60",
+	}, "partner.bar")
+	conflict := withRules(newIngress("conflict", "2"), map[string]string{
+		"ingress.kubernetes.io/ssl-protocols": "TLSv1.2",
+		"ingress.kubernetes.io/use-http2":     "false",
+	}, "partner.bar")
+	invalid := withRules(newIngress("invalid", "3"), map[string]string{
+		"ingress.kubernetes.io/ssl-protocols": "TLSv9",
+	}, "foo.bar")
+	plain := withRules(newIngress("plain", "4"), nil, "foo.bar")
+
+	servers := map[string]*ingress.Server{
+		"partner.bar": {Name: "partner.bar"},
+		"foo.bar":     {Name: "foo.bar"},
+	}
+
+	recorder := record.NewFakeRecorder(10)
+	ic := &GenericController{recorder: recorder}
+	ic.configureTLSSettings([]interface{}{partner, conflict, invalid, plain}, servers)
+
+	settings := servers["partner.bar"].TLSSettings
+	if settings.Protocols != "TLSv1 TLSv1.1 TLSv1.2" {
+		t.Errorf("expected the protocols of the first Ingress rule but returned %v", settings.Protocols)
+	}
+	if settings.HSTSMaxAge == nil || *settings.HSTSMaxAge != 60 {
+		t.Errorf("expected HSTS max-age 60 but returned %v", settings.HSTSMaxAge)
+	}
+	if settings.HTTP2 == nil || *settings.HTTP2 {
+		t.Errorf("expected HTTP/2 disabled by the second Ingress rule")
+	}
+	if !servers["foo.bar"].TLSSettings.IsEmpty() {
+		t.Errorf("expected no TLS settings in foo.bar but returned %+v", servers["foo.bar"].TLSSettings)
+	}
+
+	// one event for the conflict and one for the invalid annotation
+	if len(recorder.Events) != 2 {
+		t.Errorf("expected 2 events but %v returned", len(recorder.Events))
+	}
+}
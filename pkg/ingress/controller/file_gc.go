@@ -0,0 +1,127 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+
+	"github.com/aledbf/ingress-controller/pkg/file"
+	"github.com/aledbf/ingress-controller/pkg/ingress"
+	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/auth"
+)
+
+const (
+	// interval between the removal of the files not in use
+	unusedFilesCheckInterval = 5 * time.Minute
+	// files modified recently are kept because they could be part of
+	// a configuration not yet applied in the backend
+	unusedFilesMinAge = 2 * time.Minute
+
+	sslFileType  = "ssl"
+	authFileType = "auth"
+)
+
+// removeUnusedFiles removes the SSL certificates and the files used to
+// authenticate requests that are not used by the certificates of the
+// tracker or the configuration running in the backend. Without
+// this files from Secrets and Ingress rules removed are kept forever
+func (ic *GenericController) removeUnusedFiles() {
+	pcfg := ic.lastGood.current()
+	if pcfg == nil {
+		// the files used by the backend are unknown until the
+		// first configuration is applied
+		return
+	}
+
+	sslFiles, authFiles := filesInUse(pcfg, ic.sslCertTracker.List())
+	sslFiles = append(sslFiles, ic.ocsp.files()...)
+
+	removeFiles(sslFileType, func() ([]string, error) {
+		return file.RemoveUnused(fmt.Sprintf("%v/*.pem", ingress.DefaultSSLDirectory), sslFiles, unusedFilesMinAge)
+	})
+	// session ticket keys and OCSP responses
+	for _, ext := range []string{"key", "ocsp"} {
+		pattern := fmt.Sprintf("%v/*.%v", ingress.DefaultSSLDirectory, ext)
+		removeFiles(sslFileType, func() ([]string, error) {
+			return file.RemoveUnused(pattern, sslFiles, unusedFilesMinAge)
+		})
+	}
+	removeFiles(authFileType, func() ([]string, error) {
+		return auth.RemoveUnusedFiles(auth.DefAuthDirectory, authFiles, unusedFilesMinAge)
+	})
+}
+
+func removeFiles(fileType string, remove func() ([]string, error)) {
+	removed, err := remove()
+	for _, f := range removed {
+		glog.V(2).Infof("removed unused %v file %v", fileType, f)
+	}
+	removedFiles.WithLabelValues(fileType).Add(float64(len(removed)))
+	if err != nil {
+		glog.Warningf("unexpected error removing unused %v files: %v", fileType, err)
+	}
+}
+
+// filesInUse returns the SSL and authentication files used in a
+// configuration and the certificates of the tracker
+func filesInUse(pcfg *ingress.Configuration, certs []interface{}) ([]string, []string) {
+	sslFiles := []string{}
+	authFiles := []string{}
+
+	for _, certIf := range certs {
+		cert := certIf.(*ingress.SSLCert)
+		sslFiles = append(sslFiles, cert.PemFileName)
+		if cert.CAFileName != "" {
+			sslFiles = append(sslFiles, cert.CAFileName)
+		}
+	}
+
+	if pcfg.DHParam != nil {
+		sslFiles = append(sslFiles, pcfg.DHParam.FileName)
+	}
+	for _, key := range pcfg.SessionTicketKeys {
+		sslFiles = append(sslFiles, key.FileName)
+	}
+
+	for _, server := range pcfg.Servers {
+		if server.SSLCertificate != "" {
+			sslFiles = append(sslFiles, server.SSLCertificate)
+		}
+		if server.SSLCertificateECDSA != "" {
+			sslFiles = append(sslFiles, server.SSLCertificateECDSA)
+		}
+		if server.SSLStaplingFile != "" {
+			sslFiles = append(sslFiles, server.SSLStaplingFile)
+		}
+		for _, loc := range server.Locations {
+			if loc.BasicDigestAuth.Secured {
+				authFiles = append(authFiles, loc.BasicDigestAuth.File)
+			}
+			if loc.CertificateAuth.CertFileName != "" {
+				sslFiles = append(sslFiles, loc.CertificateAuth.CertFileName)
+			}
+			if loc.CertificateAuth.CAFileName != "" {
+				sslFiles = append(sslFiles, loc.CertificateAuth.CAFileName)
+			}
+		}
+	}
+
+	return sslFiles, authFiles
+}
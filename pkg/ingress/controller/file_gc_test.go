@@ -0,0 +1,82 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aledbf/ingress-controller/pkg/ingress"
+	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/auth"
+	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/authtls"
+)
+
+func TestFilesInUse(t *testing.T) {
+	pcfg := &ingress.Configuration{
+		DHParam: &ingress.SSLFile{FileName: "/ssl/dhparam-default-dh.pem"},
+		SessionTicketKeys: []*ingress.SSLFile{
+			{FileName: "/ssl/ticket-default-keys-b.key"},
+			{FileName: "/ssl/ticket-default-keys-a.key"},
+		},
+		Servers: []*ingress.Server{
+			{
+				Name:           "foo.bar",
+				SSLCertificate: "/ssl/default-foo.pem",
+				Locations: []*ingress.Location{
+					{
+						Path:            "/",
+						BasicDigestAuth: auth.BasicDigest{Secured: true, File: "/auth/default-foo.passwd"},
+					},
+					{
+						Path:            "/tls",
+						CertificateAuth: authtls.SSLCert{CertFileName: "/ssl/default-ca.pem", CAFileName: "/ssl/ca-default-ca.pem"},
+					},
+					{
+						Path:            "/disabled",
+						BasicDigestAuth: auth.BasicDigest{File: "/auth/default-disabled.passwd"},
+					},
+				},
+			},
+		},
+	}
+	certs := []interface{}{
+		&ingress.SSLCert{PemFileName: "/ssl/default-bar.pem", CAFileName: "/ssl/ca-default-bar.pem"},
+		&ingress.SSLCert{PemFileName: "/ssl/default-fake-certificate.pem"},
+	}
+
+	sslFiles, authFiles := filesInUse(pcfg, certs)
+
+	expectedSSL := []string{
+		"/ssl/default-bar.pem",
+		"/ssl/ca-default-bar.pem",
+		"/ssl/default-fake-certificate.pem",
+		"/ssl/dhparam-default-dh.pem",
+		"/ssl/ticket-default-keys-b.key",
+		"/ssl/ticket-default-keys-a.key",
+		"/ssl/default-foo.pem",
+		"/ssl/default-ca.pem",
+		"/ssl/ca-default-ca.pem",
+	}
+	if !reflect.DeepEqual(sslFiles, expectedSSL) {
+		t.Errorf("expected %v but returned %v", expectedSSL, sslFiles)
+	}
+
+	expectedAuth := []string{"/auth/default-foo.passwd"}
+	if !reflect.DeepEqual(authFiles, expectedAuth) {
+		t.Errorf("expected %v but returned %v", expectedAuth, authFiles)
+	}
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aledbf/ingress-controller/pkg/diff"
+)
+
+const (
+	historyPath = "/configuration/history"
+)
+
+// historyEntry describes a configuration applied in the backend
+type historyEntry struct {
+	ID       int       `json:"id"`
+	Time     time.Time `json:"time"`
+	Checksum string    `json:"checksum"`
+	// Trigger contains the keys of the objects that triggered the
+	// synchronizations since the previous configuration
+	Trigger []string `json:"trigger"`
+
+	diff string
+}
+
+// configurationHistory keeps the last configurations applied in the
+// backend in a ring buffer
+type configurationHistory struct {
+	mu sync.RWMutex
+
+	entries []*historyEntry
+	// next is the position in entries of the next configuration
+	next   int
+	nextID int
+
+	last     []byte
+	triggers map[string]bool
+}
+
+func newConfigurationHistory(size int) *configurationHistory {
+	if size < 1 {
+		size = 1
+	}
+
+	return &configurationHistory{
+		entries:  make([]*historyEntry, size),
+		nextID:   1,
+		triggers: map[string]bool{},
+	}
+}
+
+// trigger records the key of an object that triggered a synchronization
+func (h *configurationHistory) trigger(key interface{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.triggers[fmt.Sprintf("%v", key)] = true
+}
+
+// add records a new configuration applied in the backend
+func (h *configurationHistory) add(data []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	trigger := []string{}
+	for key := range h.triggers {
+		trigger = append(trigger, key)
+	}
+	sort.Strings(trigger)
+
+	sum := sha1.Sum(data)
+	h.entries[h.next] = &historyEntry{
+		ID:       h.nextID,
+		Time:     time.Now(),
+		Checksum: hex.EncodeToString(sum[:]),
+		Trigger:  trigger,
+		diff:     diff.Unified(h.last, data, "previous", "current"),
+	}
+
+	h.next = (h.next + 1) % len(h.entries)
+	h.nextID++
+	h.last = data
+	h.triggers = map[string]bool{}
+}
+
+// list returns the configurations in the history, the most recent first
+func (h *configurationHistory) list() []historyEntry {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	entries := []historyEntry{}
+	for i := 1; i <= len(h.entries); i++ {
+		e := h.entries[(h.next-i+len(h.entries))%len(h.entries)]
+		if e == nil {
+			break
+		}
+		entries = append(entries, *e)
+	}
+
+	return entries
+}
+
+// ServeHTTP returns the list of configurations in the path
+// /configuration/history and the differences with the previous
+// configuration in /configuration/history/<id>/diff
+func (h *configurationHistory) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	if path == historyPath {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(h.list())
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(path, historyPath+"/"), "/")
+	if len(parts) != 2 || parts[1] != "diff" {
+		http.NotFound(w, r)
+		return
+	}
+
+	id, err := strconv.Atoi(parts[0])
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid configuration id %v", parts[0]), http.StatusBadRequest)
+		return
+	}
+
+	for _, e := range h.list() {
+		if e.ID == id {
+			w.Header().Set("Content-Type", "text/plain")
+			fmt.Fprint(w, e.diff)
+			return
+		}
+	}
+
+	http.NotFound(w, r)
+}
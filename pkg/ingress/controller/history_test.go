@@ -0,0 +1,83 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestConfigurationHistory(t *testing.T) {
+	h := newConfigurationHistory(2)
+
+	h.trigger("default/foo")
+	h.trigger("default/bar")
+	h.add([]byte("a\n"))
+	h.add([]byte("a\nb\n"))
+	h.trigger("default/foo")
+	h.add([]byte("b\n"))
+
+	entries := h.list()
+	if len(entries) != 2 {
+		t.Fatalf("expected two entries but returned %v", len(entries))
+	}
+	if entries[0].ID != 3 || entries[1].ID != 2 {
+		t.Errorf("expected the entries 3 and 2 but returned %v and %v", entries[0].ID, entries[1].ID)
+	}
+	if len(entries[0].Trigger) != 1 || entries[0].Trigger[0] != "default/foo" {
+		t.Errorf("unexpected trigger %v", entries[0].Trigger)
+	}
+	if len(entries[1].Trigger) != 0 {
+		t.Errorf("expected no trigger but returned %v", entries[1].Trigger)
+	}
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/configuration/history", http.StatusOK, `"id":3`},
+		{"/configuration/history/3/diff", http.StatusOK, "-a\n b\n"},
+		{"/configuration/history/1/diff", http.StatusNotFound, ""},
+		{"/configuration/history/abc/diff", http.StatusBadRequest, ""},
+		{"/configuration/history/3", http.StatusNotFound, ""},
+	}
+
+	for _, test := range tests {
+		req, _ := http.NewRequest("GET", test.path, nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		if w.Code != test.code {
+			t.Errorf("expected code %v for %v but returned %v", test.code, test.path, w.Code)
+		}
+		if !strings.Contains(w.Body.String(), test.body) {
+			t.Errorf("expected %v in %v but returned %v", test.body, test.path, w.Body.String())
+		}
+	}
+
+	req, _ := http.NewRequest("GET", "/configuration/history", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	var list []historyEntry
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Errorf("unexpected error decoding the history: %v", err)
+	}
+}
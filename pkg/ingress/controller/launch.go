@@ -1,12 +1,12 @@
 package controller
 
 import (
+	"bytes"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"net/http"
-	"net/http/pprof"
 	"os"
-	"syscall"
 	"time"
 
 	"github.com/golang/glog"
@@ -14,6 +14,7 @@ import (
 
 	"github.com/aledbf/ingress-controller/pkg/ingress"
 	"github.com/aledbf/ingress-controller/pkg/k8s"
+	"github.com/aledbf/ingress-controller/pkg/net/ssl"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"k8s.io/kubernetes/pkg/api"
@@ -32,7 +33,12 @@ func NewIngressController(backend ingress.Controller) Interface {
 		defaultSvc = flags.String("default-backend-service", "",
 			`Service used to serve a 404 page for the default backend. Takes the form
     	namespace/name. The controller uses the first node port of this Service for
-    	the default backend.`)
+    	the default backend. If empty the built-in default backend is used.`)
+
+		defaultBackendConfigMap = flags.String("default-backend-configmap", "",
+			`Name of the ConfigMap that contains the templates of the error pages returned
+		by the built-in default backend. The keys in the map are the format (html, json, 
+		xml or text) or the HTTP code and the format (ie. 404.html)`)
 
 		ingressClass = flags.String("ingress-class", "nginx",
 			`Name of the ingress class to route through this controller.`)
@@ -66,13 +72,90 @@ func NewIngressController(backend ingress.Controller) Interface {
 
 		healthzPort = flags.Int("healthz-port", 10254, "port for healthz endpoint.")
 
+		metricsAddress = flags.String("metrics-address", "", `Address (host:port) where the
+		metrics are exposed in the path /metrics. If empty the metrics are exposed in the
+		port of the healthz endpoint.`)
+
+		adminAddress = flags.String("admin-address", "", `Address (host:port)
+		of the admin and debug endpoints (/build, /stop, /configuration/history and
+		/debug/pprof/). If empty the admin endpoints are disabled.`)
+
+		adminTokenFile = flags.String("admin-token-file", "", `Path to a file that contains
+		the bearer token required to use the admin endpoints.`)
+
+		adminTokenReview = flags.Bool("admin-token-review", false, `Authenticate the
+		requests to the admin endpoints validating the bearer token with the
+		TokenReview API.`)
+
+		adminUsers = flags.StringSlice("admin-users", []string{}, `Users allowed to use
+		the admin endpoints when --admin-token-review is enabled. Required by
+		--admin-token-review.`)
+
+		adminTLSSecret = flags.String("admin-tls-secret", "", `Name of the secret
+		that contains the SSL certificate used by the admin endpoints. Takes the
+		form namespace/name. If empty the admin endpoints use HTTP.`)
+
+		enableStop = flags.Bool("enable-stop-endpoint", true, `Enable the /stop admin
+		endpoint that stops the controller.`)
+
 		profiling = flags.Bool("profiling", true, `Enable profiling via web interface host:port/debug/pprof/`)
 
 		defSSLCertificate = flags.String("default-ssl-certificate", "", `Name of the secret 
 		that contains a SSL certificate to be used as default for a HTTPS catch-all server`)
 
+		fakeCertificateCN = flags.String("fake-certificate-cn", ssl.FakeCertificateCN, `Common
+		name of the self-signed certificate generated when --default-ssl-certificate is not set.`)
+
+		fakeCertificateKeyType = flags.String("fake-certificate-key-type", ssl.KeyTypeRSA, `Type
+		of key (rsa or ecdsa) of the self-signed certificate generated when
+		--default-ssl-certificate is not set.`)
+
 		defHealthzURL = flags.String("health-check-path", "/healthz", `Defines 
 		the URL to be used as health check inside in the default server in NGINX.`)
+
+		historySize = flags.Int("configuration-history-size", 10, `Number of configurations
+		applied in the backend available in the URL /configuration/history of the healthz server.`)
+
+		bisectReloadFailures = flags.Bool("bisect-reload-failures", false, `Search the
+		Ingress rule that contains an invalid configuration when the backend cannot
+		be reloaded, testing the configuration with subsets of the modified rules.`)
+
+		sslExpirationWarning = flags.Duration("ssl-expiration-warning", 14*24*time.Hour, `Time
+		before the expiration of a SSL certificate when the Ingress rules using it receive
+		a Warning event.`)
+
+		shutdownGracePeriod = flags.Duration("shutdown-grace-period", 5*time.Second, `Time to
+		wait after the IP address of the node is removed from the status of the Ingress
+		rules and before the backend is stopped, so load balancers stop sending traffic.`)
+
+		shutdownTimeout = flags.Duration("shutdown-timeout", 20*time.Second, `Maximum
+		time to wait until the backend finishes the in-flight requests and exits. The sum
+		with shutdown-grace-period must be lower than the terminationGracePeriodSeconds
+		of the pod.`)
+
+		acmeDirectory = flags.String("acme-directory", "https://acme-v02.api.letsencrypt.org/directory",
+			`URL of the directory of the ACME server used to obtain certificates.`)
+
+		acmeEmail = flags.String("acme-email", "", `Contact address of the ACME account.`)
+
+		acmeAccountSecret = flags.String("acme-account-secret", "", `Name of the secret
+		(namespace/name) that contains the key of the ACME account. If the secret does not
+		exists it is created. Enables the annotation ingress.kubernetes.io/tls-acme.`)
+
+		acmeCAFile = flags.String("acme-ca-file", "", `Path of a file with the CA used to
+		verify the certificate of the ACME server.`)
+
+		enableOCSPStapling = flags.Bool("enable-ocsp-stapling", false, `Obtains the OCSP
+		responses of the SSL certificates from the OCSP responders defined in the
+		certificates to be stapled in the TLS handshake.`)
+
+		restrictSecretNamespaces = flags.Bool("restrict-secret-namespaces", false, `Only allows
+		Ingress rules to reference Secrets in their own namespace or in the namespaces
+		of --allowed-secret-namespaces.`)
+
+		allowedSecretNamespaces = flags.StringSlice("allowed-secret-namespaces", []string{}, `Namespaces
+		with Secrets that can be referenced by the Ingress rules of any namespace when
+		--restrict-secret-namespaces is enabled.`)
 	)
 
 	flags.AddGoFlagSet(flag.CommandLine)
@@ -87,10 +170,6 @@ func NewIngressController(backend ingress.Controller) Interface {
 		glog.Infof("Watching for ingress class: %s", *ingressClass)
 	}
 
-	if *defaultSvc == "" {
-		glog.Fatalf("Please specify --default-backend-service")
-	}
-
 	kubeconfig, err := restclient.InClusterConfig()
 	if err != nil {
 		kubeconfig, err = clientConfig.ClientConfig()
@@ -109,11 +188,15 @@ func NewIngressController(backend ingress.Controller) Interface {
 		glog.Fatalf("vinvalid API configuration: %v", err)
 	}
 
-	_, err = k8s.IsValidService(kubeClient, *defaultSvc)
-	if err != nil {
-		glog.Fatalf("no service with name %v found: %v", *defaultSvc, err)
+	if *defaultSvc != "" {
+		_, err = k8s.IsValidService(kubeClient, *defaultSvc)
+		if err != nil {
+			glog.Fatalf("no service with name %v found: %v", *defaultSvc, err)
+		}
+		glog.Infof("validated %v as the default backend", *defaultSvc)
+	} else {
+		glog.Infof("using the built-in default backend")
 	}
-	glog.Infof("validated %v as the default backend", *defaultSvc)
 
 	if *publishSvc != "" {
 		svc, err := k8s.IsValidService(kubeClient, *publishSvc)
@@ -129,6 +212,10 @@ func NewIngressController(backend ingress.Controller) Interface {
 		glog.Infof("service %v validated as source of Ingress status", *publishSvc)
 	}
 
+	if *fakeCertificateKeyType != ssl.KeyTypeRSA && *fakeCertificateKeyType != ssl.KeyTypeECDSA {
+		glog.Fatalf("invalid fake certificate key type %v (rsa or ecdsa)", *fakeCertificateKeyType)
+	}
+
 	if *configMap != "" {
 		_, _, err = k8s.ParseNameNS(*configMap)
 		if err != nil {
@@ -136,49 +223,104 @@ func NewIngressController(backend ingress.Controller) Interface {
 		}
 	}
 
+	if *defaultBackendConfigMap != "" {
+		_, _, err = k8s.ParseNameNS(*defaultBackendConfigMap)
+		if err != nil {
+			glog.Fatalf("configmap error: %v", err)
+		}
+	}
+
+	if *adminTokenFile != "" && *adminTokenReview {
+		glog.Fatalf("the flags --admin-token-file and --admin-token-review cannot be used together")
+	}
+
+	admin := adminOptions{
+		address:         *adminAddress,
+		enableProfiling: *profiling,
+		enableStop:      *enableStop,
+	}
+
+	if *adminTokenFile != "" {
+		token, err := ioutil.ReadFile(*adminTokenFile)
+		if err != nil {
+			glog.Fatalf("error reading admin token: %v", err)
+		}
+		if len(bytes.TrimSpace(token)) == 0 {
+			glog.Fatalf("the admin token file %v is empty", *adminTokenFile)
+		}
+		admin.authn = staticToken(bytes.TrimSpace(token))
+	}
+
+	if *adminTokenReview {
+		if len(*adminUsers) == 0 {
+			glog.Fatalf("the flag --admin-token-review requires the list of users in --admin-users")
+		}
+		admin.authn = newTokenReview(leaderElectionClient.Authentication(), *adminUsers)
+	}
+
+	if *adminTLSSecret != "" {
+		admin.tlsConfig, err = getAdminTLSConfig(kubeClient, *adminTLSSecret)
+		if err != nil {
+			glog.Fatalf("admin TLS error: %v", err)
+		}
+	}
+
 	os.MkdirAll(ingress.DefaultSSLDirectory, 0655)
 
 	config := &Configuration{
-		Client:                kubeClient,
-		ElectionClient:        leaderElectionClient,
-		ResyncPeriod:          *resyncPeriod,
-		DefaultService:        *defaultSvc,
-		IngressClass:          *ingressClass,
-		Namespace:             *watchNamespace,
-		ConfigMapName:         *configMap,
-		TCPConfigMapName:      *tcpConfigMapName,
-		UDPConfigMapName:      *udpConfigMapName,
-		DefaultSSLCertificate: *defSSLCertificate,
-		DefaultHealthzURL:     *defHealthzURL,
-		PublishService:        *publishSvc,
-		Backend:               backend,
+		Client:                      kubeClient,
+		ElectionClient:              leaderElectionClient,
+		ResyncPeriod:                *resyncPeriod,
+		DefaultService:              *defaultSvc,
+		DefaultBackendConfigMapName: *defaultBackendConfigMap,
+		IngressClass:                *ingressClass,
+		Namespace:                   *watchNamespace,
+		ConfigMapName:               *configMap,
+		TCPConfigMapName:            *tcpConfigMapName,
+		UDPConfigMapName:            *udpConfigMapName,
+		DefaultSSLCertificate:       *defSSLCertificate,
+		FakeCertificateCN:           *fakeCertificateCN,
+		FakeCertificateKeyType:      *fakeCertificateKeyType,
+		DefaultHealthzURL:           *defHealthzURL,
+		PublishService:              *publishSvc,
+		HistorySize:                 *historySize,
+		BisectReloadFailures:        *bisectReloadFailures,
+		ShutdownGracePeriod:         *shutdownGracePeriod,
+		ShutdownTimeout:             *shutdownTimeout,
+		SSLExpirationWarning:        *sslExpirationWarning,
+		ACMEDirectory:               *acmeDirectory,
+		ACMEEmail:                   *acmeEmail,
+		ACMEAccountSecret:           *acmeAccountSecret,
+		ACMECAFile:                  *acmeCAFile,
+		EnableOCSPStapling:          *enableOCSPStapling,
+		RestrictSecretNamespaces:    *restrictSecretNamespaces,
+		AllowedSecretNamespaces:     *allowedSecretNamespaces,
+		Backend:                     backend,
 	}
 
 	ic := newIngressController(config)
-	go registerHandlers(*profiling, *healthzPort, ic)
+	go registerHandlers(*healthzPort, *metricsAddress, ic)
+	if *adminAddress != "" {
+		go runAdminServer(admin, ic)
+	}
 	return ic
 }
 
-func registerHandlers(enableProfiling bool, port int, ic Interface) {
+func registerHandlers(port int, metricsAddress string, ic *GenericController) {
 	mux := http.NewServeMux()
 	healthz.InstallHandler(mux, ic)
 
-	mux.Handle("/metrics", prometheus.Handler())
-
-	mux.HandleFunc("/build", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		fmt.Fprintf(w, ic.Info())
-	})
-
-	mux.HandleFunc("/stop", func(w http.ResponseWriter, r *http.Request) {
-		syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
-	})
-
-	if enableProfiling {
-		mux.HandleFunc("/debug/pprof/", pprof.Index)
-		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
-		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
-		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/livez", healthHandler(ic.Check))
+	mux.HandleFunc("/readyz", healthHandler(ic.Ready))
+
+	if metricsAddress == "" {
+		mux.Handle("/metrics", prometheus.Handler())
+	} else {
+		go func() {
+			metrics := http.NewServeMux()
+			metrics.Handle("/metrics", prometheus.Handler())
+			glog.Fatal(http.ListenAndServe(metricsAddress, metrics))
+		}()
 	}
 
 	server := &http.Server{
@@ -187,3 +329,15 @@ func registerHandlers(enableProfiling bool, port int, ic Interface) {
 	}
 	glog.Fatal(server.ListenAndServe())
 }
+
+// healthHandler returns an HTTP handler that returns ok (status code 200)
+// or the error returned by the check (status code 500)
+func healthHandler(check func(*http.Request) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := check(r); err != nil {
+			http.Error(w, fmt.Sprintf("%v", err), http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}
+}
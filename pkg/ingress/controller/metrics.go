@@ -16,17 +16,41 @@ limitations under the License.
 
 package controller
 
-import "github.com/prometheus/client_golang/prometheus"
+import (
+	"hash/fnv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/aledbf/ingress-controller/pkg/ingress"
+	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/parser"
+)
 
 const (
 	ns          = "ingress_controller"
 	operation   = "count"
 	reloadLabel = "reloads"
+
+	// operations with duration measured in the histogram operation_duration_seconds
+	syncOperation               = "sync"
+	getUpstreamServersOperation = "get_upstream_servers"
+	backendUpdateOperation      = "backend_update"
+	reloadOperationName         = "reload"
 )
 
 func init() {
 	prometheus.MustRegister(reloadOperation)
 	prometheus.MustRegister(reloadOperationErrors)
+	prometheus.MustRegister(backendRestarts)
+	prometheus.MustRegister(backendUp)
+	prometheus.MustRegister(operationDuration)
+	prometheus.MustRegister(configurationObjects)
+	prometheus.MustRegister(configurationSize)
+	prometheus.MustRegister(configurationHash)
+	prometheus.MustRegister(lastReloadSuccess)
+	prometheus.MustRegister(annotationErrors)
+	prometheus.MustRegister(sslExpireTime)
+	prometheus.MustRegister(removedFiles)
 
 	reloadOperationErrors.WithLabelValues(reloadLabel).Set(0)
 	reloadOperation.WithLabelValues(reloadLabel).Set(0)
@@ -49,6 +73,82 @@ var (
 		},
 		[]string{operation},
 	)
+	backendRestarts = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: ns,
+			Name:      "backend_restarts",
+			Help:      "Cumulative number of restarts of the backend process",
+		},
+	)
+	backendUp = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: ns,
+			Name:      "backend_up",
+			Help:      "Indicates if the backend process is running (1) or not (0)",
+		},
+	)
+	operationDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: ns,
+			Name:      "operation_duration_seconds",
+			Help:      "Time spent in the sync of the configuration and the reload of the backend",
+			Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+		},
+		[]string{"operation"},
+	)
+	configurationObjects = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: ns,
+			Name:      "configuration_objects",
+			Help:      "Number of servers, locations, upstreams and endpoints in the running configuration",
+		},
+		[]string{"type"},
+	)
+	configurationSize = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: ns,
+			Name:      "configuration_size_bytes",
+			Help:      "Size of the running configuration of the backend",
+		},
+	)
+	configurationHash = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: ns,
+			Name:      "configuration_hash",
+			Help:      "Hash (FNV-1a 32 bits) of the running configuration of the backend",
+		},
+	)
+	lastReloadSuccess = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: ns,
+			Name:      "last_reload_success_timestamp_seconds",
+			Help:      "Timestamp of the last successful reload of the backend",
+		},
+	)
+	annotationErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: ns,
+			Name:      "annotation_errors",
+			Help:      "Cumulative number of errors parsing the annotations of the Ingress rules",
+		},
+		[]string{"annotation"},
+	)
+	removedFiles = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: ns,
+			Name:      "removed_files",
+			Help:      "Cumulative number of unused SSL certificates and authentication files removed from disk",
+		},
+		[]string{"type"},
+	)
+	sslExpireTime = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: ns,
+			Name:      "ssl_expire_time_seconds",
+			Help:      "Number of seconds until the SSL certificate expires (negative if expired)",
+		},
+		[]string{"namespace", "secret", "host"},
+	)
 )
 
 func incReloadCount() {
@@ -58,3 +158,69 @@ func incReloadCount() {
 func incReloadErrorCount() {
 	reloadOperationErrors.WithLabelValues(reloadLabel).Inc()
 }
+
+func incBackendRestartCount() {
+	backendRestarts.Inc()
+}
+
+func setBackendUp(up bool) {
+	if up {
+		backendUp.Set(1)
+		return
+	}
+	backendUp.Set(0)
+}
+
+// observeOperationDuration records the time elapsed since start
+func observeOperationDuration(operation string, start time.Time) {
+	operationDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+// incAnnotationErrorCount counts an error parsing an annotation. The
+// absence of the annotation is not considered an error
+func incAnnotationErrorCount(annotation string, err error) {
+	if err == nil || err == parser.ErrMissingAnnotations {
+		return
+	}
+	annotationErrors.WithLabelValues(annotation).Inc()
+}
+
+// incAnnotationErrorCount counts an error parsing an annotation
+// unless the controller is building a candidate configuration
+func (ic *GenericController) incAnnotationErrorCount(annotation string, err error) {
+	if ic.quiet {
+		return
+	}
+	incAnnotationErrorCount(annotation, err)
+}
+
+// setConfigurationMetrics updates the metrics of the configuration
+// successfully applied in the backend
+func setConfigurationMetrics(pcfg *ingress.Configuration, data []byte) {
+	var locations, endpoints int
+	for _, server := range pcfg.Servers {
+		locations += len(server.Locations)
+	}
+	for _, upstream := range pcfg.Upstreams {
+		endpoints += len(upstream.Backends)
+	}
+
+	configurationObjects.WithLabelValues("servers").Set(float64(len(pcfg.Servers)))
+	configurationObjects.WithLabelValues("locations").Set(float64(locations))
+	configurationObjects.WithLabelValues("upstreams").Set(float64(len(pcfg.Upstreams)))
+	configurationObjects.WithLabelValues("endpoints").Set(float64(endpoints))
+
+	h := fnv.New32a()
+	h.Write(data)
+	configurationHash.Set(float64(h.Sum32()))
+	configurationSize.Set(float64(len(data)))
+	lastReloadSuccess.Set(float64(time.Now().Unix()))
+}
+
+func setSSLExpireTime(namespace, secret, host string, remaining time.Duration) {
+	sslExpireTime.WithLabelValues(namespace, secret, host).Set(remaining.Seconds())
+}
+
+func resetSSLExpireTime() {
+	sslExpireTime.Reset()
+}
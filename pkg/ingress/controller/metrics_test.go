@@ -0,0 +1,82 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/aledbf/ingress-controller/pkg/ingress"
+	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/parser"
+)
+
+func metricValue(t *testing.T, m prometheus.Metric) float64 {
+	v := &dto.Metric{}
+	if err := m.Write(v); err != nil {
+		t.Fatalf("unexpected error reading metric: %v", err)
+	}
+	if v.Counter != nil {
+		return v.Counter.GetValue()
+	}
+	return v.Gauge.GetValue()
+}
+
+func TestIncAnnotationErrorCount(t *testing.T) {
+	counter := annotationErrors.WithLabelValues("test")
+
+	incAnnotationErrorCount("test", nil)
+	incAnnotationErrorCount("test", parser.ErrMissingAnnotations)
+	if v := metricValue(t, counter); v != 0 {
+		t.Errorf("expected 0 errors but returned %v", v)
+	}
+
+	incAnnotationErrorCount("test", fmt.Errorf("invalid value"))
+	if v := metricValue(t, counter); v != 1 {
+		t.Errorf("expected 1 error but returned %v", v)
+	}
+}
+
+func TestSetConfigurationMetrics(t *testing.T) {
+	pcfg := &ingress.Configuration{
+		Servers: []*ingress.Server{
+			{Name: "foo.bar", Locations: []*ingress.Location{{Path: "/"}, {Path: "/bar"}}},
+			{Name: "_", Locations: []*ingress.Location{{Path: "/"}}},
+		},
+		Upstreams: []*ingress.Upstream{
+			{Name: "default-foo-80", Backends: []ingress.UpstreamServer{{Address: "10.0.0.1"}, {Address: "10.0.0.2"}}},
+		},
+	}
+
+	setConfigurationMetrics(pcfg, []byte("configuration"))
+
+	expected := map[string]float64{"servers": 2, "locations": 3, "upstreams": 1, "endpoints": 2}
+	for name, value := range expected {
+		if v := metricValue(t, configurationObjects.WithLabelValues(name)); v != value {
+			t.Errorf("expected %v %v but returned %v", value, name, v)
+		}
+	}
+
+	if v := metricValue(t, configurationSize); v != 13 {
+		t.Errorf("expected a size of 13 bytes but returned %v", v)
+	}
+	if v := metricValue(t, lastReloadSuccess); v == 0 {
+		t.Errorf("expected the timestamp of the last reload")
+	}
+}
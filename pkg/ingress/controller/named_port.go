@@ -62,7 +62,7 @@ func (ic *GenericController) checkSvcForUpdate(svc *api.Service) error {
 		if err != nil {
 			portNum, err := podutil.FindPort(pod, servicePort)
 			if err != nil {
-				glog.V(4).Infof("failed to find port for service %s/%s: %v", portNum, svc.Namespace, svc.Name, err)
+				glog.V(4).Infof("failed to find port %v for service %s/%s: %v", portNum, svc.Namespace, svc.Name, err)
 				continue
 			}
 
@@ -0,0 +1,296 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+
+	"k8s.io/kubernetes/pkg/api"
+
+	"github.com/aledbf/ingress-controller/pkg/ingress"
+	"github.com/aledbf/ingress-controller/pkg/net/ocsp"
+	"github.com/aledbf/ingress-controller/pkg/net/ssl"
+)
+
+const (
+	// interval between the checks of the OCSP responses of the certificates
+	ocspCheckInterval = 5 * time.Minute
+	// time to wait before requesting again the response of a certificate
+	// without OCSP responder or when the request failed
+	ocspRetryInterval = time.Hour
+	// maximum time to wait for the response of an OCSP responder
+	ocspRequestTimeout = 30 * time.Second
+	// a new response replaces the response loaded in the backend, which
+	// requires a reload, only when the loaded response expires within
+	// this interval. Otherwise the new response is used after the next reload
+	ocspReloadMargin = 24 * time.Hour
+)
+
+// ocspStapler obtains the OCSP responses of the certificates and stores
+// them in files referenced in the configuration of the servers. The
+// backend staples the responses in the TLS handshake without contacting
+// the OCSP responders
+type ocspStapler struct {
+	client *ocsp.Client
+
+	mu sync.Mutex
+	// responses by pem file of the certificate
+	responses map[string]*ocspEntry
+}
+
+type ocspEntry struct {
+	// file with the response. nil if there is no valid response
+	file *ingress.SSLFile
+	// checksum of the certificate of the response
+	pemSHA string
+	// response in the configuration of the backend. The file
+	// can contain a newer response
+	response *ocsp.Response
+	// time to request a new response
+	refresh time.Time
+}
+
+func newOCSPStapler(client *ocsp.Client) *ocspStapler {
+	return &ocspStapler{
+		client:    client,
+		responses: map[string]*ocspEntry{},
+	}
+}
+
+// get returns the file with the OCSP response of a certificate
+// or nil if stapling is disabled or there is no valid response
+func (s *ocspStapler) get(pemFileName string) *ingress.SSLFile {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.responses[pemFileName]; ok {
+		return e.file
+	}
+	return nil
+}
+
+// files returns the files with the responses of the certificates
+func (s *ocspStapler) files() []string {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	files := []string{}
+	for _, e := range s.responses {
+		if e.file != nil {
+			files = append(files, e.file.FileName)
+		}
+	}
+	return files
+}
+
+func (s *ocspStapler) entry(pemFileName string) *ocspEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.responses[pemFileName]
+}
+
+// set replaces the response of a certificate and returns
+// true if the file used in the configuration changed
+func (s *ocspStapler) set(pemFileName string, e *ocspEntry) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev := s.responses[pemFileName]
+	if e == nil {
+		delete(s.responses, pemFileName)
+	} else {
+		s.responses[pemFileName] = e
+	}
+	return !equalSSLFile(fileOf(prev), fileOf(e))
+}
+
+// prune removes the responses of the certificates not in use
+func (s *ocspStapler) prune(inUse map[string]bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changed := false
+	for name, e := range s.responses {
+		if !inUse[name] {
+			changed = changed || e.file != nil
+			delete(s.responses, name)
+		}
+	}
+	return changed
+}
+
+// update requests a new response of a certificate if there is no response
+// or the current response reached the middle of its validity period.
+// Returns true if the file used in the configuration changed
+func (s *ocspStapler) update(cert *ingress.SSLCert, now time.Time) bool {
+	current := s.entry(cert.PemFileName)
+	if current != nil && current.pemSHA == cert.PemSHA && now.Before(current.refresh) {
+		return false
+	}
+
+	leaf, issuer, err := ssl.GetCertificateAndIssuer(cert)
+	if err == nil && len(leaf.OCSPServer) == 0 {
+		err = ocsp.ErrNoOCSPServer
+	}
+	if err != nil {
+		// ie self-signed certificates
+		glog.V(3).Infof("OCSP stapling disabled for the certificate %v: %v", cert.PemFileName, err)
+		return s.set(cert.PemFileName, &ocspEntry{pemSHA: cert.PemSHA, refresh: now.Add(ocspRetryInterval)})
+	}
+
+	if current == nil {
+		// the response stored in a previous execution avoids a request on start
+		if e := readOCSPResponse(cert, leaf, issuer, now); e != nil && now.Before(e.refresh) {
+			return s.set(cert.PemFileName, e)
+		}
+	}
+
+	resp, err := s.client.Fetch(leaf, issuer)
+	if err == nil && resp.Status != ocsp.Good {
+		err = fmt.Errorf("the status of the certificate is not good (%v)", resp.Status)
+	}
+	if err == nil && !resp.IsValid(now) {
+		err = fmt.Errorf("the response is not valid (%v - %v)", resp.ThisUpdate, resp.NextUpdate)
+	}
+	var file *ingress.SSLFile
+	if err == nil {
+		file, err = ssl.AddOrUpdateOCSPResponse(cert, resp.Raw)
+	}
+	if err != nil {
+		glog.Warningf("error obtaining the OCSP response of the certificate %v: %v", cert.PemFileName, err)
+		if current != nil && current.file != nil && current.pemSHA == cert.PemSHA && current.response.IsValid(now) {
+			// the current response is used until it expires
+			current.refresh = now.Add(ocspRetryInterval)
+			return false
+		}
+		return s.set(cert.PemFileName, &ocspEntry{pemSHA: cert.PemSHA, refresh: now.Add(ocspRetryInterval)})
+	}
+
+	glog.V(2).Infof("new OCSP response of the certificate %v (next update %v)", cert.PemFileName, resp.NextUpdate)
+	refresh := resp.RefreshTime()
+	if refresh.Before(now.Add(ocspRetryInterval)) {
+		// ie responses without NextUpdate and an old ThisUpdate
+		refresh = now.Add(ocspRetryInterval)
+	}
+	if current != nil && current.file != nil && current.pemSHA == cert.PemSHA && current.file.FileName == file.FileName &&
+		current.response.IsValid(now.Add(ocspReloadMargin)) {
+		// the response loaded in the backend is still valid. The file
+		// already contains the new response, used after the next reload
+		current.refresh = refresh
+		if !current.response.NextUpdate.IsZero() {
+			if reload := current.response.NextUpdate.Add(-ocspReloadMargin); reload.Before(refresh) {
+				current.refresh = reload
+			}
+		}
+		return false
+	}
+
+	return s.set(cert.PemFileName, &ocspEntry{
+		file:     file,
+		pemSHA:   cert.PemSHA,
+		response: resp,
+		refresh:  refresh,
+	})
+}
+
+// readOCSPResponse returns the response stored in a previous
+// execution if it is still valid
+func readOCSPResponse(cert *ingress.SSLCert, leaf, issuer *x509.Certificate, now time.Time) *ocspEntry {
+	file, der, err := ssl.GetOCSPResponse(cert)
+	if err != nil {
+		return nil
+	}
+	resp, err := ocsp.ParseResponse(der, leaf, issuer)
+	if err != nil || resp.Status != ocsp.Good || !resp.IsValid(now) {
+		return nil
+	}
+	return &ocspEntry{
+		file:     file,
+		pemSHA:   cert.PemSHA,
+		response: resp,
+		refresh:  resp.RefreshTime(),
+	}
+}
+
+func fileOf(e *ocspEntry) *ingress.SSLFile {
+	if e == nil {
+		return nil
+	}
+	return e.file
+}
+
+func equalSSLFile(a, b *ingress.SSLFile) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// checkOCSP updates the OCSP responses of the certificates and
+// the configuration of the backend if a response changed
+func (ic *GenericController) checkOCSP() {
+	now := time.Now()
+	changed := false
+	inUse := map[string]bool{}
+	for _, certIf := range ic.sslCertTracker.List() {
+		cert := certIf.(*ingress.SSLCert)
+		inUse[cert.PemFileName] = true
+		if ic.ocsp.update(cert, now) {
+			changed = true
+		}
+	}
+	if ic.ocsp.prune(inUse) {
+		changed = true
+	}
+
+	if changed {
+		ic.syncQueue.Enqueue(&api.Secret{
+			ObjectMeta: api.ObjectMeta{
+				Namespace: api.NamespaceDefault,
+				Name:      "ocsp-responses",
+			},
+		})
+	}
+}
+
+// configureOCSPStapling adds the OCSP response of the certificate to
+// a server. The backend uses the same response with all the certificates
+// of a server, so the response is not used with a RSA and an ECDSA certificate
+func (ic *GenericController) configureOCSPStapling(server *ingress.Server) {
+	if server.SSLCertificateECDSA != "" {
+		return
+	}
+	if f := ic.ocsp.get(server.SSLCertificate); f != nil {
+		server.SSLStaplingFile = f.FileName
+		server.SSLStaplingChecksum = f.Checksum
+	}
+}
+
+// newOCSPClient returns the client used to request the OCSP responses
+func newOCSPClient() *ocsp.Client {
+	return &ocsp.Client{
+		HTTPClient: &http.Client{Timeout: ocspRequestTimeout},
+	}
+}
@@ -0,0 +1,204 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	xocsp "golang.org/x/crypto/ocsp"
+
+	"github.com/aledbf/ingress-controller/pkg/ingress"
+	"github.com/aledbf/ingress-controller/pkg/net/ssl"
+)
+
+func TestOCSPStaplerSelfSigned(t *testing.T) {
+	certPEM, keyPEM, err := ssl.GetFakeSSLCert("foo.bar", ssl.KeyTypeECDSA)
+	if err != nil {
+		t.Fatalf("unexpected error generating certificate: %v", err)
+	}
+	cert, err := ssl.AddOrUpdateCertAndKey(fmt.Sprintf("test-ocsp-%v", time.Now().UnixNano()), certPEM, keyPEM, []byte{})
+	if err != nil {
+		t.Fatalf("unexpected error writing certificate: %v", err)
+	}
+	defer os.Remove(cert.PemFileName)
+
+	s := newOCSPStapler(newOCSPClient())
+	now := time.Now()
+	// without issuer the response is not requested
+	if s.update(cert, now) {
+		t.Errorf("expected no changes with a self-signed certificate")
+	}
+	if f := s.get(cert.PemFileName); f != nil {
+		t.Errorf("expected no response but returned %v", f)
+	}
+	if e := s.entry(cert.PemFileName); e == nil || !e.refresh.Equal(now.Add(ocspRetryInterval)) {
+		t.Errorf("expected a new check after %v", ocspRetryInterval)
+	}
+
+	if s.prune(map[string]bool{}) {
+		t.Errorf("expected no changes removing a certificate without response")
+	}
+	if e := s.entry(cert.PemFileName); e != nil {
+		t.Errorf("expected the certificate removed")
+	}
+}
+
+func TestOCSPStaplerReload(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Minute)
+
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caDER, _ := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(30 * 24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, &x509.Certificate{Subject: pkix.Name{CommonName: "Test CA"}}, caKey.Public(), caKey)
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatalf("unexpected error creating CA: %v", err)
+	}
+
+	// responses valid for 4 days produced at thisUpdate
+	var thisUpdate time.Time
+	responder := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		req, err := xocsp.ParseRequest(body)
+		if err != nil {
+			t.Errorf("invalid OCSP request: %v", err)
+			return
+		}
+		der, err := xocsp.CreateResponse(ca, ca, xocsp.Response{
+			Status:       xocsp.Good,
+			SerialNumber: req.SerialNumber,
+			ThisUpdate:   thisUpdate,
+			NextUpdate:   thisUpdate.Add(4 * 24 * time.Hour),
+		}, caKey)
+		if err != nil {
+			t.Errorf("unexpected error creating OCSP response: %v", err)
+		}
+		w.Write(der)
+	}))
+	defer responder.Close()
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	leafDER, _ := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(10),
+		Subject:      pkix.Name{CommonName: "foo.bar"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(30 * 24 * time.Hour),
+		OCSPServer:   []string{responder.URL},
+	}, ca, key.Public(), caKey)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	chain := append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})...)
+	cert, err := ssl.AddOrUpdateCertAndKey(fmt.Sprintf("test-ocsp-%v", time.Now().UnixNano()), chain,
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), []byte{})
+	if err != nil {
+		t.Fatalf("unexpected error writing certificate: %v", err)
+	}
+	defer os.Remove(cert.PemFileName)
+	defer os.Remove(ssl.OCSPResponseFileName(cert))
+
+	s := newOCSPStapler(newOCSPClient())
+	thisUpdate = now
+	if !s.update(cert, now) {
+		t.Fatalf("expected changes with the first response")
+	}
+	loaded := s.get(cert.PemFileName)
+	if loaded == nil {
+		t.Fatalf("expected a response")
+	}
+	if e := s.entry(cert.PemFileName); !e.refresh.Equal(now.Add(2 * 24 * time.Hour)) {
+		t.Errorf("expected a new request in the middle of the validity period but returned %v", e.refresh)
+	}
+
+	// the new response does not replace the loaded response, still valid
+	now = now.Add(2 * 24 * time.Hour)
+	thisUpdate = now
+	if s.update(cert, now) {
+		t.Errorf("expected no changes while the loaded response is valid")
+	}
+	if f := s.get(cert.PemFileName); *f != *loaded {
+		t.Errorf("expected the loaded response %v but returned %v", loaded, f)
+	}
+	if e := s.entry(cert.PemFileName); !e.refresh.Equal(now.Add(ocspReloadMargin)) {
+		t.Errorf("expected a new request before the loaded response expires but returned %v", e.refresh)
+	}
+
+	// the loaded response expires before the margin
+	now = now.Add(ocspReloadMargin)
+	thisUpdate = now
+	if !s.update(cert, now) {
+		t.Errorf("expected changes replacing a response about to expire")
+	}
+}
+
+func TestConfigureOCSPStapling(t *testing.T) {
+	s := newOCSPStapler(newOCSPClient())
+	file := &ingress.SSLFile{FileName: "/ssl/default-foo.ocsp", Checksum: "1"}
+	if !s.set("/ssl/default-foo.pem", &ocspEntry{file: file}) {
+		t.Errorf("expected changes adding a response")
+	}
+	if s.set("/ssl/default-foo.pem", &ocspEntry{file: &ingress.SSLFile{FileName: "/ssl/default-foo.ocsp", Checksum: "1"}}) {
+		t.Errorf("expected no changes with the same response")
+	}
+
+	ic := &GenericController{ocsp: s}
+	server := &ingress.Server{Name: "foo.bar", SSLCertificate: "/ssl/default-foo.pem"}
+	ic.configureOCSPStapling(server)
+	if server.SSLStaplingFile != file.FileName || server.SSLStaplingChecksum != file.Checksum {
+		t.Errorf("expected the OCSP response in the server but returned %v", server.SSLStaplingFile)
+	}
+
+	dual := &ingress.Server{Name: "foo.bar", SSLCertificate: "/ssl/default-foo.pem", SSLCertificateECDSA: "/ssl/default-ecdsa.pem"}
+	ic.configureOCSPStapling(dual)
+	if dual.SSLStaplingFile != "" {
+		t.Errorf("expected no OCSP response with two certificates")
+	}
+
+	if files := s.files(); len(files) != 1 || files[0] != file.FileName {
+		t.Errorf("expected the file %v in use but returned %v", file.FileName, files)
+	}
+
+	// disabled stapling
+	ic = &GenericController{}
+	server = &ingress.Server{Name: "foo.bar", SSLCertificate: "/ssl/default-foo.pem"}
+	ic.configureOCSPStapling(server)
+	if server.SSLStaplingFile != "" {
+		t.Errorf("expected no OCSP response without stapling")
+	}
+
+	if !s.prune(map[string]bool{}) {
+		t.Errorf("expected changes removing a certificate with response")
+	}
+}
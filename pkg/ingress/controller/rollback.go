@@ -0,0 +1,248 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang/glog"
+
+	"github.com/aledbf/ingress-controller/pkg/ingress"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/apis/extensions"
+	"k8s.io/kubernetes/pkg/client/record"
+)
+
+// lastKnownGood keeps the last configuration successfully applied in
+// the backend and the Ingress rules used to generate it
+type lastKnownGood struct {
+	mu            sync.Mutex
+	data          []byte
+	configuration *ingress.Configuration
+	configMap     map[string]string
+	ingresses     map[string]*extensions.Ingress
+	// failure identifies the last change that could not be applied
+	// to avoid duplicated events
+	failure string
+}
+
+func newLastKnownGood() *lastKnownGood {
+	return &lastKnownGood{
+		ingresses: map[string]*extensions.Ingress{},
+	}
+}
+
+// update replaces the last known good configuration
+func (l *lastKnownGood) update(data []byte, ings []interface{}, cfg *api.ConfigMap, pcfg *ingress.Configuration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.data = data
+	l.configuration = pcfg
+	l.configMap = cfg.Data
+	l.ingresses = map[string]*extensions.Ingress{}
+	for _, ingIf := range ings {
+		ing := ingIf.(*extensions.Ingress)
+		l.ingresses[ingressKey(ing)] = ing
+	}
+	l.failure = ""
+}
+
+// current returns the last known good configuration or nil if no
+// configuration was applied
+func (l *lastKnownGood) current() *ingress.Configuration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.configuration
+}
+
+// compare returns the differences between the last known good
+// configuration and a new one
+func (l *lastKnownGood) compare(cfg *api.ConfigMap, pcfg *ingress.Configuration) *ingress.ConfigurationChanges {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	changes := ingress.CompareConfigurations(l.configuration, pcfg)
+	changes.ConfigMap = l.configuration == nil || !reflect.DeepEqual(l.configMap, cfg.Data)
+	return changes
+}
+
+// changedIngresses returns the Ingress rules created or modified since the
+// last known good configuration and the rules that did not change
+// (using the version of the last known good configuration for the
+// modified rules)
+func (l *lastKnownGood) changedIngresses(ings []interface{}) ([]*extensions.Ingress, []interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var changed []*extensions.Ingress
+	var unchanged []interface{}
+	for _, ingIf := range ings {
+		ing := ingIf.(*extensions.Ingress)
+		old, ok := l.ingresses[ingressKey(ing)]
+		if ok && old.ResourceVersion == ing.ResourceVersion {
+			unchanged = append(unchanged, ing)
+			continue
+		}
+
+		changed = append(changed, ing)
+		if ok {
+			unchanged = append(unchanged, old)
+		}
+	}
+
+	sort.Sort(ingressByKey(changed))
+	return changed, unchanged
+}
+
+// onReloadFailure is invoked when a new configuration cannot be applied.
+// If the configuration file was replaced the last known good configuration
+// is restored. The Ingress rules modified since the last known good
+// configuration receive a Warning event and, if enabled, a bisection
+// is used to find the rule that contains the invalid configuration.
+func (ic *GenericController) onReloadFailure(cfg *api.ConfigMap, ings []interface{}, reloadErr error, rollback bool) {
+	ic.lastGood.mu.Lock()
+	data := ic.lastGood.data
+	ic.lastGood.mu.Unlock()
+
+	if rollback {
+		if len(data) == 0 {
+			glog.Warningf("there is no previous valid configuration to rollback")
+		} else {
+			glog.Infof("restoring last known good configuration...")
+			out, err := ic.cfg.Backend.Restart(data)
+			if err != nil {
+				glog.Errorf("unexpected failure restoring the last known good configuration: \n%v", string(out))
+			}
+		}
+	}
+
+	changed, unchanged := ic.lastGood.changedIngresses(ings)
+
+	keys := []string{}
+	for _, ing := range changed {
+		keys = append(keys, fmt.Sprintf("%v@%v", ingressKey(ing), ing.ResourceVersion))
+	}
+	failure := fmt.Sprintf("%v|%v", cfg.ResourceVersion, strings.Join(keys, ","))
+
+	ic.lastGood.mu.Lock()
+	if ic.lastGood.failure == failure {
+		ic.lastGood.mu.Unlock()
+		return
+	}
+	ic.lastGood.failure = failure
+	ic.lastGood.mu.Unlock()
+
+	if len(changed) == 0 {
+		glog.Warningf("the configuration could not be applied and there are no changes in Ingress rules since the last known good configuration. Please check the configmap %v", ic.cfg.ConfigMapName)
+		return
+	}
+
+	glog.Warningf("the configuration could not be applied. Ingress rules involved in the change: %v", strings.Join(keys, ", "))
+	for _, ing := range changed {
+		ic.recorder.Eventf(ing, api.EventTypeWarning, "RELOAD",
+			"the configuration that includes Ingress %v could not be applied. Using last known good configuration", ingressKey(ing))
+	}
+
+	if !ic.cfg.BisectReloadFailures {
+		return
+	}
+
+	ing := ic.bisect(cfg, changed, unchanged)
+	if ing == nil {
+		glog.Warningf("unable to find the Ingress rule with an invalid configuration")
+		return
+	}
+
+	glog.Warningf("Ingress %v contains an invalid configuration: %v", ingressKey(ing), reloadErr)
+	ic.recorder.Eventf(ing, api.EventTypeWarning, "RELOAD",
+		"Ingress %v contains an invalid configuration", ingressKey(ing))
+}
+
+// bisect searches the Ingress rule that generates an invalid configuration
+// adding halves of the changed rules to the rules of the last known good
+// configuration. The search assumes there is only one invalid rule.
+func (ic *GenericController) bisect(cfg *api.ConfigMap, changed []*extensions.Ingress, unchanged []interface{}) *extensions.Ingress {
+	// the events and metrics were already emitted building the
+	// configuration that could not be applied
+	candidate := ic.quietCopy()
+
+	isValid := func(candidates []*extensions.Ingress) bool {
+		replaced := map[string]bool{}
+		ings := []interface{}{}
+		for _, ing := range candidates {
+			replaced[ingressKey(ing)] = true
+			ings = append(ings, ing)
+		}
+		for _, ingIf := range unchanged {
+			if !replaced[ingressKey(ingIf.(*extensions.Ingress))] {
+				ings = append(ings, ingIf)
+			}
+		}
+
+		_, err := ic.cfg.Backend.OnUpdate(cfg, candidate.getConfiguration(cfg, ings))
+		return err == nil
+	}
+
+	if isValid(changed) {
+		// the configuration is valid but the reload failed
+		return nil
+	}
+
+	candidates := changed
+	for len(candidates) > 1 {
+		half := candidates[:len(candidates)/2]
+		if isValid(half) {
+			candidates = candidates[len(candidates)/2:]
+		} else {
+			candidates = half
+		}
+	}
+
+	if isValid(candidates) {
+		return nil
+	}
+
+	return candidates[0]
+}
+
+// quietCopy returns a copy of the controller that does not emit events
+// or update metrics while building configurations
+func (ic *GenericController) quietCopy() *GenericController {
+	c := *ic
+	c.recorder = &record.FakeRecorder{}
+	c.quiet = true
+	return &c
+}
+
+func ingressKey(ing *extensions.Ingress) string {
+	return fmt.Sprintf("%v/%v", ing.Namespace, ing.Name)
+}
+
+// ingressByKey sorts Ingress rules by namespace and name
+type ingressByKey []*extensions.Ingress
+
+func (c ingressByKey) Len() int      { return len(c) }
+func (c ingressByKey) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
+func (c ingressByKey) Less(i, j int) bool {
+	return ingressKey(c[i]) < ingressKey(c[j])
+}
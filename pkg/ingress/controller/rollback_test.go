@@ -0,0 +1,97 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/apis/extensions"
+	"k8s.io/kubernetes/pkg/client/record"
+
+	"github.com/aledbf/ingress-controller/pkg/ingress"
+)
+
+func newIngress(name, version string) *extensions.Ingress {
+	return &extensions.Ingress{
+		ObjectMeta: api.ObjectMeta{
+			Name:            name,
+			Namespace:       api.NamespaceDefault,
+			ResourceVersion: version,
+		},
+	}
+}
+
+func TestLastKnownGoodChanges(t *testing.T) {
+	lkg := newLastKnownGood()
+	lkg.failure = "previous"
+	lkg.update([]byte("data"), []interface{}{
+		newIngress("foo", "1"),
+		newIngress("bar", "1"),
+		newIngress("deleted", "1"),
+	}, &api.ConfigMap{}, &ingress.Configuration{})
+
+	if lkg.failure != "" {
+		t.Errorf("expected the last failure to be reset")
+	}
+
+	changed, unchanged := lkg.changedIngresses([]interface{}{
+		newIngress("foo", "1"),
+		newIngress("bar", "2"),
+		newIngress("new", "1"),
+	})
+
+	if len(changed) != 2 {
+		t.Fatalf("expected two changed Ingress rules but returned %v", len(changed))
+	}
+	if changed[0].Name != "bar" || changed[1].Name != "new" {
+		t.Errorf("unexpected changed Ingress rules %v and %v", changed[0].Name, changed[1].Name)
+	}
+
+	if len(unchanged) != 2 {
+		t.Fatalf("expected two unchanged Ingress rules but returned %v", len(unchanged))
+	}
+	for _, ingIf := range unchanged {
+		ing := ingIf.(*extensions.Ingress)
+		if ing.ResourceVersion != "1" {
+			t.Errorf("expected the last known good version of %v but returned %v", ing.Name, ing.ResourceVersion)
+		}
+	}
+}
+
+func TestQuietCopy(t *testing.T) {
+	ing := newIngress("invalid", "1")
+	ing.Annotations = map[string]string{"ingress.kubernetes.io/ssl-protocols": "TLSv9"}
+	ing.Spec.Rules = []extensions.IngressRule{{Host: "foo.bar"}}
+
+	recorder := record.NewFakeRecorder(10)
+	ic := &GenericController{recorder: recorder}
+	servers := map[string]*ingress.Server{"foo.bar": {Name: "foo.bar"}}
+
+	ic.quietCopy().configureTLSSettings([]interface{}{ing}, servers)
+	if len(recorder.Events) != 0 {
+		t.Errorf("expected no events building a candidate configuration but %v returned", len(recorder.Events))
+	}
+	if ic.quiet {
+		t.Errorf("expected the controller to emit events after building a candidate configuration")
+	}
+
+	ic.configureTLSSettings([]interface{}{ing}, servers)
+	if len(recorder.Events) != 1 {
+		t.Errorf("expected 1 event but %v returned", len(recorder.Events))
+	}
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/apis/extensions"
+
+	"github.com/aledbf/ingress-controller/pkg/ingress"
+)
+
+const (
+	// interval between checks of the expiration of the SSL certificates
+	sslExpirationCheckInterval = 10 * time.Minute
+	// minimum time between warnings about the same certificate in an Ingress rule
+	sslWarningInterval = 24 * time.Hour
+)
+
+// checkSSLExpiration updates the time until the expiration of the SSL
+// certificates used in the Ingress rules and emits a Warning event in
+// the rules with a certificate expired or about to expire
+func (ic *GenericController) checkSSLExpiration() {
+	now := time.Now()
+	resetSSLExpireTime()
+
+	for key, last := range ic.sslWarnings {
+		if now.Sub(last) >= sslWarningInterval {
+			delete(ic.sslWarnings, key)
+		}
+	}
+
+	for _, ingIf := range ic.ingLister.Store.List() {
+		ing := ingIf.(*extensions.Ingress)
+		if !IsValidClass(ing, ic.cfg.IngressClass) {
+			continue
+		}
+
+		for _, tls := range ing.Spec.TLS {
+			key := fmt.Sprintf("%v/%v", ing.Namespace, tls.SecretName)
+			bc, exists := ic.sslCertTracker.Get(key)
+			if !exists {
+				continue
+			}
+			cert := bc.(*ingress.SSLCert)
+			if cert.NotAfter.IsZero() {
+				continue
+			}
+
+			remaining := cert.NotAfter.Sub(now)
+			for _, host := range tls.Hosts {
+				setSSLExpireTime(ing.Namespace, tls.SecretName, host, remaining)
+			}
+
+			ic.sslExpirationWarning(ing, key, cert, remaining, now)
+		}
+	}
+}
+
+// sslExpirationWarning emits a Warning event in an Ingress rule if the
+// certificate is expired or expires before the warning period. The event
+// is emitted again after sslWarningInterval
+func (ic *GenericController) sslExpirationWarning(ing *extensions.Ingress, secret string,
+	cert *ingress.SSLCert, remaining time.Duration, now time.Time) {
+
+	var msg string
+	switch {
+	case remaining <= 0:
+		msg = fmt.Sprintf("the SSL certificate in secret %v (serial number %v) expired at %v",
+			secret, cert.SerialNumber, cert.NotAfter.UTC())
+	case remaining < ic.cfg.SSLExpirationWarning:
+		msg = fmt.Sprintf("the SSL certificate in secret %v (serial number %v) expires at %v",
+			secret, cert.SerialNumber, cert.NotAfter.UTC())
+	default:
+		return
+	}
+
+	key := fmt.Sprintf("%v/%v/%v/%v", ing.Namespace, ing.Name, cert.PemSHA, remaining <= 0)
+	if last, ok := ic.sslWarnings[key]; ok && now.Sub(last) < sslWarningInterval {
+		return
+	}
+	ic.sslWarnings[key] = now
+
+	glog.Warningf("Ingress %v/%v: %v", ing.Namespace, ing.Name, msg)
+	ic.recorder.Event(ing, api.EventTypeWarning, "SSL", msg)
+}
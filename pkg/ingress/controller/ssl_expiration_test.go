@@ -0,0 +1,102 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/kubernetes/pkg/apis/extensions"
+	"k8s.io/kubernetes/pkg/client/cache"
+	"k8s.io/kubernetes/pkg/client/record"
+
+	cache_store "github.com/aledbf/ingress-controller/pkg/cache"
+	"github.com/aledbf/ingress-controller/pkg/ingress"
+)
+
+func newSSLExpirationController(certs map[string]*ingress.SSLCert, ings ...*extensions.Ingress) (*GenericController, *record.FakeRecorder) {
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for _, ing := range ings {
+		store.Add(ing)
+	}
+
+	tracker := newSSLCertTracker()
+	for key, cert := range certs {
+		tracker.Add(key, cert)
+	}
+
+	recorder := record.NewFakeRecorder(10)
+	return &GenericController{
+		cfg:            &Configuration{SSLExpirationWarning: 7 * 24 * time.Hour},
+		ingLister:      cache_store.StoreToIngressLister{Store: store},
+		sslCertTracker: tracker,
+		recorder:       recorder,
+		sslWarnings:    map[string]time.Time{},
+	}, recorder
+}
+
+func newTLSIngress(name, secret string, hosts ...string) *extensions.Ingress {
+	ing := newIngress(name, "1")
+	ing.Spec.TLS = []extensions.IngressTLS{{Hosts: hosts, SecretName: secret}}
+	return ing
+}
+
+func TestCheckSSLExpiration(t *testing.T) {
+	now := time.Now()
+	certs := map[string]*ingress.SSLCert{
+		"default/valid":    {PemSHA: "1", NotAfter: now.Add(90 * 24 * time.Hour)},
+		"default/expiring": {PemSHA: "2", NotAfter: now.Add(24 * time.Hour)},
+		"default/expired":  {PemSHA: "3", NotAfter: now.Add(-time.Hour)},
+	}
+
+	ic, recorder := newSSLExpirationController(certs,
+		newTLSIngress("valid", "valid", "valid.bar"),
+		newTLSIngress("expiring", "expiring", "expiring.bar", "www.expiring.bar"),
+		newTLSIngress("expired", "expired", "expired.bar"),
+		newTLSIngress("missing", "missing", "missing.bar"),
+	)
+
+	ic.checkSSLExpiration()
+	if len(recorder.Events) != 2 {
+		t.Fatalf("expected 2 events but returned %v", len(recorder.Events))
+	}
+
+	v := metricValue(t, sslExpireTime.WithLabelValues("default", "expired", "expired.bar"))
+	if v >= 0 {
+		t.Errorf("expected a negative time until the expiration but returned %v", v)
+	}
+	v = metricValue(t, sslExpireTime.WithLabelValues("default", "expiring", "www.expiring.bar"))
+	if v <= 0 || v > (24*time.Hour).Seconds() {
+		t.Errorf("expected a time until the expiration lower than one day but returned %v", v)
+	}
+
+	// the warnings are not repeated
+	for len(recorder.Events) > 0 {
+		<-recorder.Events
+	}
+	ic.checkSSLExpiration()
+	if len(recorder.Events) != 0 {
+		t.Errorf("expected no events but returned %v", len(recorder.Events))
+	}
+
+	// a new certificate emits a new warning
+	certs["default/expiring"].PemSHA = "4"
+	ic.checkSSLExpiration()
+	if len(recorder.Events) != 1 {
+		t.Errorf("expected 1 event but returned %v", len(recorder.Events))
+	}
+}
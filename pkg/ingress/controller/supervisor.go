@@ -0,0 +1,127 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/glog"
+
+	"k8s.io/kubernetes/pkg/util/wait"
+)
+
+const (
+	// minimum and maximum time to wait before starting the backend
+	// process again after an exit
+	minRestartBackoff = 1 * time.Second
+	maxRestartBackoff = 1 * time.Minute
+	// the backoff is reset if the backend process runs at least this long
+	backoffResetPeriod = 5 * time.Minute
+	// interval used to check if the backend exited during the shutdown
+	backendExitInterval = 500 * time.Millisecond
+)
+
+// processStatus keeps the state of the backend process
+type processStatus struct {
+	running int32
+	// configured indicates a configuration from the Ingress rules
+	// was successfully applied in the backend
+	configured int32
+	// shuttingDown indicates the controller is being stopped
+	shuttingDown int32
+}
+
+func (p *processStatus) setRunning(running bool) {
+	var v int32
+	if running {
+		v = 1
+	}
+	atomic.StoreInt32(&p.running, v)
+	setBackendUp(running)
+}
+
+func (p *processStatus) isRunning() bool {
+	return atomic.LoadInt32(&p.running) == 1
+}
+
+func (p *processStatus) setConfigured() {
+	atomic.StoreInt32(&p.configured, 1)
+}
+
+func (p *processStatus) isConfigured() bool {
+	return atomic.LoadInt32(&p.configured) == 1
+}
+
+func (p *processStatus) setShuttingDown() {
+	atomic.StoreInt32(&p.shuttingDown, 1)
+}
+
+func (p *processStatus) isShuttingDown() bool {
+	return atomic.LoadInt32(&p.shuttingDown) == 1
+}
+
+// waitBackendExit waits until the backend process is not running or
+// returns an error if the timeout expires
+func (ic GenericController) waitBackendExit(timeout time.Duration) error {
+	err := wait.PollImmediate(backendExitInterval, timeout, func() (bool, error) {
+		return !ic.backendStatus.isRunning(), nil
+	})
+	if err != nil {
+		return fmt.Errorf("the backend process is still running after %v", timeout)
+	}
+
+	glog.Infof("the backend process exited")
+	return nil
+}
+
+// superviseBackend starts the backend process and starts it again,
+// using an exponential backoff, each time the process exits until the
+// controller is stopped
+func (ic GenericController) superviseBackend() {
+	backoff := minRestartBackoff
+	for {
+		started := time.Now()
+		ic.backendStatus.setRunning(true)
+		err := ic.cfg.Backend.Start()
+		ic.backendStatus.setRunning(false)
+
+		select {
+		case <-ic.stopCh:
+			return
+		default:
+		}
+
+		if time.Since(started) > backoffResetPeriod {
+			backoff = minRestartBackoff
+		}
+
+		glog.Errorf("the backend process exited: %v. Starting it again in %v", err, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ic.stopCh:
+			return
+		}
+
+		incBackendRestartCount()
+		backoff *= 2
+		if backoff > maxRestartBackoff {
+			backoff = maxRestartBackoff
+		}
+	}
+}
@@ -0,0 +1,193 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"os/exec"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/kubernetes/pkg/api"
+
+	"github.com/aledbf/ingress-controller/pkg/ingress"
+	"github.com/aledbf/ingress-controller/pkg/ingress/defaults"
+	"github.com/aledbf/ingress-controller/pkg/task"
+)
+
+// fakeBackend is a backend where the process exits after each start
+type fakeBackend struct {
+	starts chan int
+	count  int
+	health error
+	onStop func()
+}
+
+func (f *fakeBackend) HealthCheck() error { return f.health }
+
+func (f *fakeBackend) Start() error {
+	f.count++
+	f.starts <- f.count
+	return fmt.Errorf("exited")
+}
+func (f *fakeBackend) Stop() error {
+	if f.onStop != nil {
+		f.onStop()
+	}
+	return nil
+}
+func (f *fakeBackend) Restart(data []byte) ([]byte, error) { return nil, nil }
+func (f *fakeBackend) Test(file string) *exec.Cmd          { return nil }
+func (f *fakeBackend) UpstreamDefaults() defaults.Backend  { return defaults.Backend{} }
+func (f *fakeBackend) Info() string                        { return "fake" }
+func (f *fakeBackend) IsReloadRequired(*ingress.ConfigurationChanges) bool {
+	return false
+}
+func (f *fakeBackend) OnUpdate(*api.ConfigMap, ingress.Configuration) ([]byte, error) {
+	return nil, nil
+}
+
+func TestSuperviseBackend(t *testing.T) {
+	backend := &fakeBackend{starts: make(chan int, 10)}
+	ic := GenericController{
+		cfg:           &Configuration{Backend: backend},
+		stopCh:        make(chan struct{}),
+		backendStatus: &processStatus{},
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ic.superviseBackend()
+		close(done)
+	}()
+
+	for i := 1; i <= 2; i++ {
+		select {
+		case n := <-backend.starts:
+			if n != i {
+				t.Errorf("expected start %v but returned %v", i, n)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected the backend to be started again")
+		}
+	}
+
+	close(ic.stopCh)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the supervisor to exit after the controller is stopped")
+	}
+
+	if ic.backendStatus.isRunning() {
+		t.Errorf("expected the backend to not be running")
+	}
+}
+
+func TestReady(t *testing.T) {
+	backend := &fakeBackend{}
+	ic := GenericController{
+		cfg:           &Configuration{Backend: backend},
+		backendStatus: &processStatus{},
+	}
+
+	if err := ic.Ready(nil); err == nil {
+		t.Errorf("expected error without a configuration")
+	}
+
+	ic.backendStatus.setConfigured()
+	if err := ic.Ready(nil); err == nil {
+		t.Errorf("expected error without a running backend")
+	}
+
+	ic.backendStatus.setRunning(true)
+	if err := ic.Ready(nil); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	backend.health = fmt.Errorf("unhealthy")
+	if err := ic.Ready(nil); err != backend.health {
+		t.Errorf("expected error %v but returned %v", backend.health, err)
+	}
+	if err := ic.Check(nil); err != backend.health {
+		t.Errorf("expected error %v but returned %v", backend.health, err)
+	}
+}
+
+// fakeSync records the shutdown of the status sync
+type fakeSync struct {
+	shutdown bool
+}
+
+func (f *fakeSync) Run(stopCh <-chan struct{}) {}
+func (f *fakeSync) Shutdown()                  { f.shutdown = true }
+func (f *fakeSync) IsLeader() bool             { return true }
+
+func newStopController(backend *fakeBackend, st *fakeSync) GenericController {
+	return GenericController{
+		cfg: &Configuration{
+			Backend:             backend,
+			ShutdownGracePeriod: 10 * time.Millisecond,
+			ShutdownTimeout:     time.Second,
+		},
+		stopLock:      &sync.Mutex{},
+		stopCh:        make(chan struct{}),
+		syncStatus:    st,
+		syncQueue:     task.NewTaskQueue(func(interface{}) error { return nil }),
+		secretQueue:   task.NewTaskQueue(func(interface{}) error { return nil }),
+		backendStatus: &processStatus{},
+	}
+}
+
+func TestStop(t *testing.T) {
+	backend := &fakeBackend{}
+	st := &fakeSync{}
+	ic := newStopController(backend, st)
+	ic.backendStatus.setConfigured()
+	ic.backendStatus.setRunning(true)
+	backend.onStop = func() {
+		if !st.shutdown {
+			t.Errorf("expected the status to be updated before stopping the backend")
+		}
+		select {
+		case <-ic.stopCh:
+		default:
+			t.Errorf("expected the controller to be stopped before the backend")
+		}
+		go ic.backendStatus.setRunning(false)
+	}
+
+	if err := ic.Stop(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := ic.Ready(nil); err == nil {
+		t.Errorf("expected the controller to be not ready after the shutdown")
+	}
+	if err := ic.Stop(); err == nil {
+		t.Errorf("expected error stopping the controller twice")
+	}
+}
+
+func TestStopTimeout(t *testing.T) {
+	ic := newStopController(&fakeBackend{}, &fakeSync{})
+	ic.backendStatus.setRunning(true)
+
+	if err := ic.Stop(); err == nil {
+		t.Errorf("expected error with a backend process still running")
+	}
+}
@@ -17,10 +17,9 @@ limitations under the License.
 package controller
 
 import (
-	"strings"
-
 	"github.com/aledbf/ingress-controller/pkg/ingress"
 	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/parser"
+	"github.com/aledbf/ingress-controller/pkg/net/ssl"
 
 	"k8s.io/kubernetes/pkg/apis/extensions"
 )
@@ -30,6 +29,12 @@ func newDefaultServer() ingress.UpstreamServer {
 	return ingress.UpstreamServer{Address: "127.0.0.1", Port: "8181"}
 }
 
+// newBuiltinDefaultServer returns an UpstreamServer that points to the
+// built-in default backend
+func newBuiltinDefaultServer() ingress.UpstreamServer {
+	return ingress.UpstreamServer{Address: "127.0.0.1", Port: builtinBackendPort}
+}
+
 // newUpstream creates an upstream without servers.
 func newUpstream(name string) *ingress.Upstream {
 	return &ingress.Upstream{
@@ -42,40 +47,7 @@ func isHostValid(host string, cert *ingress.SSLCert) bool {
 	if cert == nil {
 		return false
 	}
-	for _, cn := range cert.CN {
-		if matchHostnames(cn, host) {
-			return true
-		}
-	}
-
-	return false
-}
-
-func matchHostnames(pattern, host string) bool {
-	host = strings.TrimSuffix(host, ".")
-	pattern = strings.TrimSuffix(pattern, ".")
-
-	if len(pattern) == 0 || len(host) == 0 {
-		return false
-	}
-
-	patternParts := strings.Split(pattern, ".")
-	hostParts := strings.Split(host, ".")
-
-	if len(patternParts) != len(hostParts) {
-		return false
-	}
-
-	for i, patternPart := range patternParts {
-		if i == 0 && patternPart == "*" {
-			continue
-		}
-		if patternPart != hostParts[i] {
-			return false
-		}
-	}
-
-	return true
+	return ssl.IsValidHostname(host, cert.CN)
 }
 
 // IsValidClass returns true if the given Ingress either doesn't specify
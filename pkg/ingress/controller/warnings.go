@@ -0,0 +1,61 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/apis/extensions"
+)
+
+const (
+	// minimum time between equal warnings about the configuration of an Ingress rule
+	configurationWarningInterval = time.Hour
+)
+
+// configurationWarning emits a Warning event in an Ingress rule. The sync
+// of the configuration runs for every change in the cluster, so the same
+// warning is emitted again only after configurationWarningInterval.
+// The copies of the controller used to build candidate configurations
+// do not emit warnings
+func (ic *GenericController) configurationWarning(ing *extensions.Ingress, reason, format string, args ...interface{}) {
+	if ic.quiet {
+		return
+	}
+
+	msg := fmt.Sprintf(format, args...)
+	key := fmt.Sprintf("%v/%v/%v/%v", ing.Namespace, ing.Name, reason, msg)
+
+	now := time.Now()
+	if last, ok := ic.configurationWarnings[key]; ok && now.Sub(last) < configurationWarningInterval {
+		return
+	}
+
+	for k, last := range ic.configurationWarnings {
+		if now.Sub(last) >= configurationWarningInterval {
+			delete(ic.configurationWarnings, k)
+		}
+	}
+	ic.configurationWarnings[key] = now
+
+	glog.Warningf("Ingress %v/%v: %v", ing.Namespace, ing.Name, msg)
+	ic.recorder.Event(ing, api.EventTypeWarning, reason, msg)
+}
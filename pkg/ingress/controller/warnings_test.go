@@ -0,0 +1,66 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/kubernetes/pkg/client/record"
+)
+
+func TestConfigurationWarning(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	ic := &GenericController{
+		recorder:              recorder,
+		configurationWarnings: map[string]time.Time{},
+	}
+	ing := newIngress("foo", "1")
+
+	for i := 0; i < 3; i++ {
+		ic.configurationWarning(ing, "SECRET", "secret %v cannot be referenced from namespace %v", "other/ca", "default")
+	}
+	if len(recorder.Events) != 1 {
+		t.Fatalf("expected 1 event but returned %v", len(recorder.Events))
+	}
+
+	// a different warning is emitted
+	ic.configurationWarning(ing, "SECRET", "secret %v cannot be referenced from namespace %v", "other/cert", "default")
+	if len(recorder.Events) != 2 {
+		t.Errorf("expected 2 events but returned %v", len(recorder.Events))
+	}
+
+	// the warning is emitted again after the interval
+	for key := range ic.configurationWarnings {
+		ic.configurationWarnings[key] = time.Now().Add(-configurationWarningInterval)
+	}
+	ic.configurationWarning(ing, "SECRET", "secret %v cannot be referenced from namespace %v", "other/ca", "default")
+	if len(recorder.Events) != 3 {
+		t.Errorf("expected 3 events but returned %v", len(recorder.Events))
+	}
+	if len(ic.configurationWarnings) != 1 {
+		t.Errorf("expected the old warnings removed but returned %v", len(ic.configurationWarnings))
+	}
+
+	// the copies used to build candidate configurations do not emit
+	// warnings nor prevent the next one
+	quiet := ic.quietCopy()
+	quiet.configurationWarning(ing, "SSL", "invalid certificate")
+	if _, ok := ic.configurationWarnings["default/foo/SSL/invalid certificate"]; ok {
+		t.Errorf("expected no warning recorded by a quiet copy")
+	}
+}
@@ -42,6 +42,11 @@ type Backend struct {
 	// Enables or disables the redirect (301) to the HTTPS port
 	SSLRedirect bool `structs:"ssl-redirect"`
 
+	// Enables or disables the redirect (301) to the HTTPS port of the requests
+	// received using HTTP by a load balancer that terminates the SSL connections.
+	// The protocol is read from the header X-Forwarded-Proto
+	ForceSSLRedirect bool `structs:"force-ssl-redirect"`
+
 	// Number of unsuccessful attempts to communicate with the server that should happen in the
 	// duration set by the fail_timeout parameter to consider the server unavailable
 	// http://nginx.org/en/docs/http/ngx_http_upstream_module.html#upstream
@@ -45,6 +45,8 @@ const (
 type Sync interface {
 	Run(stopCh <-chan struct{})
 	Shutdown()
+	// IsLeader returns true if the instance is the leader of the election
+	IsLeader() bool
 }
 
 // Config ...
@@ -110,6 +112,11 @@ func (s statusSync) Shutdown() {
 	s.updateStatus([]api.LoadBalancerIngress{})
 }
 
+// IsLeader returns true if the instance is the current leader
+func (s statusSync) IsLeader() bool {
+	return s.elector.IsLeader()
+}
+
 func (s *statusSync) run() {
 	err := wait.PollInfinite(updateInterval, func() (bool, error) {
 		if s.syncQueue.IsShuttingDown() {
@@ -18,6 +18,7 @@ package ingress
 
 import (
 	"os/exec"
+	"time"
 
 	"k8s.io/kubernetes/pkg/api"
 	"k8s.io/kubernetes/pkg/api/unversioned"
@@ -25,10 +26,12 @@ import (
 	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/auth"
 	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/authreq"
 	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/authtls"
+	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/globalratelimit"
 	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/ipwhitelist"
 	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/proxy"
 	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/ratelimit"
 	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/rewrite"
+	"github.com/aledbf/ingress-controller/pkg/ingress/annotations/tlssettings"
 	"github.com/aledbf/ingress-controller/pkg/ingress/defaults"
 )
 
@@ -39,10 +42,12 @@ const (
 
 // Controller ...
 type Controller interface {
-	// Start returns the command is executed to start the backend.
-	// The command must run in foreground.
-	Start()
-	// Stop stops the backend
+	// Start starts the backend process in foreground and blocks until
+	// the process exits. The returned error describes why the process
+	// exited. The process is started again if the controller is running
+	Start() error
+	// Stop stops the backend. The backend should finish the in-flight
+	// requests before exiting (graceful shutdown)
 	Stop() error
 	// Restart reload the backend with the a configuration file returning
 	// the combined output of Stdout and Stderr
@@ -73,13 +78,30 @@ type Controller interface {
 	// communication to upstream servers (endpoints)
 	UpstreamDefaults() defaults.Backend
 	// IsReloadRequired checks if the backend must be reloaded or not.
-	// The parameter contains the new rendered template
-	IsReloadRequired([]byte) bool
+	// The parameter contains the differences between the configuration
+	// applied in the backend and the new one
+	IsReloadRequired(*ConfigurationChanges) bool
 	// Info returns information about the ingress controller
 	// This can include build version, repository, etc.
 	Info() string
 }
 
+// HealthChecker is an optional interface implemented by backends that
+// are able to check if the backend process is working as expected.
+// The result is included in the health check of the controller
+type HealthChecker interface {
+	// HealthCheck returns an error if the backend is not healthy
+	HealthCheck() error
+}
+
+// CertificateUpdater is an optional interface implemented by backends
+// that are able to update the SSL certificates of the servers without
+// a reload. It is invoked after each sync of the configuration
+type CertificateUpdater interface {
+	// UpdateCertificates sends the certificates of the servers to the backend
+	UpdateCertificates([]*Server) error
+}
+
 // Configuration describes
 type Configuration struct {
 	HealthzURL           string
@@ -88,6 +110,13 @@ type Configuration struct {
 	TCPUpstreams         []*Location
 	UDPUpstreams         []*Location
 	PassthroughUpstreams []*SSLPassthroughUpstreams
+	// DHParam contains the Diffie-Hellman parameters used in the
+	// DHE ciphers. nil means the backend uses its defaults
+	DHParam *SSLFile
+	// SessionTicketKeys contains the keys used to encrypt and decrypt
+	// TLS session tickets. The first key encrypts new tickets and
+	// the rest only decrypt tickets created before a rotation
+	SessionTicketKeys []*SSLFile
 }
 
 // Upstream describes an upstream server (endpoint)
@@ -138,7 +167,17 @@ type Server struct {
 	SSLCertificate string
 	//SSLCertificateKey string
 	SSLPemChecksum string
-	Locations      []*Location
+	// SSLCertificateECDSA contains an ECDSA certificate used in addition
+	// to the RSA certificate in SSLCertificate
+	SSLCertificateECDSA string
+	SSLPemChecksumECDSA string
+	// SSLStaplingFile contains the OCSP response of the certificate
+	// stapled in the TLS handshake
+	SSLStaplingFile     string
+	SSLStaplingChecksum string
+	// TLSSettings overrides the global TLS settings of the backend
+	TLSSettings tlssettings.Config
+	Locations   []*Location
 }
 
 // Location describes a server location
@@ -150,11 +189,24 @@ type Location struct {
 	Upstream        Upstream
 	BasicDigestAuth auth.BasicDigest
 	RateLimit       ratelimit.RateLimit
+	GlobalRateLimit globalratelimit.Config
 	Redirect        rewrite.Redirect
 	Whitelist       ipwhitelist.SourceRange
 	ExternalAuth    authreq.External
 	Proxy           proxy.Configuration
 	CertificateAuth authtls.SSLCert
+	// DefaultBackend is the name of the upstream that serves the custom
+	// error pages of the location. Empty means the global default backend
+	DefaultBackend string
+	// CustomHTTPErrors contains the HTTP codes that should be passed for
+	// processing with the error_page directive in the location.
+	// Empty means the global configuration
+	CustomHTTPErrors []int
+	// Namespace, Ingress and Service identify the Ingress rule and the
+	// service of the location. Empty if the location uses the default backend
+	Namespace string
+	Ingress   string
+	Service   string
 }
 
 // UpstreamServerByAddrPort sorts upstream servers by address and port
@@ -208,6 +260,25 @@ type SSLCert struct {
 	PemSHA string
 	// CN contains all the common names defined in the SSL certificate
 	CN []string
+	// NotBefore and NotAfter define the validity period of the certificate
+	NotBefore time.Time
+	NotAfter  time.Time
+	// Issuer contains the distinguished name of the issuer of the certificate
+	Issuer string
+	// SerialNumber contains the serial number of the certificate
+	SerialNumber string
+	// KeyType contains the type of the key of the certificate (rsa or ecdsa)
+	KeyType string
+}
+
+// SSLFile describes a file created from the content of a Secret
+// used in the SSL configuration (not a certificate)
+type SSLFile struct {
+	// FileName contains the path to the file
+	FileName string
+	// Checksum contains the sha1 of the content of the file.
+	// This is used to detect changes in the secret
+	Checksum string
 }
 
 // GetObjectKind implements the ObjectKind interface as a noop
@@ -0,0 +1,180 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package ocsp implements a minimal OCSP (RFC 6960) client used to
+// obtain the responses stapled in the TLS handshake
+package ocsp
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// Status of the certificate in an OCSP response
+const (
+	Good    = ocsp.Good
+	Revoked = ocsp.Revoked
+	Unknown = ocsp.Unknown
+)
+
+const (
+	// maximum size of a response accepted from a responder
+	maxResponseSize = 1024 * 1024
+	// tolerance between the clock of the responder and the local clock
+	maxClockSkew = 5 * time.Minute
+	// interval between requests of the responses without NextUpdate
+	refreshWindow = 12 * time.Hour
+)
+
+var (
+	// ErrNoOCSPServer is returned when the certificate does not contain
+	// the URL of an OCSP responder
+	ErrNoOCSPServer = errors.New("the certificate does not contain an OCSP server")
+)
+
+// Response is the status of a certificate returned by an OCSP responder.
+// NextUpdate is zero when the responder does not define when newer
+// information will be available
+type Response struct {
+	*ocsp.Response
+	// Raw contains the DER encoded response sent to the clients
+	Raw []byte
+}
+
+// ParseResponse parses a DER encoded response checking it contains
+// the status of the certificate and is signed by the issuer or by a
+// responder authorized by the issuer
+func ParseResponse(der []byte, cert, issuer *x509.Certificate) (*Response, error) {
+	resp, err := ocsp.ParseResponse(der, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OCSP response: %v", err)
+	}
+
+	err = checkSigner(resp, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.SerialNumber == nil || resp.SerialNumber.Cmp(cert.SerialNumber) != 0 {
+		return nil, fmt.Errorf("the OCSP response does not contain the status of the certificate %v", cert.SerialNumber)
+	}
+
+	return &Response{Response: resp, Raw: der}, nil
+}
+
+// checkSigner verifies the response is signed by the issuer or by a
+// responder with a certificate signed by the issuer. ocsp.ParseResponse
+// only checks the signature of the response with the certificate
+// included in the response
+func checkSigner(resp *ocsp.Response, issuer *x509.Certificate) error {
+	responder := resp.Certificate
+	if responder == nil || bytes.Equal(responder.Raw, issuer.Raw) {
+		err := resp.CheckSignatureFrom(issuer)
+		if err != nil {
+			return fmt.Errorf("invalid signature of the OCSP response: %v", err)
+		}
+		return nil
+	}
+
+	err := responder.CheckSignatureFrom(issuer)
+	if err != nil {
+		return fmt.Errorf("the certificate of the OCSP responder is not signed by the issuer: %v", err)
+	}
+	if !hasOCSPSigning(responder) {
+		return fmt.Errorf("the certificate of the OCSP responder is not valid for OCSP signing")
+	}
+	return nil
+}
+
+func hasOCSPSigning(cert *x509.Certificate) bool {
+	for _, usage := range cert.ExtKeyUsage {
+		if usage == x509.ExtKeyUsageOCSPSigning {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValid returns true if the response contains current information.
+// Responses without NextUpdate are valid until a newer response is obtained
+func (r *Response) IsValid(now time.Time) bool {
+	if r.ThisUpdate.After(now.Add(maxClockSkew)) {
+		return false
+	}
+	return r.NextUpdate.IsZero() || now.Before(r.NextUpdate)
+}
+
+// RefreshTime returns when a new response should be requested, the
+// middle of the validity period of the response or a fixed interval
+// after ThisUpdate when the response does not contain NextUpdate
+func (r *Response) RefreshTime() time.Time {
+	if r.NextUpdate.IsZero() {
+		return r.ThisUpdate.Add(refreshWindow)
+	}
+	return r.ThisUpdate.Add(r.NextUpdate.Sub(r.ThisUpdate) / 2)
+}
+
+// Client obtains OCSP responses from the responder defined in the certificates
+type Client struct {
+	// HTTPClient is the client used in the requests to the OCSP responder
+	HTTPClient *http.Client
+}
+
+// Fetch requests the status of a certificate to the first
+// OCSP server defined in the certificate
+func (c *Client) Fetch(cert, issuer *x509.Certificate) (*Response, error) {
+	if len(cert.OCSPServer) == 0 {
+		return nil, ErrNoOCSPServer
+	}
+
+	req, err := ocsp.CreateRequest(cert, issuer, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+
+	url := cert.OCSPServer[0]
+	resp, err := hc.Post(url, "application/ocsp-request", bytes.NewReader(req))
+	if err != nil {
+		return nil, fmt.Errorf("error requesting OCSP response to %v: %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxResponseSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("error reading OCSP response from %v: %v", url, err)
+	}
+	if len(body) > maxResponseSize {
+		return nil, fmt.Errorf("the OCSP response from %v exceeds %v bytes", url, maxResponseSize)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %v from the OCSP responder %v", resp.StatusCode, url)
+	}
+
+	return ParseResponse(body, cert, issuer)
+}
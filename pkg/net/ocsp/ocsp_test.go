@@ -0,0 +1,234 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ocsp
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+func newCertificate(t *testing.T, tmpl *x509.Certificate, parent *x509.Certificate, parentKey crypto.Signer) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error generating key: %v", err)
+	}
+	if parent == nil {
+		parent = tmpl
+		parentKey = key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, key.Public(), parentKey)
+	if err != nil {
+		t.Fatalf("unexpected error creating certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("unexpected error parsing certificate: %v", err)
+	}
+	return cert, key
+}
+
+// responder is a local OCSP responder
+type responder struct {
+	t      *testing.T
+	issuer *x509.Certificate
+	signer *x509.Certificate
+	key    crypto.Signer
+	status int
+	// errResp is returned instead of the status of the certificate
+	errResp []byte
+	now     time.Time
+}
+
+func (r *responder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Header.Get("Content-Type") != "application/ocsp-request" {
+		r.t.Errorf("unexpected content type %v", req.Header.Get("Content-Type"))
+	}
+	body, _ := ioutil.ReadAll(req.Body)
+	ocspReq, err := ocsp.ParseRequest(body)
+	if err != nil {
+		r.t.Errorf("invalid OCSP request: %v", err)
+		return
+	}
+	if r.errResp != nil {
+		w.Write(r.errResp)
+		return
+	}
+
+	tmpl := ocsp.Response{
+		Status:       r.status,
+		SerialNumber: ocspReq.SerialNumber,
+		ThisUpdate:   r.now,
+		NextUpdate:   r.now.Add(4 * 24 * time.Hour),
+		RevokedAt:    r.now.Add(-time.Hour),
+	}
+	if r.signer != r.issuer {
+		tmpl.Certificate = r.signer
+	}
+	der, err := ocsp.CreateResponse(r.issuer, r.signer, tmpl, r.key)
+	if err != nil {
+		r.t.Fatalf("unexpected error creating OCSP response: %v", err)
+	}
+	w.Write(der)
+}
+
+func TestFetch(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+
+	ca, caKey := newCertificate(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, nil)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error generating key: %v", err)
+	}
+	delegated, delegatedKey := newCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "OCSP responder"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning},
+	}, ca, caKey)
+	notDelegated, notDelegatedKey := newCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(4),
+		Subject:      pkix.Name{CommonName: "server"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca, caKey)
+
+	r := &responder{t: t, issuer: ca, now: now}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	cert, _ := newCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(10),
+		Subject:      pkix.Name{CommonName: "foo.bar"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+		OCSPServer:   []string{server.URL},
+	}, ca, caKey)
+
+	tests := map[string]struct {
+		signer   *x509.Certificate
+		key      crypto.Signer
+		status   int
+		errResp  []byte
+		expected int
+		err      bool
+	}{
+		"good":                     {ca, caKey, Good, nil, Good, false},
+		"revoked":                  {ca, caKey, Revoked, nil, Revoked, false},
+		"unknown":                  {ca, caKey, Unknown, nil, Unknown, false},
+		"delegated responder":      {delegated, delegatedKey, Good, nil, Good, false},
+		"responder not authorized": {notDelegated, notDelegatedKey, Good, nil, Good, true},
+		"invalid signature":        {ca, otherKey, Good, nil, Good, true},
+		"responder error":          {ca, caKey, Good, ocsp.TryLaterErrorResponse, Good, true},
+	}
+
+	client := &Client{HTTPClient: &http.Client{}}
+	for title, tc := range tests {
+		r.signer, r.key, r.status, r.errResp = tc.signer, tc.key, tc.status, tc.errResp
+
+		resp, err := client.Fetch(cert, ca)
+		if tc.err {
+			if err == nil {
+				t.Errorf("%v: expected an error but none returned", title)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", title, err)
+			continue
+		}
+		if resp.Status != tc.expected {
+			t.Errorf("%v: expected status %v but returned %v", title, tc.expected, resp.Status)
+		}
+		if resp.SerialNumber.Cmp(cert.SerialNumber) != 0 {
+			t.Errorf("%v: expected serial number %v but returned %v", title, cert.SerialNumber, resp.SerialNumber)
+		}
+		if !resp.ThisUpdate.Equal(now) || !resp.NextUpdate.Equal(now.Add(4*24*time.Hour)) {
+			t.Errorf("%v: unexpected validity %v - %v", title, resp.ThisUpdate, resp.NextUpdate)
+		}
+		if len(resp.Raw) == 0 {
+			t.Errorf("%v: expected the DER encoded response", title)
+		}
+	}
+
+	// the response of a different certificate is rejected
+	r.signer, r.key, r.status, r.errResp = ca, caKey, Good, nil
+	resp, err := client.Fetch(cert, ca)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	otherCert, _ := newCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(11),
+		Subject:      pkix.Name{CommonName: "bar.baz"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+	}, ca, caKey)
+	if _, err := ParseResponse(resp.Raw, otherCert, ca); err == nil {
+		t.Errorf("expected an error parsing the response of a different certificate")
+	}
+
+	if _, err := client.Fetch(otherCert, ca); err != ErrNoOCSPServer {
+		t.Errorf("expected %v but returned %v", ErrNoOCSPServer, err)
+	}
+}
+
+func TestResponseValidity(t *testing.T) {
+	now := time.Now()
+	r := &Response{Response: &ocsp.Response{ThisUpdate: now.Add(-time.Hour), NextUpdate: now.Add(3 * time.Hour)}}
+	if !r.IsValid(now) {
+		t.Errorf("expected a valid response")
+	}
+	if r.IsValid(now.Add(4 * time.Hour)) {
+		t.Errorf("expected an expired response")
+	}
+	if !r.RefreshTime().Equal(now.Add(time.Hour)) {
+		t.Errorf("expected refresh at %v but returned %v", now.Add(time.Hour), r.RefreshTime())
+	}
+
+	r = &Response{Response: &ocsp.Response{ThisUpdate: now.Add(time.Hour)}}
+	if r.IsValid(now) {
+		t.Errorf("expected an invalid response produced in the future")
+	}
+	r = &Response{Response: &ocsp.Response{ThisUpdate: now.Add(-time.Hour)}}
+	if !r.IsValid(now.Add(24 * time.Hour)) {
+		t.Errorf("expected a valid response without next update")
+	}
+	if !r.RefreshTime().Equal(r.ThisUpdate.Add(refreshWindow)) {
+		t.Errorf("expected refresh at %v but returned %v", r.ThisUpdate.Add(refreshWindow), r.RefreshTime())
+	}
+}
@@ -17,13 +17,24 @@ limitations under the License.
 package ssl
 
 import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
 	"crypto/sha1"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/hex"
 	"encoding/pem"
 	"fmt"
 	"io/ioutil"
+	"math/big"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/golang/glog"
 
@@ -108,40 +119,252 @@ func AddOrUpdateCertAndKey(name string, cert, key, ca []byte) (*ingress.SSLCert,
 		}
 		f.Write([]byte("\n"))
 
-		return &ingress.SSLCert{
-			CAFileName:  caFileName,
-			PemFileName: pemFileName,
-			PemSHA:      pemSHA1(pemFileName),
-			CN:          cn,
-		}, nil
+		s := newSSLCert(pemCert, pemFileName, cn)
+		s.CAFileName = caFileName
+		return s, nil
 	}
 
+	return newSSLCert(pemCert, pemFileName, cn), nil
+}
+
+// newSSLCert returns a SSLCert with the information of the certificate
+func newSSLCert(cert *x509.Certificate, pemFileName string, cn []string) *ingress.SSLCert {
 	return &ingress.SSLCert{
-		PemFileName: pemFileName,
-		PemSHA:      pemSHA1(pemFileName),
-		CN:          cn,
-	}, nil
+		PemFileName:  pemFileName,
+		PemSHA:       pemSHA1(pemFileName),
+		CN:           cn,
+		NotBefore:    cert.NotBefore,
+		NotAfter:     cert.NotAfter,
+		Issuer:       issuerName(cert.Issuer),
+		SerialNumber: cert.SerialNumber.String(),
+		KeyType:      keyType(cert),
+	}
+}
+
+// keyType returns the type of the public key of a certificate
+func keyType(cert *x509.Certificate) string {
+	switch cert.PublicKeyAlgorithm {
+	case x509.RSA:
+		return KeyTypeRSA
+	case x509.ECDSA:
+		return KeyTypeECDSA
+	}
+	return ""
 }
 
-// SearchDHParamFile iterates all the secrets mounted inside the /etc/nginx-ssl directory
-// in order to find a file with the name dhparam.pem. If such file exists it will
-// returns the path. If not it just returns an empty string
-func SearchDHParamFile(baseDir string) string {
-	files, _ := ioutil.ReadDir(baseDir)
-	for _, file := range files {
-		if !file.IsDir() {
+// IsValidHostname returns true if one of the common names (wildcards
+// are allowed in the first label) matches the host
+func IsValidHostname(host string, commonNames []string) bool {
+	for _, cn := range commonNames {
+		if matchHostnames(cn, host) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchHostnames(pattern, host string) bool {
+	host = strings.TrimSuffix(host, ".")
+	pattern = strings.TrimSuffix(pattern, ".")
+
+	if len(pattern) == 0 || len(host) == 0 {
+		return false
+	}
+
+	patternParts := strings.Split(pattern, ".")
+	hostParts := strings.Split(host, ".")
+
+	if len(patternParts) != len(hostParts) {
+		return false
+	}
+
+	for i, patternPart := range patternParts {
+		if i == 0 && patternPart == "*" {
 			continue
 		}
+		if patternPart != hostParts[i] {
+			return false
+		}
+	}
+
+	return true
+}
 
-		dhPath := fmt.Sprintf("%v/%v/dhparam.pem", baseDir, file.Name())
-		if _, err := os.Stat(dhPath); err == nil {
-			glog.Infof("using file '%v' for parameter ssl_dhparam", dhPath)
-			return dhPath
+// ValidateDualCertificates checks a RSA and an ECDSA certificate can be
+// used together in the server of a host
+func ValidateDualCertificates(host string, rsaCert, ecdsaCert *ingress.SSLCert) error {
+	if rsaCert.KeyType != KeyTypeRSA {
+		return fmt.Errorf("the certificate %v does not contain a RSA key", rsaCert.PemFileName)
+	}
+	if ecdsaCert.KeyType != KeyTypeECDSA {
+		return fmt.Errorf("the certificate %v does not contain an ECDSA key", ecdsaCert.PemFileName)
+	}
+	for _, cert := range []*ingress.SSLCert{rsaCert, ecdsaCert} {
+		if !IsValidHostname(host, cert.CN) {
+			return fmt.Errorf("the %v certificate is not valid for the host %v", cert.KeyType, host)
 		}
 	}
+	return nil
+}
 
-	glog.Warning("no file dhparam.pem found in secrets")
-	return ""
+// issuerName returns the distinguished name of the issuer of a certificate
+// (ie CN=Example CA,O=Example)
+func issuerName(name pkix.Name) string {
+	var parts []string
+	if name.CommonName != "" {
+		parts = append(parts, fmt.Sprintf("CN=%v", name.CommonName))
+	}
+	for _, ou := range name.OrganizationalUnit {
+		parts = append(parts, fmt.Sprintf("OU=%v", ou))
+	}
+	for _, o := range name.Organization {
+		parts = append(parts, fmt.Sprintf("O=%v", o))
+	}
+	for _, c := range name.Country {
+		parts = append(parts, fmt.Sprintf("C=%v", c))
+	}
+	return strings.Join(parts, ",")
+}
+
+// AddOrUpdateDHParam creates a file with the Diffie-Hellman parameters
+// in PEM format (openssl dhparam). The content is checked because
+// NGINX does not start with invalid parameters
+func AddOrUpdateDHParam(name string, dh []byte) (*ingress.SSLFile, error) {
+	block, _ := pem.Decode(dh)
+	if block == nil || block.Type != "DH PARAMETERS" {
+		return nil, fmt.Errorf("no valid PEM formatted block with DH parameters found")
+	}
+
+	return writeSSLFile(fmt.Sprintf("dhparam-%v.pem", name), dh)
+}
+
+// AddOrUpdateSessionTicketKey creates a file with a key used to encrypt
+// and decrypt TLS session tickets. NGINX only accepts keys of 48
+// (AES128) or 80 (AES256) bytes (openssl rand 80)
+func AddOrUpdateSessionTicketKey(name string, key []byte) (*ingress.SSLFile, error) {
+	if len(key) != 48 && len(key) != 80 {
+		return nil, fmt.Errorf("invalid session ticket key size %v (expected 48 or 80 bytes)", len(key))
+	}
+
+	return writeSSLFile(fmt.Sprintf("ticket-%v.key", name), key)
+}
+
+// OCSPResponseFileName returns the path of the file with the OCSP
+// response of a certificate, next to the pem file of the certificate
+func OCSPResponseFileName(cert *ingress.SSLCert) string {
+	return fmt.Sprintf("%v.ocsp", strings.TrimSuffix(cert.PemFileName, ".pem"))
+}
+
+// AddOrUpdateOCSPResponse creates a file with the DER encoded OCSP
+// response of a certificate
+func AddOrUpdateOCSPResponse(cert *ingress.SSLCert, der []byte) (*ingress.SSLFile, error) {
+	return writeSSLFile(filepath.Base(OCSPResponseFileName(cert)), der)
+}
+
+// GetOCSPResponse returns the OCSP response of a certificate
+// stored in a previous execution and its content
+func GetOCSPResponse(cert *ingress.SSLCert) (*ingress.SSLFile, []byte, error) {
+	fileName := OCSPResponseFileName(cert)
+	der, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	checksum := sha1.Sum(der)
+	return &ingress.SSLFile{
+		FileName: fileName,
+		Checksum: hex.EncodeToString(checksum[:]),
+	}, der, nil
+}
+
+// GetCertificateAndIssuer returns the first certificate of the pem file
+// and the certificate that signed it, searched in the rest of the chain
+// and in the CA file of the secret
+func GetCertificateAndIssuer(cert *ingress.SSLCert) (*x509.Certificate, *x509.Certificate, error) {
+	certs, err := readCertificates(cert.PemFileName)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(certs) == 0 {
+		return nil, nil, fmt.Errorf("no certificate found in %v", cert.PemFileName)
+	}
+
+	candidates := certs[1:]
+	if cert.CAFileName != "" {
+		ca, err := readCertificates(cert.CAFileName)
+		if err != nil {
+			return nil, nil, err
+		}
+		candidates = append(candidates, ca...)
+	}
+
+	leaf := certs[0]
+	for _, c := range candidates {
+		if bytes.Equal(c.RawSubject, leaf.RawIssuer) && leaf.CheckSignatureFrom(c) == nil {
+			return leaf, c, nil
+		}
+	}
+	return leaf, nil, fmt.Errorf("the issuer of the certificate is not in the chain or the CA of the secret")
+}
+
+// readCertificates returns the certificates contained in a pem file
+func readCertificates(fileName string) ([]*x509.Certificate, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		c, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate in %v: %v", fileName, err)
+		}
+		certs = append(certs, c)
+	}
+	return certs, nil
+}
+
+// writeSSLFile writes a file in the SSL directory replacing the
+// previous content in a single step
+func writeSSLFile(name string, data []byte) (*ingress.SSLFile, error) {
+	fileName := fmt.Sprintf("%v/%v", ingress.DefaultSSLDirectory, name)
+
+	tempFile, err := ioutil.TempFile("", name)
+	if err != nil {
+		return nil, fmt.Errorf("could not create temp file for %v: %v", name, err)
+	}
+	_, err = tempFile.Write(data)
+	if err != nil {
+		tempFile.Close()
+		os.Remove(tempFile.Name())
+		return nil, fmt.Errorf("could not write to file %v: %v", tempFile.Name(), err)
+	}
+	err = tempFile.Close()
+	if err != nil {
+		os.Remove(tempFile.Name())
+		return nil, fmt.Errorf("could not close temp file %v: %v", tempFile.Name(), err)
+	}
+
+	err = os.Rename(tempFile.Name(), fileName)
+	if err != nil {
+		os.Remove(tempFile.Name())
+		return nil, fmt.Errorf("could not move temp file %v to destination %v: %v", tempFile.Name(), fileName, err)
+	}
+
+	checksum := sha1.Sum(data)
+	return &ingress.SSLFile{
+		FileName: fileName,
+		Checksum: hex.EncodeToString(checksum[:]),
+	}, nil
 }
 
 // pemSHA1 returns the SHA1 of a pem file. This is used to
@@ -158,22 +381,126 @@ func pemSHA1(filename string) string {
 }
 
 const (
-	snakeOilPem = "/etc/ssl/certs/ssl-cert-snakeoil.pem"
-	snakeOilKey = "/etc/ssl/private/ssl-cert-snakeoil.key"
+	// FakeCertificateName is the name of the self-signed certificate used
+	// when the default certificate is not configured
+	FakeCertificateName = "default-fake-certificate"
+	// FakeCertificateCN is the default common name of the fake certificate
+	FakeCertificateCN = "Kubernetes Ingress Controller Fake Certificate"
+
+	// KeyTypeRSA generates the fake certificate with a RSA 2048 key
+	KeyTypeRSA = "rsa"
+	// KeyTypeECDSA generates the fake certificate with a ECDSA P-256 key
+	KeyTypeECDSA = "ecdsa"
+
+	fakeCertificateValidity = 365 * 24 * time.Hour
+	// the fake certificate is generated again before the expiration
+	fakeCertificateRenewBefore = 30 * 24 * time.Hour
 )
 
-// GetFakeSSLCert returns the snake oil ssl certificate created by the command
-// make-ssl-cert generate-default-snakeoil --force-overwrite
-func GetFakeSSLCert() ([]byte, []byte) {
-	cert, err := ioutil.ReadFile(snakeOilPem)
+// GetFakeSSLCert returns a self-signed certificate and its key in PEM format.
+// The certificate stored in the SSL directory is reused if it was created
+// with the same common name and key type and is not about to expire
+func GetFakeSSLCert(cn, keyType string) ([]byte, []byte, error) {
+	pemFileName := fmt.Sprintf("%v/%v.pem", ingress.DefaultSSLDirectory, FakeCertificateName)
+	cert, key, err := readFakeSSLCert(pemFileName, cn, keyType, time.Now())
+	if err == nil {
+		return cert, key, nil
+	}
+
+	glog.V(2).Infof("generating a new fake certificate: %v", err)
+	return generateFakeSSLCert(cn, keyType, time.Now())
+}
+
+// FakeSSLCertRenewalRequired returns true if the fake certificate must
+// be generated again because it is about to expire
+func FakeSSLCertRenewalRequired(cert *ingress.SSLCert, now time.Time) bool {
+	return cert.NotAfter.Sub(now) < fakeCertificateRenewBefore
+}
+
+// readFakeSSLCert reads a fake certificate generated in a previous execution
+func readFakeSSLCert(pemFileName, cn, keyType string, now time.Time) ([]byte, []byte, error) {
+	data, err := ioutil.ReadFile(pemFileName)
 	if err != nil {
-		return nil, nil
+		return nil, nil, err
+	}
+
+	certBlock, rest := pem.Decode(data)
+	if certBlock == nil || certBlock.Type != "CERTIFICATE" {
+		return nil, nil, fmt.Errorf("no certificate found in %v", pemFileName)
+	}
+	keyBlock, _ := pem.Decode(rest)
+	if keyBlock == nil {
+		return nil, nil, fmt.Errorf("no private key found in %v", pemFileName)
+	}
+
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch {
+	case cert.Subject.CommonName != cn:
+		return nil, nil, fmt.Errorf("the certificate was created with a different common name")
+	case keyType == KeyTypeRSA && keyBlock.Type != "RSA PRIVATE KEY",
+		keyType == KeyTypeECDSA && keyBlock.Type != "EC PRIVATE KEY":
+		return nil, nil, fmt.Errorf("the certificate was created with a different key type")
+	case cert.NotAfter.Sub(now) < fakeCertificateRenewBefore:
+		return nil, nil, fmt.Errorf("the certificate expires at %v", cert.NotAfter)
+	}
+
+	return pem.EncodeToMemory(certBlock), pem.EncodeToMemory(keyBlock), nil
+}
+
+// generateFakeSSLCert creates a self-signed certificate for the common name
+func generateFakeSSLCert(cn, keyType string, now time.Time) ([]byte, []byte, error) {
+	var (
+		priv     crypto.Signer
+		keyBlock *pem.Block
+	)
+
+	switch keyType {
+	case KeyTypeRSA:
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, nil, err
+		}
+		priv = key
+		keyBlock = &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+	case KeyTypeECDSA:
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, nil, err
+		}
+		der, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return nil, nil, err
+		}
+		priv = key
+		keyBlock = &pem.Block{Type: "EC PRIVATE KEY", Bytes: der}
+	default:
+		return nil, nil, fmt.Errorf("invalid key type %v", keyType)
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(fakeCertificateValidity),
+		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"ingress.local"},
 	}
 
-	key, err := ioutil.ReadFile(snakeOilKey)
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, priv.Public(), priv)
 	if err != nil {
-		return nil, nil
+		return nil, nil, err
 	}
 
-	return cert, key
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(keyBlock), nil
 }
@@ -17,10 +17,21 @@ limitations under the License.
 package ssl
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/base64"
+	"encoding/pem"
 	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
 	"testing"
 	"time"
+
+	"github.com/aledbf/ingress-controller/pkg/ingress"
 )
 
 func TestAddOrUpdateCertAndKey(t *testing.T) {
@@ -56,4 +67,229 @@ func TestAddOrUpdateCertAndKey(t *testing.T) {
 	if ngxCert.CN[0] != "echoheaders" {
 		t.Fatalf("expected cname echoheaders but %v returned", ngxCert.CN[0])
 	}
+
+	if ngxCert.Issuer != "CN=echoheaders,O=echoheaders" {
+		t.Errorf("expected issuer CN=echoheaders,O=echoheaders but %v returned", ngxCert.Issuer)
+	}
+
+	if ngxCert.SerialNumber == "" {
+		t.Errorf("expected serial number but returned empty")
+	}
+
+	if !ngxCert.NotAfter.After(ngxCert.NotBefore) {
+		t.Errorf("expected a validity period but returned %v - %v", ngxCert.NotBefore, ngxCert.NotAfter)
+	}
+}
+
+func TestGenerateFakeSSLCert(t *testing.T) {
+	now := time.Now()
+	for _, keyType := range []string{KeyTypeRSA, KeyTypeECDSA} {
+		cert, key, err := generateFakeSSLCert("fake.local", keyType, now)
+		if err != nil {
+			t.Fatalf("unexpected error generating %v certificate: %v", keyType, err)
+		}
+
+		file, err := ioutil.TempFile("", "fake-cert")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer os.Remove(file.Name())
+		file.Write(cert)
+		file.Write([]byte("\n"))
+		file.Write(key)
+		file.Close()
+
+		if _, _, err := readFakeSSLCert(file.Name(), "fake.local", keyType, now); err != nil {
+			t.Errorf("expected the %v certificate to be reused but returned %v", keyType, err)
+		}
+		if _, _, err := readFakeSSLCert(file.Name(), "other.local", keyType, now); err == nil {
+			t.Errorf("expected an error with a different common name")
+		}
+		if _, _, err := readFakeSSLCert(file.Name(), "fake.local", keyType, now.Add(fakeCertificateValidity)); err == nil {
+			t.Errorf("expected an error with an expired certificate")
+		}
+	}
+
+	cert, key, _ := generateFakeSSLCert("fake.local", KeyTypeECDSA, now)
+	file, _ := ioutil.TempFile("", "fake-cert")
+	defer os.Remove(file.Name())
+	file.Write(append(append(cert, '\n'), key...))
+	file.Close()
+	if _, _, err := readFakeSSLCert(file.Name(), "fake.local", KeyTypeRSA, now); err == nil {
+		t.Errorf("expected an error with a different key type")
+	}
+
+	if _, _, err := generateFakeSSLCert("fake.local", "dsa", now); err == nil {
+		t.Errorf("expected an error with an invalid key type")
+	}
+}
+
+func TestIsValidHostname(t *testing.T) {
+	tests := map[string]struct {
+		host     string
+		cn       []string
+		expected bool
+	}{
+		"exact":            {"foo.bar", []string{"foo.bar"}, true},
+		"wildcard":         {"www.foo.bar", []string{"bar.baz", "*.foo.bar"}, true},
+		"wildcard label":   {"foo.bar", []string{"*.foo.bar"}, false},
+		"different host":   {"foo.baz", []string{"foo.bar"}, false},
+		"trailing dot":     {"foo.bar.", []string{"foo.bar"}, true},
+		"empty host":       {"", []string{"foo.bar"}, false},
+		"no common names":  {"foo.bar", nil, false},
+		"nested wildcards": {"a.b.foo.bar", []string{"*.foo.bar"}, false},
+	}
+
+	for title, tc := range tests {
+		if v := IsValidHostname(tc.host, tc.cn); v != tc.expected {
+			t.Errorf("%v: expected %v but returned %v", title, tc.expected, v)
+		}
+	}
+}
+
+func TestAddOrUpdateCertAndKeyType(t *testing.T) {
+	for _, keyType := range []string{KeyTypeRSA, KeyTypeECDSA} {
+		cert, key, err := generateFakeSSLCert("fake.local", keyType, time.Now())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		name := fmt.Sprintf("test-%v-%v", keyType, time.Now().UnixNano())
+		sslCert, err := AddOrUpdateCertAndKey(name, cert, key, []byte{})
+		if err != nil {
+			t.Fatalf("unexpected error checking SSL certificate: %v", err)
+		}
+		os.Remove(sslCert.PemFileName)
+
+		if sslCert.KeyType != keyType {
+			t.Errorf("expected key type %v but returned %v", keyType, sslCert.KeyType)
+		}
+	}
+}
+
+func TestValidateDualCertificates(t *testing.T) {
+	rsaCert := &ingress.SSLCert{KeyType: KeyTypeRSA, CN: []string{"foo.bar", "*.foo.bar"}}
+	ecdsaCert := &ingress.SSLCert{KeyType: KeyTypeECDSA, CN: []string{"www.foo.bar"}}
+
+	if err := ValidateDualCertificates("www.foo.bar", rsaCert, ecdsaCert); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := ValidateDualCertificates("foo.bar", rsaCert, ecdsaCert); err == nil {
+		t.Errorf("expected an error with an ECDSA certificate not valid for the host")
+	}
+	if err := ValidateDualCertificates("www.foo.bar", ecdsaCert, rsaCert); err == nil {
+		t.Errorf("expected an error with the certificates swapped")
+	}
+}
+
+func TestAddOrUpdateDHParam(t *testing.T) {
+	name := fmt.Sprintf("test-%v", time.Now().UnixNano())
+	dh := pem.EncodeToMemory(&pem.Block{Type: "DH PARAMETERS", Bytes: []byte("dh")})
+
+	f, err := AddOrUpdateDHParam(name, dh)
+	if err != nil {
+		t.Fatalf("unexpected error writing DH parameters: %v", err)
+	}
+	defer os.Remove(f.FileName)
+
+	content, err := ioutil.ReadFile(f.FileName)
+	if err != nil {
+		t.Fatalf("unexpected error reading DH parameters: %v", err)
+	}
+	if string(content) != string(dh) {
+		t.Errorf("expected the content of the DH parameters in %v", f.FileName)
+	}
+	if f.Checksum == "" {
+		t.Errorf("expected a checksum but returned empty")
+	}
+
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("cert")})
+	if _, err := AddOrUpdateDHParam(name, cert); err == nil {
+		t.Errorf("expected an error with a PEM block without DH parameters")
+	}
+}
+
+func TestAddOrUpdateSessionTicketKey(t *testing.T) {
+	name := fmt.Sprintf("test-%v", time.Now().UnixNano())
+
+	for _, size := range []int{48, 80} {
+		f, err := AddOrUpdateSessionTicketKey(name, make([]byte, size))
+		if err != nil {
+			t.Fatalf("unexpected error writing a key of %v bytes: %v", size, err)
+		}
+		os.Remove(f.FileName)
+	}
+
+	if _, err := AddOrUpdateSessionTicketKey(name, make([]byte, 32)); err == nil {
+		t.Errorf("expected an error with a key of 32 bytes")
+	}
+}
+
+func TestGetCertificateAndIssuer(t *testing.T) {
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, caKey.Public(), caKey)
+	if err != nil {
+		t.Fatalf("unexpected error creating CA: %v", err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	certDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "foo.bar"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}, ca, key.Public(), caKey)
+	if err != nil {
+		t.Fatalf("unexpected error creating certificate: %v", err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})
+	write := func(content ...[]byte) string {
+		f, err := ioutil.TempFile("", "ssl")
+		if err != nil {
+			t.Fatalf("unexpected error creating file: %v", err)
+		}
+		defer f.Close()
+		for _, c := range content {
+			f.Write(c)
+		}
+		return f.Name()
+	}
+
+	chain := write(certPEM, caPEM)
+	defer os.Remove(chain)
+	single := write(certPEM)
+	defer os.Remove(single)
+	caFile := write(caPEM)
+	defer os.Remove(caFile)
+
+	tests := map[string]*ingress.SSLCert{
+		"issuer in the chain": {PemFileName: chain},
+		"issuer in the CA":    {PemFileName: single, CAFileName: caFile},
+	}
+	for title, cert := range tests {
+		leaf, issuer, err := GetCertificateAndIssuer(cert)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", title, err)
+			continue
+		}
+		if leaf.Subject.CommonName != "foo.bar" || issuer.Subject.CommonName != "Test CA" {
+			t.Errorf("%v: unexpected certificates %v %v", title, leaf.Subject, issuer.Subject)
+		}
+	}
+
+	if _, _, err := GetCertificateAndIssuer(&ingress.SSLCert{PemFileName: single}); err == nil {
+		t.Errorf("expected an error without the issuer")
+	}
 }
@@ -0,0 +1,97 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package throttle
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// memcachedStore keeps the counters in a memcached server using
+// the text protocol
+// https://github.com/memcached/memcached/blob/master/doc/protocol.txt
+type memcachedStore struct {
+	pool *connPool
+}
+
+func (s *memcachedStore) Incr(key string, expiration time.Duration) (int64, error) {
+	conn, err := s.pool.get()
+	if err != nil {
+		return 0, err
+	}
+
+	rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	val, err := s.incr(rw, key, expiration)
+	s.pool.put(conn, err)
+	return val, err
+}
+
+func (s *memcachedStore) Close() {
+	s.pool.close()
+}
+
+func (s *memcachedStore) incr(rw *bufio.ReadWriter, key string, expiration time.Duration) (int64, error) {
+	// incr fails if the key does not exists. In that case the key is created
+	// with add. If add fails other client created the key first so we retry
+	for i := 0; i < 2; i++ {
+		line, err := memcachedCmd(rw, fmt.Sprintf("incr %v 1\r\n", key))
+		if err != nil {
+			return 0, err
+		}
+		if line != "NOT_FOUND" {
+			return strconv.ParseInt(line, 10, 64)
+		}
+
+		line, err = memcachedCmd(rw, fmt.Sprintf("add %v 0 %v 1\r\n1\r\n", key, int64(expiration.Seconds())))
+		if err != nil {
+			return 0, err
+		}
+		if line == "STORED" {
+			return 1, nil
+		}
+		if line != "NOT_STORED" {
+			return 0, fmt.Errorf("unexpected memcached response: %v", line)
+		}
+	}
+
+	return 0, fmt.Errorf("unable to increment memcached key %v", key)
+}
+
+// memcachedCmd sends a command returning the first line of the response
+func memcachedCmd(rw *bufio.ReadWriter, cmd string) (string, error) {
+	_, err := rw.WriteString(cmd)
+	if err != nil {
+		return "", err
+	}
+	err = rw.Flush()
+	if err != nil {
+		return "", err
+	}
+
+	line, err := rw.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimSpace(line)
+	if strings.HasSuffix(line, "ERROR") {
+		return "", fmt.Errorf("unexpected memcached response: %v", line)
+	}
+	return line, nil
+}
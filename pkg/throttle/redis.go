@@ -0,0 +1,88 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package throttle
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// incrScript increments a counter setting the expiration when the
+// counter is created. The script runs atomically in the server so the
+// counter cannot be created without an expiration
+const incrScript = `local v = redis.call("INCR", KEYS[1])
+if v == 1 then
+  redis.call("EXPIRE", KEYS[1], ARGV[1])
+end
+return v`
+
+// redisStore keeps the counters in a redis server
+// http://redis.io/topics/protocol
+type redisStore struct {
+	pool *connPool
+}
+
+func (s *redisStore) Incr(key string, expiration time.Duration) (int64, error) {
+	conn, err := s.pool.get()
+	if err != nil {
+		return 0, err
+	}
+
+	rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	val, err := s.incr(rw, key, expiration)
+	s.pool.put(conn, err)
+	return val, err
+}
+
+func (s *redisStore) Close() {
+	s.pool.close()
+}
+
+func (s *redisStore) incr(rw *bufio.ReadWriter, key string, expiration time.Duration) (int64, error) {
+	return redisCmd(rw, "EVAL", incrScript, "1", key, fmt.Sprintf("%v", int64(expiration.Seconds())))
+}
+
+// redisCmd sends a command that returns an integer reply
+func redisCmd(rw *bufio.ReadWriter, args ...string) (int64, error) {
+	cmd := fmt.Sprintf("*%v\r\n", len(args))
+	for _, arg := range args {
+		cmd = fmt.Sprintf("%v$%v\r\n%v\r\n", cmd, len(arg), arg)
+	}
+
+	_, err := rw.WriteString(cmd)
+	if err != nil {
+		return 0, err
+	}
+	err = rw.Flush()
+	if err != nil {
+		return 0, err
+	}
+
+	line, err := rw.ReadString('\n')
+	if err != nil {
+		return 0, err
+	}
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, ":") {
+		return 0, fmt.Errorf("unexpected redis response: %v", line)
+	}
+
+	return strconv.ParseInt(line[1:], 10, 64)
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package throttle
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	// Memcached uses a memcached server to keep the counters
+	Memcached = "memcached"
+	// Redis uses a redis server to keep the counters
+	Redis = "redis"
+
+	// maximum number of idle connections kept open to the store
+	maxIdleConns = 16
+)
+
+// Store is a storage of counters shared between all the replicas
+// of the Ingress controller
+type Store interface {
+	// Incr increments the counter with the specified key returning the new
+	// value. If the key does not exists is created with the specified expiration
+	Incr(key string, expiration time.Duration) (int64, error)
+	// Close closes all the connections to the store
+	Close()
+}
+
+// StoreConfig describes the location of a store
+type StoreConfig struct {
+	// Type of store (memcached or redis)
+	Type string
+	Host string
+	Port int
+	// Timeout is the maximum amount of time to wait for a response
+	Timeout time.Duration
+}
+
+// NewStore returns a Store for the specified configuration
+func NewStore(cfg StoreConfig) (Store, error) {
+	p := &connPool{
+		address: net.JoinHostPort(cfg.Host, fmt.Sprintf("%v", cfg.Port)),
+		timeout: cfg.Timeout,
+		conns:   make(chan net.Conn, maxIdleConns),
+	}
+
+	switch cfg.Type {
+	case Memcached:
+		return &memcachedStore{p}, nil
+	case Redis:
+		return &redisStore{p}, nil
+	}
+
+	return nil, fmt.Errorf("invalid store type %v", cfg.Type)
+}
+
+// connPool keeps a list of idle connections to a server
+type connPool struct {
+	address string
+	timeout time.Duration
+	conns   chan net.Conn
+}
+
+// get returns an idle connection or a new one if there is none available.
+// The deadline of the connection is set using the configured timeout
+func (p *connPool) get() (net.Conn, error) {
+	var conn net.Conn
+	select {
+	case conn = <-p.conns:
+	default:
+		c, err := net.DialTimeout("tcp", p.address, p.timeout)
+		if err != nil {
+			return nil, err
+		}
+		conn = c
+	}
+
+	if p.timeout > 0 {
+		conn.SetDeadline(time.Now().Add(p.timeout))
+	}
+	return conn, nil
+}
+
+// put returns the connection to the pool. In case of error or if the
+// pool is full the connection is closed
+func (p *connPool) put(conn net.Conn, err error) {
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	select {
+	case p.conns <- conn:
+	default:
+		conn.Close()
+	}
+}
+
+func (p *connPool) close() {
+	for {
+		select {
+		case conn := <-p.conns:
+			conn.Close()
+		default:
+			return
+		}
+	}
+}
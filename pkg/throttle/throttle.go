@@ -0,0 +1,138 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package throttle
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// Throttle implements a fixed window rate limit where the counters are
+// shared between all the replicas of the Ingress controller using a
+// Store. If the store is not configured or is not available the requests
+// are allowed (degrade open)
+type Throttle struct {
+	mu    sync.RWMutex
+	cfg   StoreConfig
+	store Store
+
+	now func() time.Time
+}
+
+// NewThrottle returns a Throttle without store
+func NewThrottle() *Throttle {
+	return &Throttle{
+		now: time.Now,
+	}
+}
+
+// Configure changes the store used to keep the counters.
+// An empty type disables the use of a store
+func (t *Throttle) Configure(cfg StoreConfig) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if reflect.DeepEqual(t.cfg, cfg) {
+		return nil
+	}
+
+	if t.store != nil {
+		t.store.Close()
+		t.store = nil
+	}
+	t.cfg = cfg
+
+	if cfg.Type == "" {
+		return nil
+	}
+
+	s, err := NewStore(cfg)
+	if err != nil {
+		return err
+	}
+
+	glog.Infof("using %v (%v:%v) to store global rate limit counters", cfg.Type, cfg.Host, cfg.Port)
+	t.store = s
+	return nil
+}
+
+// Allow checks if a request with the specified key is allowed by the rate
+// limit with the specified name. The limit is the number of requests allowed
+// in a window.
+func (t *Throttle) Allow(name, key string, limit int, window time.Duration) bool {
+	t.mu.RLock()
+	s := t.store
+	t.mu.RUnlock()
+
+	if s == nil || window < time.Second {
+		return true
+	}
+
+	// the value of the key could contain any character and the name
+	// (namespace and Ingress rule) is too long for memcached, that only
+	// accepts keys up to 250 bytes. The name never contains a NUL byte
+	hasher := sha1.New()
+	hasher.Write([]byte(name))
+	hasher.Write([]byte{0})
+	hasher.Write([]byte(key))
+
+	w := int64(window.Seconds())
+	counter := fmt.Sprintf("%v_%v", hex.EncodeToString(hasher.Sum(nil)), t.now().Unix()/w)
+	val, err := s.Incr(counter, window)
+	if err != nil {
+		glog.Warningf("unexpected error obtaining global rate limit %v (allowing request): %v", name, err)
+		return true
+	}
+
+	return val <= int64(limit)
+}
+
+// ServeHTTP checks if a request is allowed by a global rate limit.
+// The parameters are obtained from the query string:
+// name, key, limit and window (seconds). It returns 200 if the
+// request is allowed or 429 if not
+func (t *Throttle) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	name := q.Get("name")
+	limit, err := strconv.Atoi(q.Get("limit"))
+	if name == "" || err != nil || limit <= 0 {
+		http.Error(w, "invalid name or limit", http.StatusBadRequest)
+		return
+	}
+
+	window, err := strconv.Atoi(q.Get("window"))
+	if err != nil || window <= 0 {
+		http.Error(w, "invalid window", http.StatusBadRequest)
+		return
+	}
+
+	if !t.Allow(name, q.Get("key"), limit, time.Duration(window)*time.Second) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
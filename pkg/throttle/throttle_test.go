@@ -0,0 +1,293 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package throttle
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer is a minimal memcached or redis server that only
+// understands the commands used by the stores
+type fakeServer struct {
+	l net.Listener
+
+	mu       sync.Mutex
+	counters map[string]int64
+}
+
+func newFakeServer(t *testing.T, handler func(*fakeServer, *bufio.ReadWriter) error) *fakeServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	s := &fakeServer{l: l, counters: map[string]int64{}}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+				rw := bufio.NewReadWriter(bufio.NewReader(c), bufio.NewWriter(c))
+				for handler(s, rw) == nil {
+					rw.Flush()
+				}
+			}(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *fakeServer) config(storeType string) StoreConfig {
+	addr := s.l.Addr().(*net.TCPAddr)
+	return StoreConfig{
+		Type:    storeType,
+		Host:    addr.IP.String(),
+		Port:    addr.Port,
+		Timeout: time.Second,
+	}
+}
+
+// memcachedMaxKeyLength is the maximum length of a key in memcached
+const memcachedMaxKeyLength = 250
+
+func fakeMemcached(s *fakeServer, rw *bufio.ReadWriter) error {
+	line, err := rw.ReadString('\n')
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	parts := strings.Fields(line)
+	if len(parts) > 1 && len(parts[1]) > memcachedMaxKeyLength {
+		if parts[0] == "add" {
+			rw.ReadString('\n')
+		}
+		rw.WriteString("CLIENT_ERROR bad command line format\r\n")
+		return nil
+	}
+
+	switch parts[0] {
+	case "incr":
+		if _, ok := s.counters[parts[1]]; !ok {
+			rw.WriteString("NOT_FOUND\r\n")
+			return nil
+		}
+		s.counters[parts[1]]++
+		rw.WriteString(fmt.Sprintf("%v\r\n", s.counters[parts[1]]))
+	case "add":
+		// value of the key
+		rw.ReadString('\n')
+		if _, ok := s.counters[parts[1]]; ok {
+			rw.WriteString("NOT_STORED\r\n")
+			return nil
+		}
+		s.counters[parts[1]] = 1
+		rw.WriteString("STORED\r\n")
+	default:
+		rw.WriteString("ERROR\r\n")
+	}
+	return nil
+}
+
+func fakeRedis(s *fakeServer, rw *bufio.ReadWriter) error {
+	line, err := rw.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+	args := []string{}
+	for i := 0; i < n; i++ {
+		// length of the argument
+		l, err := rw.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		size, _ := strconv.Atoi(strings.TrimSpace(l[1:]))
+		arg := make([]byte, size+2)
+		if _, err := io.ReadFull(rw, arg); err != nil {
+			return err
+		}
+		args = append(args, string(arg[:size]))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch args[0] {
+	case "EVAL":
+		// only the script used by the store with one key and the expiration
+		if args[1] != incrScript || args[2] != "1" || len(args) != 5 {
+			rw.WriteString("-ERR unexpected script\r\n")
+			return nil
+		}
+		s.counters[args[3]]++
+		rw.WriteString(fmt.Sprintf(":%v\r\n", s.counters[args[3]]))
+	default:
+		rw.WriteString("-ERR unknown command\r\n")
+	}
+	return nil
+}
+
+func TestThrottle(t *testing.T) {
+	for storeType, handler := range map[string]func(*fakeServer, *bufio.ReadWriter) error{
+		Memcached: fakeMemcached,
+		Redis:     fakeRedis,
+	} {
+		s := newFakeServer(t, handler)
+
+		th := NewThrottle()
+		now := time.Unix(60, 0)
+		th.now = func() time.Time { return now }
+
+		err := th.Configure(s.config(storeType))
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", storeType, err)
+		}
+
+		for i := 0; i < 3; i++ {
+			if !th.Allow("default_foo", "10.0.0.1", 3, time.Minute) {
+				t.Errorf("%v: expected request %v to be allowed", storeType, i)
+			}
+		}
+		if th.Allow("default_foo", "10.0.0.1", 3, time.Minute) {
+			t.Errorf("%v: expected request to be rejected", storeType)
+		}
+		if !th.Allow("default_foo", "10.0.0.2", 3, time.Minute) {
+			t.Errorf("%v: expected request with a different key to be allowed", storeType)
+		}
+
+		// next window
+		now = now.Add(time.Minute)
+		if !th.Allow("default_foo", "10.0.0.1", 3, time.Minute) {
+			t.Errorf("%v: expected request to be allowed in a new window", storeType)
+		}
+
+		s.l.Close()
+		th.Configure(StoreConfig{})
+	}
+}
+
+func TestThrottleLongName(t *testing.T) {
+	s := newFakeServer(t, fakeMemcached)
+	defer s.l.Close()
+
+	th := NewThrottle()
+	th.Configure(s.config(Memcached))
+
+	// the name of an Ingress rule can contain up to 253 characters
+	name := "default_" + strings.Repeat("a", 253)
+	for i := 0; i < 2; i++ {
+		th.Allow(name, "10.0.0.1", 1, time.Minute)
+	}
+	if th.Allow(name, "10.0.0.1", 1, time.Minute) {
+		t.Errorf("expected request to be rejected")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key := range s.counters {
+		if len(key) > memcachedMaxKeyLength {
+			t.Errorf("expected a key shorter than %v bytes but returned %v", memcachedMaxKeyLength, len(key))
+		}
+	}
+
+	// other rules with the same prefix use other counters
+	if !th.Allow(name[:len(name)-1], "10.0.0.1", 1, time.Minute) {
+		t.Errorf("expected request of a different rule to be allowed")
+	}
+}
+
+func TestThrottleDegradeOpen(t *testing.T) {
+	th := NewThrottle()
+	if !th.Allow("default_foo", "10.0.0.1", 1, time.Minute) {
+		t.Errorf("expected request to be allowed without store")
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	err = th.Configure(StoreConfig{Type: Redis, Host: "127.0.0.1", Port: port, Timeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if !th.Allow("default_foo", "10.0.0.1", 1, time.Minute) {
+			t.Errorf("expected request to be allowed with an unavailable store")
+		}
+	}
+}
+
+func TestThrottleHandler(t *testing.T) {
+	s := newFakeServer(t, fakeRedis)
+	defer s.l.Close()
+
+	th := NewThrottle()
+	th.Configure(s.config(Redis))
+
+	ts := httptest.NewServer(th)
+	defer ts.Close()
+
+	codes := []int{}
+	for _, q := range []string{
+		"name=default_foo&key=abc&limit=1&window=60",
+		"name=default_foo&key=abc&limit=1&window=60",
+		"name=default_foo&key=abc&limit=1",
+		"key=abc&limit=1&window=60",
+	} {
+		res, err := http.Get(fmt.Sprintf("%v/?%v", ts.URL, q))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		res.Body.Close()
+		codes = append(codes, res.StatusCode)
+	}
+
+	expected := []int{http.StatusOK, http.StatusTooManyRequests, http.StatusBadRequest, http.StatusBadRequest}
+	for i := range expected {
+		if codes[i] != expected[i] {
+			t.Errorf("expected status codes %v but %v returned", expected, codes)
+			break
+		}
+	}
+}
+
+func TestInvalidStore(t *testing.T) {
+	_, err := NewStore(StoreConfig{Type: "etcd"})
+	if err == nil {
+		t.Errorf("expected error with an invalid store type")
+	}
+}
@@ -0,0 +1,283 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package traffic
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	ns = "ingress_controller"
+	// maximum size of a datagram sent by NGINX
+	maxDatagramSize = 65536
+)
+
+var (
+	labels = []string{"host", "path", "namespace", "ingress", "service", "upstream"}
+
+	statusClasses = []string{"1xx", "2xx", "3xx", "4xx", "5xx"}
+
+	durationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30}
+)
+
+// Labels identifies the location that processed a request
+type Labels struct {
+	Host      string
+	Path      string
+	Namespace string
+	Ingress   string
+	Service   string
+	Upstream  string
+}
+
+func (l Labels) values() []string {
+	return []string{l.Host, l.Path, l.Namespace, l.Ingress, l.Service, l.Upstream}
+}
+
+// request contains the information of a request sent by NGINX.
+// All the values are strings to simplify the encoding in Lua
+type request struct {
+	Host                 string `json:"host"`
+	Path                 string `json:"path"`
+	Namespace            string `json:"namespace"`
+	Ingress              string `json:"ingress"`
+	Service              string `json:"service"`
+	Upstream             string `json:"upstream"`
+	Status               string `json:"status"`
+	RequestTime          string `json:"requestTime"`
+	UpstreamResponseTime string `json:"upstreamResponseTime"`
+	RequestLength        string `json:"requestLength"`
+	BytesSent            string `json:"bytesSent"`
+}
+
+// Collector exposes as Prometheus metrics the information about the
+// requests processed by NGINX received in a UDP socket. Each datagram
+// contains one or more requests encoded in JSON separated by new lines
+type Collector struct {
+	mu sync.Mutex
+	// label sets with at least one request
+	seen map[Labels]bool
+
+	requests         *prometheus.CounterVec
+	requestDuration  *prometheus.HistogramVec
+	upstreamDuration *prometheus.HistogramVec
+	requestBytes     *prometheus.CounterVec
+	responseBytes    *prometheus.CounterVec
+}
+
+// NewCollector returns a Collector. The collector must be registered
+// to expose the metrics
+func NewCollector() *Collector {
+	return &Collector{
+		seen: map[Labels]bool{},
+		requests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: ns,
+				Name:      "requests",
+				Help:      "Cumulative number of client requests by status class",
+			},
+			append(labels, "status"),
+		),
+		requestDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: ns,
+				Name:      "request_duration_seconds",
+				Help:      "Time spent processing client requests",
+				Buckets:   durationBuckets,
+			},
+			labels,
+		),
+		upstreamDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: ns,
+				Name:      "upstream_response_duration_seconds",
+				Help:      "Time spent receiving the response from the upstream servers",
+				Buckets:   durationBuckets,
+			},
+			labels,
+		),
+		requestBytes: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: ns,
+				Name:      "request_bytes",
+				Help:      "Cumulative size of the client requests (request line, headers and body)",
+			},
+			labels,
+		),
+		responseBytes: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: ns,
+				Name:      "response_bytes",
+				Help:      "Cumulative number of bytes sent to the clients",
+			},
+			labels,
+		),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.requests.Describe(ch)
+	c.requestDuration.Describe(ch)
+	c.upstreamDuration.Describe(ch)
+	c.requestBytes.Describe(ch)
+	c.responseBytes.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.requests.Collect(ch)
+	c.requestDuration.Collect(ch)
+	c.upstreamDuration.Collect(ch)
+	c.requestBytes.Collect(ch)
+	c.responseBytes.Collect(ch)
+}
+
+// Listen reads the requests sent to the UDP address
+func (c *Collector) Listen(addr string) error {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return err
+		}
+		c.handle(buf[:n])
+	}
+}
+
+// handle updates the metrics with the requests contained in a datagram
+func (c *Collector) handle(data []byte) {
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var r request
+		if err := json.Unmarshal(line, &r); err != nil {
+			glog.V(3).Infof("invalid request information %v: %v", string(line), err)
+			continue
+		}
+		c.observe(r)
+	}
+}
+
+func (c *Collector) observe(r request) {
+	l := Labels{
+		Host:      r.Host,
+		Path:      r.Path,
+		Namespace: r.Namespace,
+		Ingress:   r.Ingress,
+		Service:   r.Service,
+		Upstream:  r.Upstream,
+	}
+	values := l.values()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seen[l] = true
+
+	c.requests.WithLabelValues(append(values, statusClass(r.Status))...).Inc()
+
+	if v, ok := parseFloat(r.RequestTime); ok {
+		c.requestDuration.WithLabelValues(values...).Observe(v)
+	}
+	if v, ok := parseFloat(r.UpstreamResponseTime); ok {
+		c.upstreamDuration.WithLabelValues(values...).Observe(v)
+	}
+	if v, ok := parseFloat(r.RequestLength); ok {
+		c.requestBytes.WithLabelValues(values...).Add(v)
+	}
+	if v, ok := parseFloat(r.BytesSent); ok {
+		c.responseBytes.WithLabelValues(values...).Add(v)
+	}
+}
+
+// Prune removes the metrics of the locations not contained in the list
+// to avoid exposing metrics of Ingress rules that do not exist anymore
+func (c *Collector) Prune(valid []Labels) {
+	keep := map[Labels]bool{}
+	for _, l := range valid {
+		keep[l] = true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for l := range c.seen {
+		if keep[l] {
+			continue
+		}
+
+		values := l.values()
+		for _, class := range statusClasses {
+			c.requests.DeleteLabelValues(append(values, class)...)
+		}
+		c.requests.DeleteLabelValues(append(values, "")...)
+		c.requestDuration.DeleteLabelValues(values...)
+		c.upstreamDuration.DeleteLabelValues(values...)
+		c.requestBytes.DeleteLabelValues(values...)
+		c.responseBytes.DeleteLabelValues(values...)
+		delete(c.seen, l)
+	}
+}
+
+// statusClass returns the class (ie 2xx) of a status code
+func statusClass(status string) string {
+	if len(status) != 3 || status[0] < '1' || status[0] > '5' {
+		return ""
+	}
+	return status[:1] + "xx"
+}
+
+// parseFloat parses a value sent by NGINX. If the request was
+// processed by more than one upstream server the value contains
+// one value for each server (ie "0.010, 0.012" or "0.010 : 0.012").
+// In that case the sum is returned
+func parseFloat(val string) (float64, bool) {
+	var sum float64
+	found := false
+	for _, v := range strings.FieldsFunc(val, func(r rune) bool {
+		return r == ',' || r == ':' || r == ' '
+	}) {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			continue
+		}
+		sum += f
+		found = true
+	}
+	return sum, found
+}
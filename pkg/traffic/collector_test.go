@@ -0,0 +1,166 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package traffic
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	fooRequest = `{"host":"foo.bar","path":"/","namespace":"default","ingress":"foo","service":"foo-svc","upstream":"default-foo-svc-80","status":"200","requestTime":"0.020","upstreamResponseTime":"0.010, 0.005","requestLength":"100","bytesSent":"1000"}`
+	barRequest = `{"host":"bar.baz","path":"/api","namespace":"default","ingress":"bar","service":"bar-svc","upstream":"default-bar-svc-80","status":"503","requestTime":"0.5","upstreamResponseTime":"-","requestLength":"50","bytesSent":"10"}`
+)
+
+var (
+	fooLabels = Labels{"foo.bar", "/", "default", "foo", "foo-svc", "default-foo-svc-80"}
+	barLabels = Labels{"bar.baz", "/api", "default", "bar", "bar-svc", "default-bar-svc-80"}
+)
+
+func value(t *testing.T, m prometheus.Metric) *dto.Metric {
+	v := &dto.Metric{}
+	if err := m.Write(v); err != nil {
+		t.Fatalf("unexpected error reading metric: %v", err)
+	}
+	return v
+}
+
+func count(c prometheus.Collector) int {
+	ch := make(chan prometheus.Metric, 100)
+	c.Collect(ch)
+	close(ch)
+	return len(ch)
+}
+
+func TestHandle(t *testing.T) {
+	c := NewCollector()
+	c.handle([]byte(fooRequest + "\n" + fooRequest + "\n" + barRequest + "\ninvalid\n"))
+
+	foo := append(fooLabels.values(), "2xx")
+	if v := value(t, c.requests.WithLabelValues(foo...)).Counter.GetValue(); v != 2 {
+		t.Errorf("expected 2 requests but returned %v", v)
+	}
+	bar := append(barLabels.values(), "5xx")
+	if v := value(t, c.requests.WithLabelValues(bar...)).Counter.GetValue(); v != 1 {
+		t.Errorf("expected 1 request but returned %v", v)
+	}
+
+	h := value(t, c.upstreamDuration.WithLabelValues(fooLabels.values()...)).Histogram
+	if h.GetSampleCount() != 2 || h.GetSampleSum() < 0.0299 || h.GetSampleSum() > 0.0301 {
+		t.Errorf("expected 2 samples with a sum of 0.03 but returned %v and %v", h.GetSampleCount(), h.GetSampleSum())
+	}
+
+	if v := value(t, c.responseBytes.WithLabelValues(fooLabels.values()...)).Counter.GetValue(); v != 2000 {
+		t.Errorf("expected 2000 bytes but returned %v", v)
+	}
+
+	// bar.baz does not contains an upstream response time
+	if n := count(c.upstreamDuration); n != 1 {
+		t.Errorf("expected 1 upstream histogram but returned %v", n)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	c := NewCollector()
+	c.handle([]byte(fooRequest + "\n" + barRequest))
+
+	c.Prune([]Labels{fooLabels})
+
+	if n := count(c.requests); n != 1 {
+		t.Errorf("expected 1 request counter but returned %v", n)
+	}
+	if n := count(c.requestDuration); n != 1 {
+		t.Errorf("expected 1 request histogram but returned %v", n)
+	}
+	if len(c.seen) != 1 || !c.seen[fooLabels] {
+		t.Errorf("expected only the labels of foo.bar but returned %v", c.seen)
+	}
+}
+
+func TestListen(t *testing.T) {
+	c := NewCollector()
+	go c.Listen("127.0.0.1:10248")
+
+	var err error
+	var conn net.Conn
+	for i := 0; i < 10; i++ {
+		conn, err = net.Dial("udp", "127.0.0.1:10248")
+		if err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer conn.Close()
+
+	for i := 0; i < 20; i++ {
+		conn.Write([]byte(fooRequest))
+		time.Sleep(50 * time.Millisecond)
+		if count(c.requests) > 0 {
+			return
+		}
+	}
+	t.Errorf("expected requests received in the UDP socket")
+}
+
+func TestParseFloat(t *testing.T) {
+	testCases := map[string]struct {
+		value float64
+		ok    bool
+	}{
+		"":                 {0, false},
+		"-":                {0, false},
+		"0.010":            {0.010, true},
+		"1, 2":             {3, true},
+		"1 : 2, 3":         {6, true},
+		"1, -":             {1, true},
+		"invalid":          {0, false},
+		"0.000":            {0, true},
+		"10.5, 0.5 : 1.25": {12.25, true},
+	}
+
+	for val, tc := range testCases {
+		v, ok := parseFloat(val)
+		if v != tc.value || ok != tc.ok {
+			t.Errorf("%q: expected %v (%v) but returned %v (%v)", val, tc.value, tc.ok, v, ok)
+		}
+	}
+}
+
+func TestStatusClass(t *testing.T) {
+	testCases := map[string]string{
+		"200": "2xx",
+		"304": "3xx",
+		"499": "4xx",
+		"504": "5xx",
+		"":    "",
+		"600": "",
+		"20":  "",
+	}
+
+	for status, class := range testCases {
+		if c := statusClass(status); c != class {
+			t.Errorf("%q: expected %v but returned %v", status, class, c)
+		}
+	}
+}